@@ -2,50 +2,519 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
 
+	"arc/client"
 	"arc/database"
 	"arc/server"
 	"arc/vm"
 )
 
 func printUsage() {
-	println("Usage: arc [mode]")
+	println("Usage: arc [mode] [options]")
 	println("")
 	println("Available modes:")
 	println("- client: run in client mode and connect to server at <localhost:8080>")
-	println("- server: run in server mode at <localhost:8080>")
+	println("- server [address] [options]: run in server mode at <address> (defaults to <:8080>)")
 	println("- standalone: run in standalone mode")
+	println("")
+	println("Client options:")
+	println("- --bigkeys: scan the database and report the largest keys per type")
+	println("")
+	println("Server options (0 disables a limit):")
+	println("- --read-header-timeout, --read-timeout, --write-timeout, --idle-timeout duration (like 30s)")
+	println("- --max-header-bytes, --max-body-size bytes")
+	println("- --max-connections, --max-requests count")
+	println("- --read-rate, --write-rate commands per second per client, --read-burst, --write-burst count")
+	println("- --rate-limit-by ip|user|api-key (the X-API-Key header), defaults to ip")
+	println("- --api-keys file: the API keys allowed to have their own budgets, one per line (other keys are limited by IP)")
+	println("- --users file: authenticate the users listed as user:password lines (HTTP basic authentication)")
+}
+
+// API key header of the clients when the rate limits are by API key.
+const apiKeyHeader = "X-API-Key"
+
+// serverOptions are the server mode options.
+type serverOptions struct {
+	address       string
+	limits        server.Limits
+	rateLimits    server.RateLimits
+	authenticator server.Authenticator
+}
+
+// parseServerOptions parses the server mode options: the (optional) address followed by the server limits, the rate
+// limits (disabled without read and write rates), the API keys file and the users file.
+func parseServerOptions(arguments []string) (options serverOptions, err error) {
+	var limits, rateLimits = server.DefaultLimits(), server.RateLimits{}
+
+	options.address = ":8080"
+
+	if (len(arguments) > 0) && !strings.HasPrefix(arguments[0], "-") {
+		options.address = arguments[0]
+		arguments = arguments[1:]
+	}
+
+	var flags = flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	flags.DurationVar(&limits.ReadHeaderTimeout, "read-header-timeout", limits.ReadHeaderTimeout, "")
+	flags.DurationVar(&limits.ReadTimeout, "read-timeout", limits.ReadTimeout, "")
+	flags.DurationVar(&limits.WriteTimeout, "write-timeout", limits.WriteTimeout, "")
+	flags.DurationVar(&limits.IdleTimeout, "idle-timeout", limits.IdleTimeout, "")
+	flags.IntVar(&limits.MaxHeaderBytes, "max-header-bytes", limits.MaxHeaderBytes, "")
+	flags.Int64Var(&limits.MaxBodySize, "max-body-size", limits.MaxBodySize, "")
+	flags.IntVar(&limits.MaxConnections, "max-connections", limits.MaxConnections, "")
+	flags.IntVar(&limits.MaxConcurrentRequests, "max-requests", limits.MaxConcurrentRequests, "")
+
+	var rateLimitBy, apiKeysFile, usersFile = "ip", "", ""
+
+	flags.Float64Var(&rateLimits.Read.Rate, "read-rate", 0, "")
+	flags.IntVar(&rateLimits.Read.Burst, "read-burst", 0, "")
+	flags.Float64Var(&rateLimits.Write.Rate, "write-rate", 0, "")
+	flags.IntVar(&rateLimits.Write.Burst, "write-burst", 0, "")
+	flags.StringVar(&rateLimitBy, "rate-limit-by", rateLimitBy, "")
+	flags.StringVar(&apiKeysFile, "api-keys", apiKeysFile, "")
+	flags.StringVar(&usersFile, "users", usersFile, "")
+
+	if err = flags.Parse(arguments); err != nil {
+		return
+	}
+
+	if flags.NArg() > 0 {
+		err = fmt.Errorf("unexpected argument %q", flags.Arg(0))
+		return
+	}
+
+	if (rateLimits.Read.Rate < 0) || (rateLimits.Write.Rate < 0) || (rateLimits.Read.Burst < 0) || (rateLimits.Write.Burst < 0) {
+		err = fmt.Errorf("negative rate limit")
+		return
+	}
+
+	switch rateLimitBy {
+	case "ip":
+		rateLimits.Key = server.RateLimitByIP
+	case "user":
+		rateLimits.Key = server.RateLimitByUser
+	case "api-key":
+		if apiKeysFile == "" {
+			err = errors.New("rate limits by API key need the --api-keys file")
+			return
+		}
+
+		var apiKeys []string
+
+		if apiKeys, err = readAPIKeys(apiKeysFile); err != nil {
+			return
+		}
+
+		rateLimits.Key = server.RateLimitByAPIKey(apiKeyHeader, apiKeys)
+	default:
+		err = fmt.Errorf("invalid rate limit key %q, expected ip, user or api-key", rateLimitBy)
+		return
+	}
+
+	if usersFile != "" {
+		var passwords map[string]string
+
+		if passwords, err = readUsers(usersFile); err != nil {
+			return
+		}
+
+		options.authenticator = server.BasicAuthenticator(passwords)
+	} else if rateLimitBy == "user" {
+		err = errors.New("rate limits by user need the --users file")
+		return
+	}
+
+	options.limits, options.rateLimits = limits, rateLimits
+	return
+}
+
+// readUsers reads the passwords of the users from a file of user:password lines.
+func readUsers(path string) (passwords map[string]string, err error) {
+	passwords = make(map[string]string)
+
+	err = readLines(path, func(line string, lineNumber int) error {
+		var user, password, found = strings.Cut(line, ":")
+
+		if !found || (user == "") {
+			return fmt.Errorf("%s:%d: user:password expected", path, lineNumber)
+		}
+
+		passwords[user] = password
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// readAPIKeys reads the API keys from a file of one key per line.
+func readAPIKeys(path string) (apiKeys []string, err error) {
+	err = readLines(path, func(line string, lineNumber int) error {
+		apiKeys = append(apiKeys, line)
+		return nil
+	})
+
+	if (err == nil) && (len(apiKeys) == 0) {
+		err = fmt.Errorf("%s: no API keys", path)
+	}
+
+	return
 }
 
-func runServer() {
+// readLines calls handle with the trimmed lines of a file and their number, empty lines and lines starting with # are
+// ignored. Stops at the first error of handle.
+func readLines(path string, handle func(line string, lineNumber int) error) error {
+	var file, err = os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var scanner = bufio.NewScanner(file)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line = strings.TrimSpace(scanner.Text())
+
+		if (line == "") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err = handle(line, lineNumber); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func runServer(options serverOptions) {
 	var db = database.Create()
+	db.StartActiveExpiration()
 	log.Print("ARC: database created.")
 
 	var runtime = vm.CreateRuntime(vm.StandardLibrary, db)
 	log.Print("ARC: runtime created with standard library.")
 
-	var server = server.Create(":8080", runtime)
-	log.Print("ARC: server created to run at :8080.")
+	var server = server.Create(options.address, runtime)
+	server.SetLimits(options.limits)
+
+	if options.authenticator != nil {
+		server.SetAuthenticator(options.authenticator)
+		log.Print("ARC: user authentication enabled.")
+	}
+
+	if (options.rateLimits.Read.Rate > 0) || (options.rateLimits.Write.Rate > 0) {
+		server.SetRateLimits(options.rateLimits)
+		log.Print("ARC: per-client rate limits enabled.")
+	}
+
+	log.Printf("ARC: server created to run at %s.", options.address)
 
 	log.Print("ARC: running...")
 	defer log.Print("ARC: done.")
 
-	log.Fatal(server.Run())
+	var err = server.Run()
+	db.Close()
+	log.Fatal(err)
+}
+
+// streamFromServer prints the data of a server event stream until the user presses ENTER.
+func streamFromServer(path string, commandLineScanner *bufio.Scanner, printData func(data string)) {
+	var streamContext, stopStream = context.WithCancel(context.Background())
+	defer stopStream()
+
+	var request, _ = http.NewRequestWithContext(streamContext, http.MethodGet, "http://"+client.DefaultAddress+path, nil)
+	var httpResponse, err = http.DefaultClient.Do(request)
+
+	if err != nil {
+		fmt.Printf("HTTP ERROR: %v.\n", err)
+		return
+	}
+
+	if httpResponse.StatusCode != http.StatusOK {
+		httpResponse.Body.Close()
+		fmt.Printf("HTTP ERROR: %s.\n", httpResponse.Status)
+		return
+	}
+
+	println("Streaming, press ENTER to stop.")
+
+	go func() {
+		defer httpResponse.Body.Close()
+
+		var eventScanner = bufio.NewScanner(httpResponse.Body)
+
+		for eventScanner.Scan() {
+			if data, isData := strings.CutPrefix(eventScanner.Text(), "data: "); isData {
+				printData(data)
+			}
+		}
+	}()
+
+	commandLineScanner.Scan()
+}
+
+func printPubsubEvent(data string) {
+	var event struct {
+		Type    string
+		Pattern string
+		Channel string
+		Payload string
+		Count   int
+	}
+
+	if json.Unmarshal([]byte(data), &event) != nil {
+		println(data)
+		return
+	}
+
+	switch event.Type {
+	case "subscribe":
+		fmt.Printf("subscribe %s %d\n", event.Channel, event.Count)
+	case "psubscribe":
+		fmt.Printf("psubscribe %s %d\n", event.Pattern, event.Count)
+	case "message":
+		fmt.Printf("message %s %s\n", event.Channel, event.Payload)
+	case "pmessage":
+		fmt.Printf("pmessage %s %s %s\n", event.Pattern, event.Channel, event.Payload)
+	}
+}
+
+// subscribeLocally prints the messages published to the runtime broker until the user presses ENTER.
+func subscribeLocally(runtime *vm.Runtime, channels []string, patterns []string, commandLineScanner *bufio.Scanner) {
+	var subscription = runtime.GetBroker().Subscribe()
+
+	subscription.Subscribe(channels...)
+	subscription.PSubscribe(patterns...)
+
+	println("Subscribed, press ENTER to stop.")
+
+	go func() {
+		for message := range subscription.Messages() {
+			if message.Pattern != "" {
+				fmt.Printf("pmessage %s %s %s\n", message.Pattern, message.Channel, message.Payload)
+			} else {
+				fmt.Printf("message %s %s\n", message.Channel, message.Payload)
+			}
+		}
+	}()
+
+	commandLineScanner.Scan()
+	subscription.Close()
+}
+
+// subscribe handles the SUBSCRIBE and PSUBSCRIBE shell commands.
+func subscribe(standalone bool, runtime *vm.Runtime, commandFields []string, commandLineScanner *bufio.Scanner) {
+	if len(commandFields) < 2 {
+		println("Usage: SUBSCRIBE channel [channel...] | PSUBSCRIBE pattern [pattern...]")
+		return
+	}
+
+	var parameter = "channel"
+
+	if strings.ToUpper(commandFields[0]) == "PSUBSCRIBE" {
+		parameter = "pattern"
+	}
+
+	if standalone {
+		if parameter == "pattern" {
+			subscribeLocally(runtime, nil, commandFields[1:], commandLineScanner)
+		} else {
+			subscribeLocally(runtime, commandFields[1:], nil, commandLineScanner)
+		}
+
+		return
+	}
+
+	var query = url.Values{}
+
+	for _, name := range commandFields[1:] {
+		query.Add(parameter, name)
+	}
+
+	streamFromServer("/subscribe?"+query.Encode(), commandLineScanner, printPubsubEvent)
+}
+
+// Number of keys examined by each SCAN call of the bigkeys scan (their TYPE and MEMORY USAGE are sent in one batch).
+const bigKeysScanCount = 100
+
+// runBigKeys scans the server database and reports the largest keys (by approximate memory usage) per type.
+func runBigKeys() {
+	type bigKey struct {
+		key    string
+		memory int64
+	}
+
+	var arcClient = client.Create(client.Options{})
+	var ctx = context.Background()
+
+	var biggest = make(map[string]bigKey)
+	var counts = make(map[string]int)
+	var totals = make(map[string]int64)
+	var scanned = 0
+
+	println("Scanning keys...")
+	println("")
+
+	for cursor := uint64(0); ; {
+		var keys []string
+		var err error
+
+		if cursor, keys, err = arcClient.Scan(ctx, cursor, "*", bigKeysScanCount); err != nil {
+			log.Fatalf("ERROR: %v.", err)
+		}
+
+		var pipeline = arcClient.Pipeline()
+
+		for _, key := range keys {
+			pipeline.Queue("TYPE", key).Queue("MEMORY", "USAGE", key)
+		}
+
+		var results = pipeline.Exec(ctx)
+
+		for index, key := range keys {
+			var typeResult, usageResult = results[2*index], results[2*index+1]
+
+			if (typeResult.Err != nil) || (usageResult.Err != nil) {
+				continue
+			}
+
+			var keyType, _ = vm.Result(typeResult.Result).Text()
+			var size, err = vm.Result(usageResult.Result).Int()
+
+			if (keyType == "none") || (err != nil) {
+				continue
+			}
+
+			scanned++
+			counts[keyType]++
+			totals[keyType] += size
+
+			if size > biggest[keyType].memory {
+				biggest[keyType] = bigKey{key: key, memory: size}
+				fmt.Printf("Biggest %-6s found so far %q with %d bytes\n", keyType, key, size)
+			}
+		}
+
+		if cursor == 0 {
+			break
+		}
+	}
+
+	fmt.Printf("\n%d keys scanned.\n", scanned)
+	println("")
+	println("-------- summary -------")
+	println("")
+
+	var types = make([]string, 0, len(counts))
+
+	for keyType := range counts {
+		types = append(types, keyType)
+	}
+
+	sort.Strings(types)
+
+	for _, keyType := range types {
+		fmt.Printf("Biggest %-6s found %q has %d bytes\n", keyType, biggest[keyType].key, biggest[keyType].memory)
+	}
+
+	println("")
+
+	for _, keyType := range types {
+		fmt.Printf("%d %ss with %d bytes (avg size %.2f)\n", counts[keyType], keyType, totals[keyType], float64(totals[keyType])/float64(counts[keyType]))
+	}
+}
+
+// runBatch reads command lines until END and then executes all of them together (as a single batch request in client
+// mode), printing the result of each command.
+func runBatch(runtime *vm.Runtime, arcClient *client.Client, commandLineScanner *bufio.Scanner) {
+	var commandLines []string
+
+	println("Enter one command per line and END to execute the batch.")
+	print("... ")
+
+	for commandLineScanner.Scan() {
+		var commandLine = strings.TrimSpace(commandLineScanner.Text())
+
+		if strings.ToUpper(commandLine) == "END" {
+			break
+		}
+
+		if commandLine != "" {
+			commandLines = append(commandLines, commandLine)
+		}
+
+		print("... ")
+	}
+
+	var printResult = func(index int, result []string, err error) {
+		var serverError *client.Error
+
+		if errors.As(err, &serverError) {
+			fmt.Printf("%d) %s\n", index+1, serverError.Message)
+		} else if errors.Is(err, client.ErrNil) {
+			fmt.Printf("%d) %s\n", index+1, vm.NilMessage)
+		} else if err != nil {
+			fmt.Printf("%d) ERROR: %v.\n", index+1, err)
+		} else {
+			fmt.Printf("%d) %s\n", index+1, strings.Join(result, " "))
+		}
+	}
+
+	if runtime != nil {
+		for index := range commandLines {
+			var result, err = runtime.ExecuteTyped(vm.ExecutionContext{Client: "local"}, commandLines[index]).Get()
+			printResult(index, result, err)
+		}
+
+		return
+	}
+
+	var pipeline = arcClient.Pipeline()
+
+	for index := range commandLines {
+		var arguments, err = vm.Tokenize(commandLines[index])
+
+		if err != nil {
+			fmt.Printf("Line %d: %v, batch not executed.\n", index+1, err)
+			return
+		}
+
+		pipeline.Queue(arguments...)
+	}
+
+	for index, pipelineResult := range pipeline.Exec(context.Background()) {
+		printResult(index, pipelineResult.Result, pipelineResult.Err)
+	}
 }
 
 func runClient(standalone bool) {
 	var db *database.Database
 	var runtime *vm.Runtime
+	var arcClient *client.Client
 
 	if standalone {
 		db = database.Create()
+		db.StartActiveExpiration()
+		defer db.Close()
 		log.Print("ARC: database created.")
 
 		runtime = vm.CreateRuntime(vm.StandardLibrary, db)
@@ -53,6 +522,9 @@ func runClient(standalone bool) {
 
 		log.Print("ARC: running in standalone mode, type HELP for help and EXIT to exit.")
 	} else {
+		arcClient = client.Create(client.Options{})
+		defer arcClient.Close()
+
 		log.Print("ARC: running in client mode, type HELP for help and EXIT to exit.")
 	}
 
@@ -72,26 +544,47 @@ func runClient(standalone bool) {
 
 		if strings.ToUpper(commandLine) == "HELP" {
 			for index := range vm.StandardLibrary {
-				println(vm.StandardLibrary[index].GetHelp())
+				fmt.Printf("%s - %s\n", vm.StandardLibrary[index].GetHelp(), vm.StandardLibrary[index].GetSummary())
+			}
+		} else if commandFields := strings.Fields(commandLine); (len(commandFields) == 2) && (strings.ToUpper(commandFields[0]) == "HELP") {
+			// The command documentation is taken from where the commands run (the server in client mode).
+
+			if standalone {
+				println(strings.Join(runtime.Execute("COMMAND DOCS "+commandFields[1]), "\n"))
+			} else if result, err := arcClient.Do(context.Background(), "COMMAND", "DOCS", commandFields[1]); err == nil {
+				println(strings.Join(result, "\n"))
+			} else {
+				fmt.Printf("ERROR: %v.\n", err)
 			}
+		} else if strings.ToUpper(strings.TrimSpace(commandLine)) == "BATCH" {
+			runBatch(runtime, arcClient, commandLineScanner)
+		} else if strings.ToUpper(commandLine) == "MONITOR" {
+			if standalone {
+				println("MONITOR is only available in client mode.")
+			} else {
+				streamFromServer("/monitor", commandLineScanner, func(data string) { println(data) })
+			}
+		} else if commandFields := strings.Fields(commandLine); (len(commandFields) > 0) &&
+			((strings.ToUpper(commandFields[0]) == "SUBSCRIBE") || (strings.ToUpper(commandFields[0]) == "PSUBSCRIBE")) {
+			subscribe(standalone, runtime, commandFields, commandLineScanner)
 		} else {
 			if standalone {
 				if result := runtime.Execute(commandLine); result != nil {
 					println(strings.Join(result, " "))
 				}
 			} else {
-				if httpResponse, err := http.Get("http://localhost:8080/?cmd=" + url.QueryEscape(commandLine)); err == nil {
-					var bodyBuffer = new(bytes.Buffer)
+				var result, err = arcClient.Execute(context.Background(), commandLine)
+				var serverError *client.Error
 
-					if bodySize, bodyError := bodyBuffer.ReadFrom(httpResponse.Body); (bodyError == nil) && (bodySize > 0) {
-						println(bodyBuffer.String())
-					}
-
-					httpResponse.Body.Close()
-				} else {
-					fmt.Printf("HTTP ERROR: %v.\n", err)
+				if errors.As(err, &serverError) {
+					println(serverError.Message)
+				} else if errors.Is(err, client.ErrNil) {
+					println(vm.NilMessage)
+				} else if err != nil {
+					fmt.Printf("ERROR: %v.\n", err)
+				} else if len(result) > 0 {
+					println(strings.Join(result, " "))
 				}
-
 			}
 		}
 
@@ -100,17 +593,34 @@ func runClient(standalone bool) {
 }
 
 func main() {
-	if len(os.Args) != 2 {
+	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
 	}
 
+	var options = os.Args[2:]
+
 	switch os.Args[1] {
 	case "client":
-		runClient(false)
+		if (len(options) == 1) && (options[0] == "--bigkeys") {
+			runBigKeys()
+		} else if len(options) == 0 {
+			runClient(false)
+		} else {
+			printUsage()
+			os.Exit(1)
+		}
 
 	case "server":
-		runServer()
+		var serverOptions, err = parseServerOptions(options)
+
+		if err != nil {
+			fmt.Printf("Invalid server options: %v.\n\n", err)
+			printUsage()
+			os.Exit(1)
+		}
+
+		runServer(serverOptions)
 
 	case "standalone":
 		runClient(true)
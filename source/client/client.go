@@ -0,0 +1,217 @@
+// Package client implements a Go client for ARC servers.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"arc/vm"
+)
+
+type (
+	// Options defines the client options, zero values are replaced by the defaults.
+	Options struct {
+		// Address is the server address (host:port), defaults to localhost:8080.
+		Address string
+
+		// Timeout limits the duration of each request (including retries), defaults to 10 seconds.
+		Timeout time.Duration
+
+		// MaxRetries is the number of times a request is retried after a network error or a server failure, defaults
+		// to 3 (use a negative value to disable retries). A retried write command may be executed more than once, so
+		// pipelines (which may hold many write commands) are never retried.
+		MaxRetries int
+
+		// RetryBackoff is the delay before the first retry, doubled on each retry, defaults to 100 milliseconds.
+		RetryBackoff time.Duration
+
+		// MaxConnections is the maximum number of idle (pooled) connections, defaults to 16.
+		MaxConnections int
+
+		// MaxRedirects is the number of cluster redirections (MOVED or ASK) followed by a request, defaults to 5.
+		MaxRedirects int
+	}
+
+	// Client is an ARC client, safe for concurrent use by multiple goroutines.
+	Client struct {
+		options    Options
+		httpClient *http.Client
+	}
+)
+
+// Default client options.
+const (
+	DefaultAddress        = "localhost:8080"
+	DefaultTimeout        = 10 * time.Second
+	DefaultMaxRetries     = 3
+	DefaultRetryBackoff   = 100 * time.Millisecond
+	DefaultMaxConnections = 16
+	DefaultMaxRedirects   = 5
+)
+
+// errRetry marks the failures after which a request can be retried.
+var errRetry = errors.New("client: retryable failure")
+
+// Create creates a new client.
+func Create(options Options) *Client {
+	if options.Address == "" {
+		options.Address = DefaultAddress
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+
+	if options.MaxRetries == 0 {
+		options.MaxRetries = DefaultMaxRetries
+	} else if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = DefaultRetryBackoff
+	}
+
+	if options.MaxConnections <= 0 {
+		options.MaxConnections = DefaultMaxConnections
+	}
+
+	if options.MaxRedirects <= 0 {
+		options.MaxRedirects = DefaultMaxRedirects
+	}
+
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = options.MaxConnections
+	transport.MaxIdleConnsPerHost = options.MaxConnections
+
+	return &Client{
+		options:    options,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+// GetAddress returns the server address.
+func (client *Client) GetAddress() string {
+	return client.options.Address
+}
+
+// Close closes the pooled connections.
+func (client *Client) Close() {
+	client.httpClient.CloseIdleConnections()
+}
+
+// Do executes a command and returns its result, error results are returned as an *Error and nil results as ErrNil.
+func (client *Client) Do(ctx context.Context, arguments ...string) ([]string, error) {
+	return client.Execute(ctx, vm.FormatCommandLine(arguments))
+}
+
+// Execute executes a command line and returns its result, error results are returned as an *Error and nil results as
+// ErrNil.
+func (client *Client) Execute(ctx context.Context, commandLine string) (result []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, client.options.Timeout)
+	defer cancel()
+
+	var address = client.options.Address
+	var asking = false
+	var typedResult vm.TypedResult
+
+	for redirects := 0; ; redirects++ {
+		err = client.withRetries(ctx, func() (err error) {
+			typedResult, err = client.send(ctx, address, commandLine, asking)
+			return
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		var isRedirection bool
+
+		if address, asking, isRedirection = vm.GetRedirection(typedResult.Values); !isRedirection {
+			break
+		}
+
+		if redirects == client.options.MaxRedirects {
+			return nil, fmt.Errorf("client: too many redirections (last to %s)", address)
+		}
+	}
+
+	return typedResult.Get()
+}
+
+// withRetries calls attempt until it succeeds, fails with an error that can't be retried or the retries are exhausted.
+func (client *Client) withRetries(ctx context.Context, attempt func() error) (err error) {
+	var backoff = client.options.RetryBackoff
+
+	for retries := 0; ; retries++ {
+		if err = attempt(); !errors.Is(err, errRetry) || (retries == client.options.MaxRetries) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// send sends a single command line to the server, in the body of a single command batch (so command lines are not
+// limited by the URL length nor logged by proxies as part of it), and returns its typed result.
+func (client *Client) send(ctx context.Context, address string, commandLine string, asking bool) (result vm.TypedResult, err error) {
+	var body, _ = json.Marshal([]string{commandLine})
+	var request, _ = http.NewRequestWithContext(ctx, http.MethodPost, "http://"+address+"/batch?typed=true", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	if asking {
+		request.Header.Set(vm.AskingHeader, "1")
+	}
+
+	var batchResults []vm.TypedResult
+
+	if err = client.roundTrip(ctx, request, &batchResults); err != nil {
+		return
+	}
+
+	if len(batchResults) != 1 {
+		return result, fmt.Errorf("client: unexpected number of batch results: %d", len(batchResults))
+	}
+
+	return batchResults[0], nil
+}
+
+// roundTrip sends a request to the server and decodes its JSON response.
+func (client *Client) roundTrip(ctx context.Context, request *http.Request, value any) error {
+	request.Header.Set("Accept", "application/json")
+
+	var response, err = client.httpClient.Do(request)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("%w: %v", errRetry, err)
+	}
+
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: unexpected response: %s", errRetry, response.Status)
+	case response.StatusCode != http.StatusOK:
+		return fmt.Errorf("client: unexpected response: %s", response.Status)
+	}
+
+	if err = json.NewDecoder(response.Body).Decode(value); err != nil {
+		return fmt.Errorf("client: invalid response: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"arc/database"
+	"arc/server"
+	"arc/vm"
+)
+
+func createTestClient(test *testing.T) *Client {
+	var testDB = database.Create()
+	var testRuntime = vm.CreateRuntime(vm.StandardLibrary, testDB)
+	var testServer = httptest.NewServer(server.Create(":0", testRuntime).Handler())
+
+	test.Cleanup(testDB.Close)
+	test.Cleanup(testServer.Close)
+
+	return Create(Options{Address: strings.TrimPrefix(testServer.URL, "http://")})
+}
+
+func TestCommands(test *testing.T) {
+	var testClient = createTestClient(test)
+	var ctx = context.Background()
+
+	if err := testClient.Set(ctx, "greeting", "hello world"); err != nil {
+		test.Fatal(err)
+	}
+
+	if value, err := testClient.Get(ctx, "greeting"); (err != nil) || (value != "hello world") {
+		test.Fail()
+	}
+
+	if _, err := testClient.Get(ctx, "missing"); err != ErrNil {
+		test.Fail()
+	}
+
+	if value, err := testClient.Incr(ctx, "counter"); (err != nil) || (value != 1) {
+		test.Fail()
+	}
+
+	if added, err := testClient.ZAdd(ctx, "scores", Z{Score: 2, Member: "b"}, Z{Score: 1.5, Member: "a"}); (err != nil) || (added != 2) {
+		test.Fail()
+	}
+
+	if members, err := testClient.ZRange(ctx, "scores", 0, -1); (err != nil) || (strings.Join(members, ",") != "a,b") {
+		test.Fail()
+	}
+
+	if _, err := testClient.Incr(ctx, "scores"); !errors.Is(err, ErrInvalidDataType) {
+		test.Fail()
+	}
+
+	if _, err := testClient.Do(ctx, "NOPE"); !errors.Is(err, ErrUnknownCommand) {
+		test.Fail()
+	}
+}
+
+func TestSetEx(test *testing.T) {
+	var testClient = createTestClient(test)
+	var ctx = context.Background()
+
+	for _, expiration := range []time.Duration{0, -time.Second, 500 * time.Microsecond} {
+		if err := testClient.SetEx(ctx, "invalid", "value", expiration); !errors.Is(err, ErrInvalidExpiration) {
+			test.Errorf("SetEx with a %v expiration = %v", expiration, err)
+		}
+	}
+
+	if err := testClient.SetEx(ctx, "session", "value", 1500*time.Millisecond); err != nil {
+		test.Fatal(err)
+	}
+
+	if result, err := testClient.Do(ctx, "PTTL", "session"); (err != nil) || (len(result) != 1) {
+		test.Errorf("PTTL = %q %v", result, err)
+	} else if ttl, _ := strconv.ParseInt(result[0], 10, 64); (ttl <= 1000) || (ttl > 1500) {
+		test.Errorf("PTTL of a 1.5s expiration = %d", ttl)
+	}
+
+	if err := testClient.SetEx(ctx, "short", "value", 100*time.Millisecond); err != nil {
+		test.Fatal(err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if _, err := testClient.Get(ctx, "short"); err != ErrNil {
+		test.Errorf("Get of a key expired after 100ms = %v", err)
+	}
+}
+
+func TestScan(test *testing.T) {
+	var testClient = createTestClient(test)
+	var ctx = context.Background()
+	var keys = make(map[string]bool)
+
+	for index := 0; index < 25; index++ {
+		testClient.Set(ctx, "scan:"+strconv.Itoa(index), "value")
+	}
+
+	testClient.Set(ctx, "other", "value")
+
+	for cursor := uint64(0); ; {
+		var found []string
+		var err error
+
+		if cursor, found, err = testClient.Scan(ctx, cursor, "scan:*", 10); err != nil {
+			test.Fatal(err)
+		}
+
+		for _, key := range found {
+			keys[key] = true
+		}
+
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if (len(keys) != 25) || keys["other"] {
+		test.Errorf("scanned %d keys", len(keys))
+	}
+}
+
+func TestPipeline(test *testing.T) {
+	var testClient = createTestClient(test)
+	var pipeline = testClient.Pipeline()
+
+	for index := 0; index < 100; index++ {
+		pipeline.Queue("INCR", "counter")
+	}
+
+	for _, result := range pipeline.Exec(context.Background()) {
+		if result.Err != nil {
+			test.Fail()
+		}
+	}
+
+	if value, err := testClient.Get(context.Background(), "counter"); (err != nil) || (value != "100") || (pipeline.Len() != 0) {
+		test.Fail()
+	}
+
+	var results = pipeline.Queue("SET", "key", "hello world").Queue("GET", "key").Queue("INCR", "key").Exec(context.Background())
+
+	if (len(results) != 3) || (results[0].Err != nil) || (results[1].Result[0] != "hello world") ||
+		!errors.Is(results[2].Err, ErrInvalidDataType) {
+		test.Fail()
+	}
+}
+
+func TestTypedResults(test *testing.T) {
+	var testClient = createTestClient(test)
+	var ctx = context.Background()
+
+	for _, value := range []string{vm.NilMessage, "Error: not an error"} {
+		if err := testClient.Set(ctx, "key", value); err != nil {
+			test.Fatal(err)
+		}
+
+		if result, err := testClient.Get(ctx, "key"); (err != nil) || (result != value) {
+			test.Errorf("Get = %q, %v, expected %q", result, err, value)
+		}
+
+		var results = testClient.Pipeline().Queue("GET", "key").Queue("GET", "missing").Exec(ctx)
+
+		if (results[0].Err != nil) || (strings.Join(results[0].Result, " ") != value) || (results[1].Err != ErrNil) {
+			test.Errorf("pipeline results = %+v", results)
+		}
+	}
+
+	if _, err := testClient.Execute(ctx, "GET missing"); err != ErrNil {
+		test.Errorf("Execute of a missing key = %v", err)
+	}
+}
+
+func TestRetriesAndCancellation(test *testing.T) {
+	var requests atomic.Int32
+
+	var testServer = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var body, _ = io.ReadAll(request.Body)
+
+		if (request.Method != http.MethodPost) || (request.URL.Path != "/batch") || !strings.Contains(string(body), "SET") {
+			response.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if requests.Add(1)%3 != 0 {
+			response.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		response.Write([]byte(`[{"type": "values", "values": ["OK"]}]`))
+	}))
+
+	defer testServer.Close()
+
+	var testClient = Create(Options{Address: strings.TrimPrefix(testServer.URL, "http://"), RetryBackoff: time.Millisecond})
+
+	if err := testClient.Set(context.Background(), "key", "value"); (err != nil) || (requests.Load() != 3) {
+		test.Fail()
+	}
+
+	// Pipelines are sent once, even after a server failure.
+
+	if results := testClient.Pipeline().Queue("SET", "key", "value").Exec(context.Background()); (results[0].Err == nil) || (requests.Load() != 4) {
+		test.Errorf("pipeline results = %+v after %d requests", results, requests.Load())
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if err := testClient.Set(ctx, "key", "value"); !errors.Is(err, context.Canceled) {
+		test.Fail()
+	}
+}
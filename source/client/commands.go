@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"arc/vm"
+)
+
+// Z is a sorted set member and its score.
+type Z struct {
+	Score  float64
+	Member string
+}
+
+// getString returns the single value of a result, nil results are returned as ErrNil.
+func getString(result []string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+
+	return vm.Result(result).Text()
+}
+
+// getInt returns the single integer value of a result, nil results are returned as ErrNil.
+func getInt(result []string, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+
+	return vm.Result(result).Int()
+}
+
+// getOK checks that the result of a command is OK.
+func getOK(result []string, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return vm.Result(result).OK()
+}
+
+// Set sets a key value (SET key value).
+func (client *Client) Set(ctx context.Context, key string, value string) error {
+	return getOK(client.Do(ctx, "SET", key, value))
+}
+
+// SetEx sets a key value that expires after a duration, with a millisecond resolution (SET key value PX
+// milliseconds). Expirations shorter than a millisecond return ErrInvalidExpiration.
+func (client *Client) SetEx(ctx context.Context, key string, value string, expiration time.Duration) error {
+	if expiration < time.Millisecond {
+		return ErrInvalidExpiration
+	}
+
+	return getOK(client.Do(ctx, "SET", key, value, "PX", strconv.FormatInt(expiration.Milliseconds(), 10)))
+}
+
+// Get returns a key value, or ErrNil if the key does not exist (GET key).
+func (client *Client) Get(ctx context.Context, key string) (string, error) {
+	return getString(client.Do(ctx, "GET", key))
+}
+
+// Del removes keys and returns the number of keys removed (DEL key [key...]).
+func (client *Client) Del(ctx context.Context, keys ...string) (int64, error) {
+	return getInt(client.Do(ctx, append([]string{"DEL"}, keys...)...))
+}
+
+// Rename renames a key (RENAME key newkey).
+func (client *Client) Rename(ctx context.Context, key string, newKey string) error {
+	return getOK(client.Do(ctx, "RENAME", key, newKey))
+}
+
+// DBSize returns the number of keys (DBSIZE).
+func (client *Client) DBSize(ctx context.Context) (int64, error) {
+	return getInt(client.Do(ctx, "DBSIZE"))
+}
+
+// Incr increments a key value and returns the new value (INCR key).
+func (client *Client) Incr(ctx context.Context, key string) (int64, error) {
+	return getInt(client.Do(ctx, "INCR", key))
+}
+
+// ZAdd adds members to a sorted set and returns the number of new members (ZADD key score member [score member...]).
+func (client *Client) ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	var arguments = make([]string, 0, 2+(2*len(members)))
+	arguments = append(arguments, "ZADD", key)
+
+	for index := range members {
+		arguments = append(arguments, strconv.FormatFloat(members[index].Score, 'f', -1, 64), members[index].Member)
+	}
+
+	return getInt(client.Do(ctx, arguments...))
+}
+
+// ZCard returns the number of members of a sorted set (ZCARD key).
+func (client *Client) ZCard(ctx context.Context, key string) (int64, error) {
+	return getInt(client.Do(ctx, "ZCARD", key))
+}
+
+// ZRank returns the rank of a sorted set member, or ErrNil if it does not exist (ZRANK key member).
+func (client *Client) ZRank(ctx context.Context, key string, member string) (int64, error) {
+	return getInt(client.Do(ctx, "ZRANK", key, member))
+}
+
+// ZRange returns the sorted set members from start to stop (ZRANGE key start stop).
+func (client *Client) ZRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return client.Do(ctx, "ZRANGE", key, strconv.FormatInt(start, 10), strconv.FormatInt(stop, 10))
+}
+
+// Keys returns the keys matching a glob pattern (KEYS pattern).
+func (client *Client) Keys(ctx context.Context, pattern string) ([]string, error) {
+	return client.Do(ctx, "KEYS", pattern)
+}
+
+// Scan returns a batch of the keys matching a glob-style pattern, examining about count keys from a cursor (zero to
+// start), and the cursor of the next call (zero once the scan is complete) (SCAN cursor MATCH pattern COUNT count).
+func (client *Client) Scan(ctx context.Context, cursor uint64, pattern string, count int) (next uint64, keys []string, err error) {
+	var result []string
+
+	if result, err = client.Do(ctx, "SCAN", strconv.FormatUint(cursor, 10), "MATCH", pattern, "COUNT", strconv.Itoa(count)); err != nil {
+		return
+	}
+
+	if len(result) == 0 {
+		return 0, nil, fmt.Errorf("client: unexpected SCAN result: %q", result)
+	}
+
+	if next, err = strconv.ParseUint(result[0], 10, 64); err != nil {
+		return 0, nil, fmt.Errorf("client: unexpected SCAN cursor: %q", result[0])
+	}
+
+	return next, result[1:], nil
+}
+
+// Type returns the type of a key value (TYPE key).
+func (client *Client) Type(ctx context.Context, key string) (string, error) {
+	return getString(client.Do(ctx, "TYPE", key))
+}
+
+// MemoryUsage returns the approximate memory used by a key, or ErrNil if it does not exist (MEMORY USAGE key).
+func (client *Client) MemoryUsage(ctx context.Context, key string) (int64, error) {
+	return getInt(client.Do(ctx, "MEMORY", "USAGE", key))
+}
+
+// Publish publishes a message and returns the number of subscribers that received it (PUBLISH channel message).
+func (client *Client) Publish(ctx context.Context, channel string, message string) (int64, error) {
+	return getInt(client.Do(ctx, "PUBLISH", channel, message))
+}
+
+// Info returns the server information, all the sections are returned for an empty section (INFO [section]).
+func (client *Client) Info(ctx context.Context, section string) (string, error) {
+	if section == "" {
+		return getString(client.Do(ctx, "INFO"))
+	}
+
+	return getString(client.Do(ctx, "INFO", section))
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"errors"
+
+	"arc/vm"
+)
+
+// Error is an error result returned by the server, the same type returned by the embedded runtime.
+type Error = vm.Error
+
+// GenericErrorKind is the kind of the server errors without a specific kind.
+const GenericErrorKind = vm.GenericErrorKind
+
+// ErrNil is returned when a key or member does not exist.
+var ErrNil = vm.ErrNil
+
+// ErrInvalidExpiration is returned for expirations shorter than a millisecond, without sending the command.
+var ErrInvalidExpiration = errors.New("client: invalid expiration, at least 1ms expected")
+
+// Server errors, to be compared using errors.Is.
+var (
+	ErrUnknownCommand        = vm.ErrUnknownCommand
+	ErrInvalidCommandLine    = vm.ErrInvalidCommandLine
+	ErrInvalidParameters     = vm.ErrInvalidParameters
+	ErrInvalidParameterValue = vm.ErrInvalidParameterValue
+	ErrInvalidDataType       = vm.ErrInvalidDataType
+	ErrStreamingOnly         = vm.ErrStreamingOnly
+	ErrNoSuchKey             = vm.ErrNoSuchKey
+	ErrOutOfMemory           = vm.ErrOutOfMemory
+	ErrReadOnlyReplica       = vm.ErrReadOnlyReplica
+	ErrCrossSlot             = vm.ErrCrossSlot
+	ErrClusterDown           = vm.ErrClusterDown
+	ErrSyntax                = vm.ErrSyntax
+	ErrReadOnly              = vm.ErrReadOnly
+	ErrTimeout               = vm.ErrTimeout
+	ErrCancelled             = vm.ErrCancelled
+	ErrPreconditionFailed    = vm.ErrPreconditionFailed
+	ErrArgumentLimit         = vm.ErrArgumentLimit
+	ErrRateLimited           = vm.ErrRateLimited
+)
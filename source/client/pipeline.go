@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"arc/vm"
+)
+
+type (
+	// Pipeline queues commands to be sent together in a single batch request, so many small commands need a single
+	// round trip. The commands are executed in queue order, but not atomically.
+	Pipeline struct {
+		client   *Client
+		commands [][]string
+	}
+
+	// PipelineResult is the result of a pipelined command, error results are returned as an *Error and nil results
+	// as ErrNil.
+	PipelineResult struct {
+		Result []string
+		Err    error
+	}
+)
+
+// Pipeline creates a new empty pipeline.
+func (client *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: client}
+}
+
+// Queue adds a command to the pipeline.
+func (pipeline *Pipeline) Queue(arguments ...string) *Pipeline {
+	pipeline.commands = append(pipeline.commands, arguments)
+	return pipeline
+}
+
+// Len returns the number of queued commands.
+func (pipeline *Pipeline) Len() int {
+	return len(pipeline.commands)
+}
+
+// Exec sends the queued commands and returns their results (in queue order), the pipeline is emptied. Commands
+// redirected to other cluster nodes are then sent one by one. Pipelines are not retried (see Options.MaxRetries).
+func (pipeline *Pipeline) Exec(ctx context.Context) []PipelineResult {
+	var client = pipeline.client
+	var commands = pipeline.commands
+	var results = make([]PipelineResult, len(commands))
+
+	pipeline.commands = nil
+
+	if len(commands) == 0 {
+		return results
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.options.Timeout)
+	defer cancel()
+
+	var body, _ = json.Marshal(commands)
+	var batchResults []vm.TypedResult
+
+	// The batch is sent once: a failed batch may have been executed (in part), and retrying it would execute its write
+	// commands again.
+
+	var request, _ = http.NewRequestWithContext(ctx, http.MethodPost, "http://"+client.options.Address+"/batch?typed=true", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	var err = client.roundTrip(ctx, request, &batchResults)
+
+	if (err == nil) && (len(batchResults) != len(commands)) {
+		err = fmt.Errorf("client: unexpected number of batch results: %d", len(batchResults))
+	}
+
+	for index := range results {
+		switch {
+		case err != nil:
+			results[index].Err = err
+		case isRedirection(batchResults[index].Values):
+			results[index].Result, results[index].Err = client.Do(ctx, commands[index]...)
+		default:
+			results[index].Result, results[index].Err = batchResults[index].Get()
+		}
+	}
+
+	return results
+}
+
+func isRedirection(result []string) bool {
+	var _, _, isRedirection = vm.GetRedirection(result)
+	return isRedirection
+}
@@ -0,0 +1,343 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Slot migration states.
+const (
+	Stable = iota
+	Migrating
+	Importing
+)
+
+// Errors returned by the cluster state operations.
+var (
+	ErrInvalidSlot     = errors.New("invalid hash slot")
+	ErrSlotAssigned    = errors.New("hash slot already assigned")
+	ErrUnknownNode     = errors.New("unknown node")
+	ErrSlotNotOwned    = errors.New("hash slot not owned by this node")
+	ErrSlotOwnedByNode = errors.New("hash slot already owned by this node")
+)
+
+type (
+	// Node represents a cluster node.
+	Node struct {
+		ID      string
+		Address string
+	}
+
+	// Route describes how a key must be handled by this node.
+	Route struct {
+		Slot     int
+		Local    bool
+		Asking   bool
+		Redirect bool
+		Address  string
+	}
+
+	// SlotRange represents a range of consecutive slots owned by the same node.
+	SlotRange struct {
+		Start int
+		End   int
+		Node  Node
+	}
+
+	// State holds the cluster topology as seen by this node.
+	State struct {
+		mutex     sync.RWMutex
+		myself    *Node
+		nodes     map[string]*Node
+		slots     [NumberOfSlots]*Node
+		migrating map[int]*Node
+		importing map[int]*Node
+	}
+)
+
+// CreateState creates a new cluster state, with a single node (this one) and no slots assigned.
+func CreateState() (state *State) {
+	var idBytes = make([]byte, 20)
+	rand.Read(idBytes)
+
+	state = &State{
+		myself:    &Node{ID: hex.EncodeToString(idBytes)},
+		nodes:     make(map[string]*Node),
+		migrating: make(map[int]*Node),
+		importing: make(map[int]*Node),
+	}
+
+	state.nodes[state.myself.ID] = state.myself
+	return
+}
+
+// GetMyself returns this node.
+func (state *State) GetMyself() Node {
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+
+	return *state.myself
+}
+
+// SetMyAddress sets the address announced for this node.
+func (state *State) SetMyAddress(address string) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.myself.Address = address
+}
+
+// Meet adds (or updates) a node.
+func (state *State) Meet(id string, address string) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if node, exists := state.nodes[id]; exists {
+		node.Address = address
+	} else {
+		state.nodes[id] = &Node{ID: id, Address: address}
+	}
+}
+
+// Forget removes a node and the slots assigned to it.
+func (state *State) Forget(id string) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	var node, exists = state.nodes[id]
+
+	if !exists || (node == state.myself) {
+		return ErrUnknownNode
+	}
+
+	for slot := range state.slots {
+		if state.slots[slot] == node {
+			state.slots[slot] = nil
+		}
+	}
+
+	delete(state.nodes, id)
+	return nil
+}
+
+// AddSlots assigns unassigned slots to this node.
+func (state *State) AddSlots(slots []int) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	for _, slot := range slots {
+		if (slot < 0) || (slot >= NumberOfSlots) {
+			return ErrInvalidSlot
+		}
+
+		if state.slots[slot] != nil {
+			return fmt.Errorf("%w: %d", ErrSlotAssigned, slot)
+		}
+	}
+
+	for _, slot := range slots {
+		state.slots[slot] = state.myself
+	}
+
+	return nil
+}
+
+// DeleteSlots unassigns slots.
+func (state *State) DeleteSlots(slots []int) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	for _, slot := range slots {
+		if (slot < 0) || (slot >= NumberOfSlots) {
+			return ErrInvalidSlot
+		}
+	}
+
+	for _, slot := range slots {
+		state.slots[slot] = nil
+		delete(state.migrating, slot)
+		delete(state.importing, slot)
+	}
+
+	return nil
+}
+
+// SetSlot changes a slot migration state or owner: Migrating (to a node), Importing (from a node) or Stable (the
+// node is ignored). With setOwner, the slot is assigned to the node and its migration state is cleared.
+func (state *State) SetSlot(slot int, migrationState int, nodeID string, setOwner bool) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if (slot < 0) || (slot >= NumberOfSlots) {
+		return ErrInvalidSlot
+	}
+
+	var node, exists = state.nodes[nodeID]
+
+	if !exists && (setOwner || (migrationState != Stable)) {
+		return ErrUnknownNode
+	}
+
+	if setOwner {
+		state.slots[slot] = node
+		delete(state.migrating, slot)
+		delete(state.importing, slot)
+		return nil
+	}
+
+	switch migrationState {
+	case Migrating:
+		if state.slots[slot] != state.myself {
+			return ErrSlotNotOwned
+		}
+
+		state.migrating[slot] = node
+	case Importing:
+		if state.slots[slot] == state.myself {
+			return ErrSlotOwnedByNode
+		}
+
+		state.importing[slot] = node
+	default:
+		delete(state.migrating, slot)
+		delete(state.importing, slot)
+	}
+
+	return nil
+}
+
+// GetRoute returns how a key slot must be handled by this node. The exists function is only called for slots being
+// migrated, to check if the key is still stored locally, and asking tells if the client was redirected by an ASK.
+func (state *State) GetRoute(slot int, exists func() bool, asking bool) (route Route) {
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+
+	route.Slot = slot
+
+	var owner = state.slots[slot]
+
+	switch {
+	case owner == state.myself:
+		if target, migrating := state.migrating[slot]; migrating && !exists() {
+			route.Asking = true
+			route.Address = target.Address
+		} else {
+			route.Local = true
+		}
+	case asking && (state.importing[slot] != nil):
+		route.Local = true
+	case owner != nil:
+		route.Redirect = true
+		route.Address = owner.Address
+	}
+
+	return
+}
+
+// CountSlots returns the number of assigned slots.
+func (state *State) CountSlots() (count int) {
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+
+	for slot := range state.slots {
+		if state.slots[slot] != nil {
+			count++
+		}
+	}
+
+	return
+}
+
+// GetNodes returns all the known nodes (sorted by ID).
+func (state *State) GetNodes() (nodes []Node) {
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+
+	for _, node := range state.getSortedNodes() {
+		nodes = append(nodes, *node)
+	}
+
+	return
+}
+
+// GetSlotRanges returns the ranges of consecutive slots assigned to the same node.
+func (state *State) GetSlotRanges() (ranges []SlotRange) {
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+
+	for slot := 0; slot < NumberOfSlots; slot++ {
+		if state.slots[slot] == nil {
+			continue
+		}
+
+		if (len(ranges) > 0) && (ranges[len(ranges)-1].End == slot-1) && (ranges[len(ranges)-1].Node.ID == state.slots[slot].ID) {
+			ranges[len(ranges)-1].End = slot
+		} else {
+			ranges = append(ranges, SlotRange{Start: slot, End: slot, Node: *state.slots[slot]})
+		}
+	}
+
+	return
+}
+
+// Describe returns the CLUSTER NODES like description of every node (one line per node).
+func (state *State) Describe() (lines []string) {
+	var ranges = state.GetSlotRanges()
+
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+
+	for _, node := range state.getSortedNodes() {
+		var fields = []string{node.ID, node.Address}
+
+		if node == state.myself {
+			fields = append(fields, "myself")
+		} else {
+			fields = append(fields, "node")
+		}
+
+		for index := range ranges {
+			if ranges[index].Node.ID != node.ID {
+				continue
+			}
+
+			if ranges[index].Start == ranges[index].End {
+				fields = append(fields, fmt.Sprint(ranges[index].Start))
+			} else {
+				fields = append(fields, fmt.Sprintf("%d-%d", ranges[index].Start, ranges[index].End))
+			}
+		}
+
+		if node == state.myself {
+			for slot, target := range state.migrating {
+				fields = append(fields, fmt.Sprintf("[%d->-%s]", slot, target.ID))
+			}
+
+			for slot, source := range state.importing {
+				fields = append(fields, fmt.Sprintf("[%d-<-%s]", slot, source.ID))
+			}
+		}
+
+		lines = append(lines, strings.Join(fields, " "))
+	}
+
+	return
+}
+
+// getSortedNodes returns the known nodes sorted by ID (the caller must hold the state lock).
+func (state *State) getSortedNodes() (nodes []*Node) {
+	for _, node := range state.nodes {
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(index1, index2 int) bool {
+		return nodes[index1].ID < nodes[index2].ID
+	})
+
+	return
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"testing"
+)
+
+func TestKeySlot(test *testing.T) {
+	if KeySlot("123456789") != 12739 {
+		test.Fail()
+	}
+
+	if (KeySlot("{user1000}.following") != KeySlot("{user1000}.followers")) || (KeySlot("{user1000}.following") != KeySlot("user1000")) {
+		test.Fail()
+	}
+
+	if KeySlot("foo{}{bar}") != KeySlot("foo{}{bar}") || (KeySlot("foo{}{bar}") == KeySlot("bar")) {
+		test.Fail()
+	}
+}
+
+func TestRoutes(test *testing.T) {
+	var testState = CreateState()
+	var exists = func() bool { return false }
+
+	testState.SetMyAddress("127.0.0.1:8080")
+	testState.Meet("other", "127.0.0.1:8081")
+
+	if testState.AddSlots([]int{0, 1}) != nil {
+		test.Fail()
+	}
+
+	if testState.SetSlot(2, Stable, "other", true) != nil {
+		test.Fail()
+	}
+
+	if route := testState.GetRoute(0, exists, false); !route.Local {
+		test.Fail()
+	}
+
+	if route := testState.GetRoute(2, exists, false); !route.Redirect || (route.Address != "127.0.0.1:8081") {
+		test.Fail()
+	}
+
+	if testState.SetSlot(1, Migrating, "other", false) != nil {
+		test.Fail()
+	}
+
+	if route := testState.GetRoute(1, exists, false); !route.Asking || (route.Address != "127.0.0.1:8081") {
+		test.Fail()
+	}
+
+	if route := testState.GetRoute(3, exists, false); route.Local || route.Redirect || route.Asking {
+		test.Fail()
+	}
+}
+
+func TestKeyIndex(test *testing.T) {
+	var testIndex = CreateKeyIndex()
+	var slot = KeySlot("user")
+
+	for _, key := range []string{"{user}.name", "{user}.email", "{user}.age", "other"} {
+		testIndex.Add(key)
+	}
+
+	testIndex.Add("{user}.name")
+	testIndex.Remove("{user}.age")
+	testIndex.Remove("missing")
+
+	if testIndex.Count(slot) != 2 {
+		test.Errorf("Count = %d", testIndex.Count(slot))
+	}
+
+	if keys := testIndex.GetKeys(slot, -1); (len(keys) != 2) || (keys[0] != "{user}.email") || (keys[1] != "{user}.name") {
+		test.Errorf("GetKeys = %q", keys)
+	}
+
+	if keys := testIndex.GetKeys(slot, 1); (len(keys) != 1) || (keys[0] != "{user}.email") {
+		test.Errorf("GetKeys with count = %q", keys)
+	}
+
+	testIndex.Remove("{user}.email")
+	testIndex.Remove("{user}.name")
+
+	if (testIndex.Count(slot) != 0) || (len(testIndex.GetKeys(slot, -1)) != 0) {
+		test.Error("the slot is not empty once its keys are removed")
+	}
+}
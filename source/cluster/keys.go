@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"sort"
+	"sync"
+)
+
+// KeyIndex keeps the keys of each hash slot, so the keys of a slot are found without scanning the whole keyspace.
+type KeyIndex struct {
+	mutex sync.RWMutex
+	slots [NumberOfSlots]map[string]struct{}
+}
+
+// CreateKeyIndex creates a new empty key index.
+func CreateKeyIndex() *KeyIndex {
+	return &KeyIndex{}
+}
+
+// Add adds a key to the index of its slot.
+func (index *KeyIndex) Add(key string) {
+	var slot = KeySlot(key)
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	if index.slots[slot] == nil {
+		index.slots[slot] = make(map[string]struct{})
+	}
+
+	index.slots[slot][key] = struct{}{}
+}
+
+// Remove removes a key from the index of its slot.
+func (index *KeyIndex) Remove(key string) {
+	var slot = KeySlot(key)
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	delete(index.slots[slot], key)
+
+	if len(index.slots[slot]) == 0 {
+		index.slots[slot] = nil
+	}
+}
+
+// Count returns the number of keys in a slot.
+func (index *KeyIndex) Count(slot int) int {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return len(index.slots[slot])
+}
+
+// GetKeys returns up to count keys (all of them if count is negative) of a slot, in lexicographic order.
+func (index *KeyIndex) GetKeys(slot int, count int) (keys []string) {
+	index.mutex.RLock()
+	keys = make([]string, 0, len(index.slots[slot]))
+
+	for key := range index.slots[slot] {
+		keys = append(keys, key)
+	}
+
+	index.mutex.RUnlock()
+
+	sort.Strings(keys)
+
+	if (count >= 0) && (count < len(keys)) {
+		keys = keys[:count]
+	}
+
+	return
+}
@@ -0,0 +1,47 @@
+package cluster
+
+import (
+	"strings"
+)
+
+// NumberOfSlots defines the number of hash slots the keyspace is split into.
+const NumberOfSlots = 16384
+
+// crc16Table holds the CRC16 (XMODEM) lookup table used to hash keys.
+var crc16Table = func() (table [256]uint16) {
+	for index := range table {
+		var crc = uint16(index) << 8
+
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+
+		table[index] = crc
+	}
+
+	return
+}()
+
+func crc16(data string) (crc uint16) {
+	for index := 0; index < len(data); index++ {
+		crc = (crc << 8) ^ crc16Table[byte(crc>>8)^data[index]]
+	}
+
+	return
+}
+
+// KeySlot returns the hash slot of a key. If the key contains a non empty {hashtag}, only the hashtag is hashed, so
+// related keys can be forced into the same slot.
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key)) % NumberOfSlots
+}
@@ -1,24 +1,86 @@
 package database
 
 import (
+	"context"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
+
+	"arc/glob"
 )
 
 type (
 	// Database defines a database object.
 	Database struct {
-		mutex sync.RWMutex
-		data  map[string]*Value
+		mutex       sync.RWMutex
+		data        map[string]*Value
+		keys        *keySet
+		expires     *keySet
+		listeners   []Listener
+		expiredKeys int64
+		evictedKeys int64
+		usedMemory  int64
+		version     uint64
+		maxMemory   int64
+		policy      int
+		samples     int
+		writeLock   sync.Locker
+		done        chan struct{}
+		closeOnce   sync.Once
+		expireOnce  sync.Once
+	}
+
+	// KeyInformation holds introspection information about a single key.
+	KeyInformation struct {
+		Type      int
+		Encoding  string
+		Length    int
+		Memory    int64
+		IdleTime  time.Duration
+		Frequency uint32
+		Expires   int64 // Unix milliseconds
+	}
+
+	// SnapshotEntry holds a copy of a single key value.
+	SnapshotEntry struct {
+		Key     string
+		Type    int
+		Value   string
+		Entries []*SortedSetEntry
+		Expires int64 // Unix milliseconds
+	}
+
+	// Statistics holds a snapshot of the database key counters.
+	Statistics struct {
+		SingleValues int64
+		SortedSets   int64
+		Expires      int64
+		ExpiredKeys  int64
+		EvictedKeys  int64
+		Memory       int64
 	}
 )
 
-// Create creates a new database object.
-func Create() *Database {
-	return &Database{
-		data: make(map[string]*Value),
+// Number of keys scanned between cancellation checks.
+const scanCheckInterval = 1024
+
+// Create creates a new database object. Expired keys are removed when accessed, StartActiveExpiration also removes
+// them in the background.
+func Create() (db *Database) {
+	db = &Database{
+		data:    make(map[string]*Value),
+		keys:    createKeySet(),
+		expires: createKeySet(),
+		policy:  NoEviction,
+		samples: defaultEvictionSamples,
+		done:    make(chan struct{}),
+
+		// Versions start at the creation time, so versions from a previous run are never reused.
+		version: uint64(time.Now().UnixNano()),
 	}
+
+	return
 }
 
 // Get returns a value from the database.
@@ -30,7 +92,8 @@ func (db *Database) Get(key string) *Value {
 		value.mutex.RLock()
 		defer value.mutex.RUnlock()
 
-		if (value.expireTime > time.Now().Unix()) || (value.expireTime == 0) {
+		if (value.expireTime > time.Now().UnixMilli()) || (value.expireTime == 0) {
+			value.touch()
 			return value
 		}
 	}
@@ -38,21 +101,24 @@ func (db *Database) Get(key string) *Value {
 	return nil
 }
 
-// GetSingleValue returns a single value from the database.
-func (db *Database) GetSingleValue(key string) string {
+// GetSingleValue returns a single value from the database, exists is false when the key does not exist and ok is false
+// when the key holds another type of value.
+func (db *Database) GetSingleValue(key string) (data string, exists bool, ok bool) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	if value, exists := db.data[key]; exists {
+	if value, found := db.data[key]; found {
 		value.mutex.RLock()
 		defer value.mutex.RUnlock()
 
-		if (value.dataType == SingleValue) && (value.expireTime > time.Now().Unix()) || (value.expireTime == 0) {
-			return value.data.(string)
+		if !value.isExpired(time.Now().UnixMilli()) {
+			value.touch()
+			data, ok = value.data.(string)
+			return data, true, ok
 		}
 	}
 
-	return ""
+	return "", false, true
 }
 
 // GetSortedSet returns a sorted set value from the database.
@@ -64,7 +130,8 @@ func (db *Database) GetSortedSet(key string) *SortedSet {
 		value.mutex.RLock()
 		defer value.mutex.RUnlock()
 
-		if (value.dataType == SortedSetValue) && (value.expireTime > time.Now().Unix()) || (value.expireTime == 0) {
+		if (value.dataType == SortedSetValue) && !value.isExpired(time.Now().UnixMilli()) {
+			value.touch()
 			return value.data.(*SortedSet)
 		}
 	}
@@ -77,43 +144,126 @@ func (db *Database) Set(key string, value *Value) {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	if value, exists := db.data[key]; exists {
-		db.data[key].Set(value.dataType, value.data, value.expireTime)
+	db.expireIfNeeded(key)
+
+	var dataType, expires = value.GetInformation()
+
+	if current, exists := db.data[key]; exists {
+		current.Set(dataType, value.Get(), expires)
+		value = current
 	} else {
-		db.data[key] = value
+		db.store(key, value)
 	}
+
+	value.touch()
+	db.updateVersion(value)
+	db.updateSize(key, value)
+	db.trackExpire(key, expires)
+	db.notify(GenericEvents, SetEvent, key)
 }
 
-// SetSingleValue sets a database value as a single value.
+// SetSingleValue sets a database value as a single value, expires is its expire time in Unix milliseconds (zero when
+// it never expires).
 func (db *Database) SetSingleValue(key string, data string, expires int64) {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	if value, exists := db.data[key]; exists {
+	db.expireIfNeeded(key)
+
+	var value, exists = db.data[key]
+
+	if exists {
 		value.Set(SingleValue, data, expires)
 	} else {
-		db.data[key] = &Value{
+		value = &Value{
 			dataType:   SingleValue,
 			expireTime: expires,
 			data:       data,
 		}
+
+		db.store(key, value)
 	}
+
+	value.touch()
+	db.updateVersion(value)
+	db.updateSize(key, value)
+	db.trackExpire(key, expires)
+	db.notify(StringEvents, SetEvent, key)
 }
 
-// SetSortedSet sets a database value as a sorted set.
+// SetSortedSet sets a database value as a sorted set, expires is its expire time in Unix milliseconds (zero when it
+// never expires).
 func (db *Database) SetSortedSet(key string, set *SortedSet, expires int64) {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	if value, exists := db.data[key]; exists {
+	db.expireIfNeeded(key)
+
+	var value, exists = db.data[key]
+
+	if exists {
 		value.Set(SortedSetValue, set, expires)
 	} else {
-		db.data[key] = &Value{
+		value = &Value{
 			dataType:   SortedSetValue,
 			expireTime: expires,
 			data:       set,
 		}
+
+		db.store(key, value)
+	}
+
+	value.touch()
+	db.updateVersion(value)
+	db.updateSize(key, value)
+	db.trackExpire(key, expires)
+	db.notify(GenericEvents, SetEvent, key)
+}
+
+// AddSortedSetEntries adds entries to a sorted set value (creating it if needed) and returns the number of new members.
+func (db *Database) AddSortedSetEntries(key string, entries []*SortedSetEntry) (added int64, ok bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.expireIfNeeded(key)
+
+	var set *SortedSet
+	var value, exists = db.data[key]
+
+	if exists {
+		value.mutex.RLock()
+
+		if value.dataType != SortedSetValue {
+			value.mutex.RUnlock()
+			return 0, false
+		}
+
+		set = value.data.(*SortedSet)
+		value.mutex.RUnlock()
+	} else {
+		set = CreateSortedSet()
+
+		value = &Value{
+			dataType: SortedSetValue,
+			data:     set,
+		}
+
+		db.store(key, value)
+		db.updateSize(key, value)
 	}
+
+	for index := range entries {
+		if set.AddEntry(entries[index]) {
+			db.resize(value, int64(len(entries[index].member))+setEntryOverhead)
+			added++
+		}
+	}
+
+	value.touch()
+	db.updateVersion(value)
+
+	db.notify(SortedSetEvents, ZaddEvent, key)
+	return added, true
 }
 
 // IncrementSingleValue increments an integer single value.
@@ -121,6 +271,8 @@ func (db *Database) IncrementSingleValue(key string) (newValue int64, ok bool) {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
+	db.expireIfNeeded(key)
+
 	var value *Value
 	var exists bool
 
@@ -140,7 +292,8 @@ func (db *Database) IncrementSingleValue(key string) (newValue int64, ok bool) {
 			data:       "0",
 		}
 
-		db.data[key] = value
+		db.store(key, value)
+		db.updateSize(key, value)
 	}
 
 	value.mutex.RLock()
@@ -149,6 +302,10 @@ func (db *Database) IncrementSingleValue(key string) (newValue int64, ok bool) {
 		value.mutex.RUnlock()
 		intValue++
 		value.Set(SingleValue, strconv.FormatInt(intValue, 10), value.expireTime)
+		value.touch()
+		db.updateVersion(value)
+		db.updateSize(key, value)
+		db.notify(StringEvents, IncrEvent, key)
 		return intValue, true
 	}
 
@@ -161,13 +318,53 @@ func (db *Database) Unset(key string) (had bool) {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
+	if db.expireIfNeeded(key) {
+		return false
+	}
+
 	if _, had = db.data[key]; had {
-		delete(db.data, key)
+		db.remove(key)
+		db.notify(GenericEvents, DelEvent, key)
 	}
 
 	return
 }
 
+// Rename renames a key, replacing the destination key value if it exists.
+func (db *Database) Rename(key string, newKey string) (renamed bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.expireIfNeeded(key)
+	db.expireIfNeeded(newKey)
+
+	var value, exists = db.data[key]
+
+	if !exists {
+		return false
+	}
+
+	if key == newKey {
+		return true
+	}
+
+	if _, exists = db.data[newKey]; exists {
+		db.remove(newKey)
+	}
+
+	db.remove(key)
+	db.store(newKey, value)
+
+	var _, expires = value.GetInformation()
+	db.updateVersion(value)
+	db.updateSize(newKey, value)
+	db.trackExpire(newKey, expires)
+
+	db.notify(GenericEvents, RenameFromEvent, key)
+	db.notify(GenericEvents, RenameToEvent, newKey)
+	return true
+}
+
 // Has returns if a value exists into the database.
 func (db *Database) Has(key string) bool {
 	db.mutex.RLock()
@@ -177,7 +374,10 @@ func (db *Database) Has(key string) bool {
 		value.mutex.RLock()
 		defer value.mutex.RUnlock()
 
-		return (value.expireTime > time.Now().Unix()) || (value.expireTime == 0)
+		if (value.expireTime > time.Now().UnixMilli()) || (value.expireTime == 0) {
+			value.touch()
+			return true
+		}
 	}
 
 	return false
@@ -197,7 +397,7 @@ func (db *Database) Size() (size int) {
 
 		value.mutex.RLock()
 
-		if (value.expireTime > time.Now().Unix()) || (value.expireTime == 0) {
+		if (value.expireTime > time.Now().UnixMilli()) || (value.expireTime == 0) {
 			size++
 		}
 
@@ -206,3 +406,288 @@ func (db *Database) Size() (size int) {
 
 	return
 }
+
+// GetStatistics returns a snapshot of the database key counters.
+func (db *Database) GetStatistics() (stats Statistics) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var now = time.Now().UnixMilli()
+
+	for _, value := range db.data {
+		if value == nil {
+			continue
+		}
+
+		value.mutex.RLock()
+
+		if !value.isExpired(now) {
+			switch value.dataType {
+			case SingleValue:
+				stats.SingleValues++
+			case SortedSetValue:
+				stats.SortedSets++
+			}
+
+			if value.expireTime != 0 {
+				stats.Expires++
+			}
+		}
+
+		value.mutex.RUnlock()
+	}
+
+	stats.ExpiredKeys = db.expiredKeys
+	stats.EvictedKeys = db.evictedKeys
+	stats.Memory = db.usedMemory
+	return
+}
+
+// expireIfNeeded removes a key if its value has expired (the caller must hold the database write lock).
+func (db *Database) expireIfNeeded(key string) (expired bool) {
+	if value, exists := db.data[key]; exists && (value != nil) {
+		value.mutex.RLock()
+		expired = value.isExpired(time.Now().UnixMilli())
+		value.mutex.RUnlock()
+
+		if expired {
+			db.remove(key)
+			db.expiredKeys++
+			db.notify(ExpiredEvents, ExpiredEvent, key)
+		}
+	}
+
+	return
+}
+
+// trackExpire keeps the set of keys with an expire time up to date (the caller must hold the database write lock).
+func (db *Database) trackExpire(key string, expires int64) {
+	if expires != 0 {
+		db.expires.add(key)
+	} else {
+		db.expires.remove(key)
+	}
+}
+
+// store adds or replaces the value of a key (the caller must hold the database write lock).
+func (db *Database) store(key string, value *Value) {
+	db.data[key] = value
+	db.keys.add(key)
+}
+
+// remove removes a key and its memory accounting (the caller must hold the database write lock).
+func (db *Database) remove(key string) {
+	if value, exists := db.data[key]; exists {
+		db.usedMemory -= value.size
+		delete(db.data, key)
+		db.keys.remove(key)
+		db.expires.remove(key)
+	}
+}
+
+// updateVersion gives a new version to a changed value (the caller must hold the database write lock).
+func (db *Database) updateVersion(value *Value) {
+	db.version++
+	value.version = db.version
+}
+
+// updateSize recalculates the memory used by a key and its value (the caller must hold the database write lock).
+func (db *Database) updateSize(key string, value *Value) {
+	value.mutex.RLock()
+	var size = int64(len(key)) + keyOverhead + value.approximateSize()
+	value.mutex.RUnlock()
+
+	db.resize(value, size-value.size)
+}
+
+// resize adjusts the memory used by a value (the caller must hold the database write lock).
+func (db *Database) resize(value *Value, delta int64) {
+	value.size += delta
+	db.usedMemory += delta
+}
+
+// GetVersion returns the version of a key value, which changes every time the value is written, or zero when the key
+// does not exist. It doesn't change the key access time or frequency.
+func (db *Database) GetVersion(key string) uint64 {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if value, exists := db.data[key]; exists {
+		value.mutex.RLock()
+		defer value.mutex.RUnlock()
+
+		if !value.isExpired(time.Now().UnixMilli()) {
+			return value.version
+		}
+	}
+
+	return 0
+}
+
+// GetKeyInformation returns introspection information about a key, without changing its access time or frequency.
+func (db *Database) GetKeyInformation(key string) (information KeyInformation, exists bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var now = time.Now()
+	var value *Value
+
+	if value, exists = db.data[key]; !exists {
+		return
+	}
+
+	value.mutex.RLock()
+	defer value.mutex.RUnlock()
+
+	if value.isExpired(now.UnixMilli()) {
+		return information, false
+	}
+
+	information = KeyInformation{
+		Type:      value.dataType,
+		Memory:    value.size,
+		IdleTime:  value.getIdleTime(now),
+		Frequency: value.getFrequency(now),
+		Expires:   value.expireTime,
+	}
+
+	switch data := value.data.(type) {
+	case string:
+		information.Length = len(data)
+
+		if _, err := strconv.ParseInt(data, 10, 64); err == nil {
+			information.Encoding = "int"
+		} else {
+			information.Encoding = "raw"
+		}
+	case *SortedSet:
+		information.Length = data.Len()
+		information.Encoding = "sortedset"
+	}
+
+	return
+}
+
+// GetKeys returns the (non expired) keys matching a glob-style pattern.
+func (db *Database) GetKeys(pattern string) (keys []string) {
+	keys, _ = db.GetKeysContext(context.Background(), pattern)
+	return
+}
+
+// GetKeysContext returns the (non expired) keys matching a glob-style pattern, the scan stops (returning the context
+// error) when the context is done.
+func (db *Database) GetKeysContext(ctx context.Context, pattern string) (keys []string, err error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var now = time.Now().UnixMilli()
+	var scanned = 0
+	keys = make([]string, 0)
+
+	for key, value := range db.data {
+		if scanned++; scanned%scanCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		value.mutex.RLock()
+		var expired = value.isExpired(now)
+		value.mutex.RUnlock()
+
+		if !expired && glob.Match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return
+}
+
+// Scan examines up to count keys starting at a cursor (zero to start a new scan), and returns the (non expired) keys
+// matching a glob-style pattern and the cursor of the next call (zero once the scan is complete). The keys present
+// during the whole scan are returned at least once, while the keys added or removed during the scan may not be. Each
+// call holds the database lock only while examining its keys.
+func (db *Database) Scan(cursor uint64, pattern string, count int) (next uint64, keys []string) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	// Keys are examined from the end of the key slice: a removed key is replaced by the last key, which was already
+	// examined, so no key is skipped when keys are removed between calls.
+
+	var position = uint64(db.keys.len())
+
+	if (cursor != 0) && (cursor < position) {
+		position = cursor
+	}
+
+	var now = time.Now().UnixMilli()
+	keys = make([]string, 0)
+
+	for examined := 0; (examined < count) && (position > 0); examined++ {
+		position--
+
+		var key = db.keys.keys[position]
+		var value = db.data[key]
+
+		value.mutex.RLock()
+		var expired = value.isExpired(now)
+		value.mutex.RUnlock()
+
+		if !expired && glob.Match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return position, keys
+}
+
+// Snapshot returns a copy of every (non expired) key value.
+func (db *Database) Snapshot() (entries []SnapshotEntry) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var now = time.Now().UnixMilli()
+	entries = make([]SnapshotEntry, 0, len(db.data))
+
+	for key, value := range db.data {
+		if entry, ok := value.snapshot(key, now); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return
+}
+
+// SnapshotKey returns a copy of a single key value.
+func (db *Database) SnapshotKey(key string) (entry SnapshotEntry, exists bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if value, found := db.data[key]; found {
+		return value.snapshot(key, time.Now().UnixMilli())
+	}
+
+	return
+}
+
+// Flush removes all the keys from the database, sending a del event for each one of them.
+func (db *Database) Flush() {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var keys = make([]string, 0, len(db.data))
+
+	for key := range db.data {
+		keys = append(keys, key)
+	}
+
+	db.data = make(map[string]*Value)
+	db.keys = createKeySet()
+	db.expires = createKeySet()
+	db.usedMemory = 0
+
+	for index := range keys {
+		db.notify(GenericEvents, DelEvent, keys[index])
+	}
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -10,6 +13,8 @@ func TestDatabaseRace(test *testing.T) {
 	var testDB = Create()
 	var testWait sync.WaitGroup
 
+	defer testDB.Close()
+
 	testDB.SetSingleValue("raceTest", "0", 0)
 
 	for index := 0; index < 1000000; index++ {
@@ -23,17 +28,22 @@ func TestDatabaseRace(test *testing.T) {
 
 	testWait.Wait()
 
-	if testDB.GetSingleValue("raceTest") != "1000000" {
+	if value, _, _ := testDB.GetSingleValue("raceTest"); value != "1000000" {
 		test.Fail()
 	}
 }
 
 func TestExpireTime(test *testing.T) {
 	var testDB = Create()
+	defer testDB.Close()
+
+	testDB.SetSingleValue("expireTest", "0", time.Now().UnixMilli()+500)
 
-	testDB.SetSingleValue("expireTest", "0", time.Now().Unix()+5)
+	if !testDB.Has("expireTest") {
+		test.Fail()
+	}
 
-	time.Sleep(time.Second * 6)
+	time.Sleep(600 * time.Millisecond)
 
 	if testDB.Has("expireTest") {
 		test.Fail()
@@ -57,3 +67,193 @@ func TestSortedSets(test *testing.T) {
 		test.Fail()
 	}
 }
+
+func TestEvents(test *testing.T) {
+	var testDB = Create()
+	var events = make(chan Event, 16)
+
+	defer testDB.Close()
+	testDB.StartActiveExpiration()
+
+	testDB.AddListener(func(event Event) {
+		events <- event
+	})
+
+	testDB.SetSingleValue("eventTest", "0", time.Now().UnixMilli()+1000)
+	testDB.IncrementSingleValue("eventTest")
+	testDB.Rename("eventTest", "renamedTest")
+
+	var expected = []string{SetEvent, IncrEvent, RenameFromEvent, RenameToEvent, ExpiredEvent}
+
+	for index := range expected {
+		select {
+		case event := <-events:
+			if event.Name != expected[index] {
+				test.Fatalf("expected %s event, got %s", expected[index], event.Name)
+			}
+		case <-time.After(time.Second * 3):
+			test.Fatalf("expected %s event, got none", expected[index])
+		}
+	}
+
+	if testDB.Has("renamedTest") {
+		test.Fail()
+	}
+}
+
+func TestFlushEvents(test *testing.T) {
+	var testDB = Create()
+	var deleted = make(map[string]uint64)
+
+	defer testDB.Close()
+
+	testDB.SetSingleValue("first", "1", 0)
+	testDB.SetSingleValue("second", "2", time.Now().UnixMilli()+100000)
+
+	testDB.AddListener(func(event Event) {
+		if event.Name == DelEvent {
+			deleted[event.Key] = event.Version
+		}
+	})
+
+	testDB.Flush()
+
+	if (len(deleted) != 2) || (deleted["first"] != 0) || (deleted["second"] != 0) {
+		test.Errorf("flush events = %v", deleted)
+	}
+
+	if testDB.Has("first") || (testDB.GetStatistics() != Statistics{}) {
+		test.Errorf("flushed database statistics = %+v", testDB.GetStatistics())
+	}
+}
+
+func TestActiveExpirationWriteLock(test *testing.T) {
+	var testDB = Create()
+	var writeLock sync.Mutex
+	var done = make(chan struct{})
+
+	defer testDB.Close()
+
+	testDB.SetWriteLock(&writeLock)
+	testDB.SetSingleValue("expired", "1", time.Now().UnixMilli()-1)
+
+	writeLock.Lock()
+
+	go func() {
+		testDB.activeExpireCycle()
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if testDB.GetStatistics().ExpiredKeys != 0 {
+		test.Error("active expiration removed a key while the write lock was held")
+	}
+
+	writeLock.Unlock()
+	<-done
+
+	if testDB.GetStatistics().ExpiredKeys != 1 {
+		test.Error("active expiration did not remove the expired key")
+	}
+}
+
+func TestEviction(test *testing.T) {
+	var testDB = Create()
+	defer testDB.Close()
+
+	for index := 0; index < 100; index++ {
+		testDB.SetSingleValue("evictionTest"+strconv.Itoa(index), "0123456789", 0)
+	}
+
+	var usedMemory = testDB.GetStatistics().Memory
+	testDB.SetMaxMemory(usedMemory / 2)
+
+	if testDB.FreeMemory() {
+		test.Fatal("noeviction policy must not free memory")
+	}
+
+	testDB.SetEvictionPolicy("allkeys-lru")
+
+	if !testDB.FreeMemory() {
+		test.Fatal("allkeys-lru policy must free memory")
+	}
+
+	var stats = testDB.GetStatistics()
+
+	if (stats.Memory > usedMemory/2) || (stats.EvictedKeys == 0) || (stats.SingleValues+stats.EvictedKeys != 100) {
+		test.Fail()
+	}
+
+	for index := 0; index < 100; index++ {
+		testDB.Unset("evictionTest" + strconv.Itoa(index))
+	}
+
+	if testDB.GetStatistics().Memory != 0 {
+		test.Fail()
+	}
+}
+
+func TestGetKeysContext(test *testing.T) {
+	var testDB = Create()
+	defer testDB.Close()
+
+	for index := 0; index < 3*scanCheckInterval; index++ {
+		testDB.SetSingleValue("key"+strconv.Itoa(index), "value", 0)
+	}
+
+	if keys, err := testDB.GetKeysContext(context.Background(), "key1*"); (err != nil) || (len(keys) == 0) {
+		test.Errorf("GetKeysContext = %d keys, %v", len(keys), err)
+	}
+
+	var cancelledContext, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if keys, err := testDB.GetKeysContext(cancelledContext, "*"); (keys != nil) || !errors.Is(err, context.Canceled) {
+		test.Errorf("cancelled GetKeysContext = %d keys, %v", len(keys), err)
+	}
+}
+
+func TestVersions(test *testing.T) {
+	var testDB = Create()
+	defer testDB.Close()
+
+	if testDB.GetVersion("missing") != 0 {
+		test.Error("missing key has a version")
+	}
+
+	testDB.SetSingleValue("key", "1", 0)
+	var created = testDB.GetVersion("key")
+
+	if (created == 0) || (testDB.GetVersion("key") != created) {
+		test.Errorf("version after set = %d", created)
+	}
+
+	testDB.IncrementSingleValue("key")
+	var incremented = testDB.GetVersion("key")
+
+	if incremented <= created {
+		test.Errorf("version after increment = %d, expected more than %d", incremented, created)
+	}
+
+	testDB.Rename("key", "renamed")
+
+	if (testDB.GetVersion("key") != 0) || (testDB.GetVersion("renamed") <= incremented) {
+		test.Errorf("versions after rename = %d, %d", testDB.GetVersion("key"), testDB.GetVersion("renamed"))
+	}
+
+	testDB.Unset("renamed")
+	testDB.SetSingleValue("renamed", "1", 0)
+
+	if testDB.GetVersion("renamed") <= incremented {
+		test.Error("recreated key reuses a version")
+	}
+
+	testDB.AddSortedSetEntries("set", []*SortedSetEntry{CreateSortedSetEntry("a", 1)})
+	var added = testDB.GetVersion("set")
+	testDB.AddSortedSetEntries("set", []*SortedSetEntry{CreateSortedSetEntry("b", 2)})
+
+	if (added == 0) || (testDB.GetVersion("set") <= added) {
+		test.Errorf("versions after zadd = %d, %d", added, testDB.GetVersion("set"))
+	}
+}
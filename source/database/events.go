@@ -0,0 +1,148 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// Event classes, used to filter the events a listener is interested in.
+const (
+	GenericEvents = 1 << iota
+	StringEvents
+	SortedSetEvents
+	ExpiredEvents
+	EvictedEvents
+
+	AllEvents = GenericEvents | StringEvents | SortedSetEvents | ExpiredEvents | EvictedEvents
+)
+
+// Event names.
+const (
+	SetEvent        = "set"
+	DelEvent        = "del"
+	ExpiredEvent    = "expired"
+	EvictedEvent    = "evicted"
+	IncrEvent       = "incr"
+	ZaddEvent       = "zadd"
+	RenameFromEvent = "rename_from"
+	RenameToEvent   = "rename_to"
+)
+
+// Active expiration settings: every cycle samples keys with an expire time and removes the expired ones, the
+// sampling is repeated while more than a quarter of the sampled keys were expired (up to the cycle time limit).
+const (
+	activeExpireInterval   = 100 * time.Millisecond
+	activeExpireSampleSize = 20
+	activeExpireTimeLimit  = 25 * time.Millisecond
+)
+
+type (
+	// Event describes a single database change.
+	Event struct {
+		Class int
+		Name  string
+		Key   string
+
+		// Version is the key version after the change, zero when the key was removed.
+		Version uint64
+	}
+
+	// Listener receives database change events. Listeners are called while the database is locked, so they must
+	// return quickly and never call database methods.
+	Listener func(event Event)
+)
+
+// AddListener adds a database change listener.
+func (db *Database) AddListener(listener Listener) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.listeners = append(db.listeners, listener)
+}
+
+// notify sends an event to all the listeners (the caller must hold the database write lock).
+func (db *Database) notify(class int, name string, key string) {
+	if len(db.listeners) == 0 {
+		return
+	}
+
+	var event = Event{Class: class, Name: name, Key: key}
+
+	if value, exists := db.data[key]; exists {
+		event.Version = value.version
+	}
+
+	for index := range db.listeners {
+		db.listeners[index](event)
+	}
+}
+
+// StartActiveExpiration starts removing the expired keys in the background (until the database is closed), so their
+// expired events are sent shortly after their expire time.
+func (db *Database) StartActiveExpiration() {
+	db.expireOnce.Do(func() {
+		go db.runActiveExpire()
+	})
+}
+
+// SetWriteLock sets a lock held by the active expiration while it removes keys, so the removals of the background
+// task are serialized with the writes of the caller holding the same lock (nil by default).
+func (db *Database) SetWriteLock(writeLock sync.Locker) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.writeLock = writeLock
+}
+
+// Close stops the database background tasks.
+func (db *Database) Close() {
+	db.closeOnce.Do(func() {
+		close(db.done)
+	})
+}
+
+func (db *Database) runActiveExpire() {
+	var ticker = time.NewTicker(activeExpireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-ticker.C:
+			db.activeExpireCycle()
+		}
+	}
+}
+
+func (db *Database) activeExpireCycle() {
+	db.mutex.RLock()
+	var writeLock = db.writeLock
+	db.mutex.RUnlock()
+
+	if writeLock != nil {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var startTime = time.Now()
+
+	for db.expires.len() > 0 {
+		var sampled, expired int
+
+		for (sampled < activeExpireSampleSize) && (db.expires.len() > 0) {
+			sampled++
+
+			if db.expireIfNeeded(db.expires.random()) {
+				expired++
+			}
+		}
+
+		if (expired*4 <= sampled) || (time.Since(startTime) > activeExpireTimeLimit) {
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// Eviction policies, used to free memory when the memory limit is reached.
+const (
+	NoEviction = iota
+	AllKeysLRU
+	AllKeysLFU
+	VolatileLRU
+	VolatileTTL
+	AllKeysRandom
+)
+
+// Number of keys sampled to find the best eviction candidate.
+const defaultEvictionSamples = 5
+
+// Eviction policy names.
+var evictionPolicyNames = map[int]string{
+	NoEviction:    "noeviction",
+	AllKeysLRU:    "allkeys-lru",
+	AllKeysLFU:    "allkeys-lfu",
+	VolatileLRU:   "volatile-lru",
+	VolatileTTL:   "volatile-ttl",
+	AllKeysRandom: "allkeys-random",
+}
+
+// SetMaxMemory sets the memory limit in bytes (zero means no limit).
+func (db *Database) SetMaxMemory(maxMemory int64) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.maxMemory = maxMemory
+}
+
+// GetMaxMemory returns the memory limit in bytes (zero means no limit).
+func (db *Database) GetMaxMemory() int64 {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.maxMemory
+}
+
+// SetEvictionPolicy sets the eviction policy by name.
+func (db *Database) SetEvictionPolicy(name string) (ok bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for policy, policyName := range evictionPolicyNames {
+		if policyName == strings.ToLower(name) {
+			db.policy = policy
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetEvictionPolicy returns the eviction policy name.
+func (db *Database) GetEvictionPolicy() string {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return evictionPolicyNames[db.policy]
+}
+
+// SetEvictionSamples sets the number of keys sampled to find the best eviction candidate.
+func (db *Database) SetEvictionSamples(samples int) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.samples = max(samples, 1)
+}
+
+// GetEvictionSamples returns the number of keys sampled to find the best eviction candidate.
+func (db *Database) GetEvictionSamples() int {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.samples
+}
+
+// FreeMemory evicts keys (according to the eviction policy) until the used memory is below the memory limit, it
+// returns false if that is not possible (e.g. when using the noeviction policy).
+func (db *Database) FreeMemory() (ok bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for (db.maxMemory > 0) && (db.usedMemory > db.maxMemory) {
+		if db.policy == NoEviction {
+			return false
+		}
+
+		var key, found = db.findEvictionCandidate()
+
+		if !found {
+			return false
+		}
+
+		db.remove(key)
+		db.evictedKeys++
+		db.notify(EvictedEvents, EvictedEvent, key)
+	}
+
+	return true
+}
+
+// findEvictionCandidate samples keys and returns the best one to be evicted (the caller must hold the database lock).
+func (db *Database) findEvictionCandidate() (candidate string, found bool) {
+	var now = time.Now()
+	var bestScore float64
+	var sampled = 0
+
+	var consider = func(key string, value *Value) {
+		var score float64
+
+		switch db.policy {
+		case AllKeysLRU, VolatileLRU:
+			score = float64(value.getIdleTime(now))
+		case AllKeysLFU:
+			score = lfuMaxValue - float64(value.getFrequency(now))
+		case VolatileTTL:
+			value.mutex.RLock()
+			score = -float64(value.expireTime)
+			value.mutex.RUnlock()
+		}
+
+		if !found || (score > bestScore) {
+			candidate = key
+			bestScore = score
+			found = true
+		}
+
+		sampled++
+	}
+
+	// Keys are sampled uniformly at random (with replacement) from all the keys, or from the keys with an expire time.
+
+	var keys = db.keys
+
+	if (db.policy == VolatileLRU) || (db.policy == VolatileTTL) {
+		keys = db.expires
+	}
+
+	for (sampled < db.samples) && (keys.len() > 0) {
+		var key = keys.random()
+		consider(key, db.data[key])
+	}
+
+	return
+}
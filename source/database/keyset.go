@@ -0,0 +1,51 @@
+package database
+
+import (
+	"math/rand"
+)
+
+// keySet is a set of keys that can return random keys in constant time, so samples of keys are uniformly random
+// (unlike the first keys found when iterating a map).
+type keySet struct {
+	keys    []string
+	indexes map[string]int
+}
+
+func createKeySet() *keySet {
+	return &keySet{
+		indexes: make(map[string]int),
+	}
+}
+
+func (set *keySet) add(key string) {
+	if _, exists := set.indexes[key]; !exists {
+		set.indexes[key] = len(set.keys)
+		set.keys = append(set.keys, key)
+	}
+}
+
+// remove removes a key, moving the last key to its position.
+func (set *keySet) remove(key string) {
+	var index, exists = set.indexes[key]
+
+	if !exists {
+		return
+	}
+
+	var last = len(set.keys) - 1
+
+	set.keys[index] = set.keys[last]
+	set.indexes[set.keys[index]] = index
+	set.keys[last] = ""
+	set.keys = set.keys[:last]
+	delete(set.indexes, key)
+}
+
+func (set *keySet) len() int {
+	return len(set.keys)
+}
+
+// random returns a random key of the set (which must not be empty).
+func (set *keySet) random() string {
+	return set.keys[rand.Intn(len(set.keys))]
+}
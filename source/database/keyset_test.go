@@ -0,0 +1,68 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestKeySet(test *testing.T) {
+	var set = createKeySet()
+
+	for index := 0; index < 5; index++ {
+		set.add("key" + strconv.Itoa(index))
+	}
+
+	set.add("key0")
+	set.remove("key1")
+	set.remove("key4")
+	set.remove("missing")
+
+	if set.len() != 3 {
+		test.Fatalf("len = %d, expected 3", set.len())
+	}
+
+	for index, key := range set.keys {
+		if set.indexes[key] != index {
+			test.Errorf("index of %s = %d, expected %d", key, set.indexes[key], index)
+		}
+	}
+
+	for _, key := range []string{"key1", "key4"} {
+		if _, exists := set.indexes[key]; exists {
+			test.Errorf("removed key %s still in the set", key)
+		}
+	}
+}
+
+func TestEvictionSampling(test *testing.T) {
+	var testDB = Create()
+	var counts = make(map[string]int)
+
+	defer testDB.Close()
+
+	for index := 0; index < 10; index++ {
+		testDB.SetSingleValue("key"+strconv.Itoa(index), "value", 0)
+	}
+
+	testDB.SetEvictionPolicy("allkeys-random")
+
+	const trials = 10000
+
+	for trial := 0; trial < trials; trial++ {
+		var key, found = testDB.findEvictionCandidate()
+
+		if !found {
+			test.Fatal("no eviction candidate")
+		}
+
+		counts[key]++
+	}
+
+	// Every key is expected trials/10 times, a uniform sample is never this far from it.
+
+	for index := 0; index < 10; index++ {
+		if count := counts["key"+strconv.Itoa(index)]; (count < trials/20) || (count > trials*3/20) {
+			test.Errorf("key%d chosen %d times out of %d", index, count, trials)
+		}
+	}
+}
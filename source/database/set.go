@@ -144,6 +144,32 @@ func (set *SortedSet) GetRank(member string) int64 {
 	return -1
 }
 
+// getEntries returns a sorted copy of the set entries.
+func (set *SortedSet) getEntries() (entries []*SortedSetEntry) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	set.checkSort()
+	entries = make([]*SortedSetEntry, len(set.sorted))
+
+	for index := range set.sorted {
+		entries[index] = CreateSortedSetEntry(set.sorted[index].member, set.sorted[index].score)
+	}
+
+	return
+}
+
+func (set *SortedSet) approximateSize() (size int64) {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+
+	for member := range set.entries {
+		size += int64(len(member)) + setEntryOverhead
+	}
+
+	return
+}
+
 // Get returns the member and score for a sorted set entry.
 func (entry *SortedSetEntry) Get() (member string, score float64) {
 	return entry.member, entry.score
@@ -1,7 +1,10 @@
 package database
 
 import (
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Value type constants.
@@ -10,16 +13,47 @@ const (
 	SortedSetValue
 )
 
+// GetTypeName returns the name of a value type.
+func GetTypeName(dataType int) string {
+	switch dataType {
+	case SingleValue:
+		return "string"
+	case SortedSetValue:
+		return "zset"
+	}
+
+	return "none"
+}
+
+// Approximate memory overheads (in bytes) used for memory accounting.
+const (
+	keyOverhead      = 16
+	valueOverhead    = 48
+	setEntryOverhead = 64
+)
+
 type (
 	// Value represents a database value.
 	Value struct {
 		mutex      sync.RWMutex
 		dataType   int
-		expireTime int64
+		expireTime int64 // Unix milliseconds, zero when the value never expires
 		data       interface{}
+		size       int64
+		version    uint64
+		lastAccess atomic.Int64
+		frequency  atomic.Uint32
 	}
 )
 
+// Logarithmic access frequency counter settings (the counter saturates at 255 and decays one unit per minute idle).
+const (
+	lfuInitialValue = 5
+	lfuLogFactor    = 10
+	lfuMaxValue     = 255
+	lfuDecayTime    = time.Minute
+)
+
 // Get returns the current data for the value.
 func (value *Value) Get() interface{} {
 	value.mutex.RLock()
@@ -53,3 +87,86 @@ func (value *Value) Set(dataType int, data interface{}, expires int64) {
 	value.data = data
 	value.expireTime = expires
 }
+
+// isExpired returns if the value has expired at the specified time (the caller must hold the value lock).
+func (value *Value) isExpired(now int64) bool {
+	return (value.expireTime != 0) && (value.expireTime <= now)
+}
+
+// approximateSize returns the approximate memory used by the value data (the caller must hold the value lock).
+func (value *Value) approximateSize() (size int64) {
+	size = valueOverhead
+
+	switch data := value.data.(type) {
+	case string:
+		size += int64(len(data))
+	case *SortedSet:
+		size += data.approximateSize()
+	}
+
+	return
+}
+
+// touch updates the value access time and frequency, it is safe to call while holding only read locks.
+func (value *Value) touch() {
+	var now = time.Now()
+	var frequency = value.getFrequency(now)
+
+	if frequency < lfuMaxValue {
+		var baseValue = float64(frequency) - lfuInitialValue
+
+		if (baseValue <= 0) || (rand.Float64() < 1/(baseValue*lfuLogFactor+1)) {
+			frequency++
+		}
+	}
+
+	value.frequency.Store(frequency)
+	value.lastAccess.Store(now.UnixMilli())
+}
+
+// getIdleTime returns the time since the value was last accessed.
+func (value *Value) getIdleTime(now time.Time) time.Duration {
+	return time.Duration(now.UnixMilli()-value.lastAccess.Load()) * time.Millisecond
+}
+
+// getFrequency returns the (decayed) logarithmic access frequency counter.
+func (value *Value) getFrequency(now time.Time) uint32 {
+	var frequency = value.frequency.Load()
+
+	if value.lastAccess.Load() == 0 {
+		return lfuInitialValue
+	}
+
+	var decay = uint32(value.getIdleTime(now) / lfuDecayTime)
+
+	if decay >= frequency {
+		return 0
+	}
+
+	return frequency - decay
+}
+
+// snapshot returns a copy of the value, if it has not expired.
+func (value *Value) snapshot(key string, now int64) (entry SnapshotEntry, ok bool) {
+	value.mutex.RLock()
+	defer value.mutex.RUnlock()
+
+	if value.isExpired(now) {
+		return
+	}
+
+	entry = SnapshotEntry{
+		Key:     key,
+		Type:    value.dataType,
+		Expires: value.expireTime,
+	}
+
+	switch data := value.data.(type) {
+	case string:
+		entry.Value = data
+	case *SortedSet:
+		entry.Entries = data.getEntries()
+	}
+
+	return entry, true
+}
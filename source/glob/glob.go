@@ -0,0 +1,129 @@
+package glob
+
+import "strings"
+
+// Match reports if a string matches a glob-style pattern.
+//
+// Supported pattern syntax:
+//   - *: matches any sequence of characters (including none).
+//   - ?: matches any single character.
+//   - [abc], [^abc], [a-z]: matches (or not, with ^) one character from the set.
+//   - \x: matches the character x literally.
+func Match(pattern string, value string) bool {
+	return match([]rune(pattern), []rune(value))
+}
+
+// Escape returns a pattern that only matches the value itself, escaping the pattern special characters.
+func Escape(value string) string {
+	var builder strings.Builder
+
+	for _, char := range value {
+		if strings.ContainsRune(`*?[]\`, char) {
+			builder.WriteRune('\\')
+		}
+
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+func match(pattern []rune, value []rune) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for (len(pattern) > 1) && (pattern[1] == '*') {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 1 {
+				return true
+			}
+
+			for index := 0; index <= len(value); index++ {
+				if match(pattern[1:], value[index:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(value) == 0 {
+				return false
+			}
+		case '[':
+			if len(value) == 0 {
+				return false
+			}
+
+			var matched bool
+			var consumed int
+
+			if matched, consumed = matchSet(pattern, value[0]); !matched {
+				return false
+			}
+
+			pattern = pattern[consumed:]
+			value = value[1:]
+			continue
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+
+			fallthrough
+		default:
+			if (len(value) == 0) || (pattern[0] != value[0]) {
+				return false
+			}
+		}
+
+		pattern = pattern[1:]
+		value = value[1:]
+	}
+
+	return len(value) == 0
+}
+
+// matchSet matches a character against a [...] set, returning the number of pattern characters consumed.
+func matchSet(pattern []rune, char rune) (matched bool, consumed int) {
+	var index = 1
+	var negate = false
+
+	if (index < len(pattern)) && (pattern[index] == '^') {
+		negate = true
+		index++
+	}
+
+	for (index < len(pattern)) && (pattern[index] != ']') {
+		if (pattern[index] == '\\') && (index+1 < len(pattern)) {
+			index++
+
+			if pattern[index] == char {
+				matched = true
+			}
+		} else if (index+2 < len(pattern)) && (pattern[index+1] == '-') && (pattern[index+2] != ']') {
+			var start, end = pattern[index], pattern[index+2]
+
+			if start > end {
+				start, end = end, start
+			}
+
+			if (char >= start) && (char <= end) {
+				matched = true
+			}
+
+			index += 2
+		} else if pattern[index] == char {
+			matched = true
+		}
+
+		index++
+	}
+
+	if index < len(pattern) {
+		index++
+	}
+
+	return matched != negate, index
+}
@@ -0,0 +1,52 @@
+package glob
+
+import (
+	"testing"
+)
+
+func TestMatch(test *testing.T) {
+	var cases = []struct {
+		pattern string
+		value   string
+		matches bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"news.*", "news.sports", true},
+		{"news.*", "weather.today", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h*o", "h/a/b/o", true},
+		{`h\*o`, "h*o", true},
+		{`h\*o`, "hello", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+	}
+
+	for index := range cases {
+		if Match(cases[index].pattern, cases[index].value) != cases[index].matches {
+			test.Errorf("Match(%q, %q) != %v", cases[index].pattern, cases[index].value, cases[index].matches)
+		}
+	}
+}
+
+func TestEscape(test *testing.T) {
+	for _, value := range []string{"plain", "user:*", `a?b[c]\d`, ""} {
+		if !Match(Escape(value), value) {
+			test.Errorf("Match(Escape(%q), %q) is false", value, value)
+		}
+
+		if (value != "") && Match(Escape(value), value+"x") {
+			test.Errorf("Match(Escape(%q), %q) is true", value, value+"x")
+		}
+	}
+
+	if Match(Escape("user:*"), "user:1") {
+		test.Error("escaped pattern matches other values")
+	}
+}
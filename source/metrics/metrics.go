@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Metric type names, as used by the Prometheus text exposition format.
+const (
+	CounterType   = "counter"
+	GaugeType     = "gauge"
+	HistogramType = "histogram"
+)
+
+type (
+	// Counter represents a monotonically increasing value.
+	Counter struct {
+		value atomic.Uint64
+	}
+
+	// Gauge represents a value that can go up and down.
+	Gauge struct {
+		value atomic.Int64
+	}
+
+	// Histogram counts observations into a fixed set of cumulative buckets.
+	Histogram struct {
+		mutex   sync.Mutex
+		buckets []float64
+		counts  []uint64
+		count   uint64
+		sum     float64
+	}
+
+	// Labels represents a set of metric labels.
+	Labels map[string]string
+
+	// Writer writes metrics using the Prometheus text exposition format.
+	Writer struct {
+		output io.Writer
+		err    error
+	}
+)
+
+// LatencyBuckets defines the default buckets (in seconds) used for latency histograms.
+var LatencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// SizeBuckets defines the default buckets (in bytes) used for size histograms.
+var SizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+
+// Add adds a delta to the counter.
+func (counter *Counter) Add(delta uint64) {
+	counter.value.Add(delta)
+}
+
+// Increment increments the counter by one.
+func (counter *Counter) Increment() {
+	counter.value.Add(1)
+}
+
+// Get returns the current counter value.
+func (counter *Counter) Get() uint64 {
+	return counter.value.Load()
+}
+
+// Add adds a (possibly negative) delta to the gauge.
+func (gauge *Gauge) Add(delta int64) {
+	gauge.value.Add(delta)
+}
+
+// Get returns the current gauge value.
+func (gauge *Gauge) Get() int64 {
+	return gauge.value.Load()
+}
+
+// CreateHistogram creates a new histogram with the specified (ascending) bucket upper bounds.
+func CreateHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds a single observation to the histogram.
+func (histogram *Histogram) Observe(value float64) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	for index := range histogram.buckets {
+		if value <= histogram.buckets[index] {
+			histogram.counts[index]++
+		}
+	}
+
+	histogram.count++
+	histogram.sum += value
+}
+
+// Snapshot returns a consistent copy of the histogram cumulative counts, total count and sum.
+func (histogram *Histogram) Snapshot() (counts []uint64, count uint64, sum float64) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	counts = make([]uint64, len(histogram.counts))
+	copy(counts, histogram.counts)
+
+	return counts, histogram.count, histogram.sum
+}
+
+// CreateWriter creates a new metrics writer that outputs to the specified writer.
+func CreateWriter(output io.Writer) *Writer {
+	return &Writer{
+		output: output,
+	}
+}
+
+// Error returns the first error found while writing the metrics (if any).
+func (writer *Writer) Error() error {
+	return writer.err
+}
+
+// Header writes the help and type lines for a metric family.
+func (writer *Writer) Header(name string, metricType string, help string) {
+	writer.printf("# HELP %s %s\n", name, help)
+	writer.printf("# TYPE %s %s\n", name, metricType)
+}
+
+// Value writes a single sample line.
+func (writer *Writer) Value(name string, labels Labels, value float64) {
+	writer.printf("%s%s %s\n", name, formatLabels(labels, "", ""), formatValue(value))
+}
+
+// Histogram writes all the sample lines for a histogram.
+func (writer *Writer) Histogram(name string, labels Labels, histogram *Histogram) {
+	var counts, count, sum = histogram.Snapshot()
+
+	for index := range histogram.buckets {
+		writer.printf("%s_bucket%s %d\n", name, formatLabels(labels, "le", formatValue(histogram.buckets[index])), counts[index])
+	}
+
+	writer.printf("%s_bucket%s %d\n", name, formatLabels(labels, "le", "+Inf"), count)
+	writer.printf("%s_sum%s %s\n", name, formatLabels(labels, "", ""), formatValue(sum))
+	writer.printf("%s_count%s %d\n", name, formatLabels(labels, "", ""), count)
+}
+
+func (writer *Writer) printf(format string, arguments ...interface{}) {
+	if writer.err != nil {
+		return
+	}
+
+	_, writer.err = fmt.Fprintf(writer.output, format, arguments...)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatLabels(labels Labels, extraName string, extraValue string) string {
+	if (len(labels) == 0) && (extraName == "") {
+		return ""
+	}
+
+	var names = make([]string, 0, len(labels))
+
+	for name := range labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var pairs = make([]string, 0, len(names)+1)
+
+	for index := range names {
+		pairs = append(pairs, names[index]+`="`+labelValueReplacer.Replace(labels[names[index]])+`"`)
+	}
+
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+labelValueReplacer.Replace(extraValue)+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
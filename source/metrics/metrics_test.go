@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriter(test *testing.T) {
+	var output strings.Builder
+	var writer = CreateWriter(&output)
+	var counter Counter
+	var gauge Gauge
+	var histogram = CreateHistogram([]float64{0.1, 1})
+
+	counter.Add(2)
+	counter.Increment()
+	gauge.Add(5)
+	gauge.Add(-2)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(3)
+
+	writer.Header("test_total", CounterType, "Test counter.")
+	writer.Value("test_total", Labels{"b": "2", "a": "quote \" and \\ and\nline"}, float64(counter.Get()))
+	writer.Header("test_gauge", GaugeType, "Test gauge.")
+	writer.Value("test_gauge", nil, float64(gauge.Get()))
+	writer.Value("test_gauge", Labels{"limit": "max"}, math.Inf(1))
+	writer.Header("test_seconds", HistogramType, "Test histogram.")
+	writer.Histogram("test_seconds", Labels{"command": "GET"}, histogram)
+
+	var expected = `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{a="quote \" and \\ and\nline",b="2"} 3
+# HELP test_gauge Test gauge.
+# TYPE test_gauge gauge
+test_gauge 3
+test_gauge{limit="max"} +Inf
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{command="GET",le="0.1"} 1
+test_seconds_bucket{command="GET",le="1"} 2
+test_seconds_bucket{command="GET",le="+Inf"} 3
+test_seconds_sum{command="GET"} 3.55
+test_seconds_count{command="GET"} 3
+`
+
+	if writer.Error() != nil {
+		test.Fatal(writer.Error())
+	}
+
+	if output.String() != expected {
+		test.Errorf("metrics output:\n%s\nexpected:\n%s", output.String(), expected)
+	}
+}
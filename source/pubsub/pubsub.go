@@ -0,0 +1,219 @@
+package pubsub
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"arc/glob"
+)
+
+// Number of messages a subscription can hold before new messages are dropped.
+const subscriptionBufferSize = 1024
+
+type (
+	// Message represents a message delivered to a subscription.
+	Message struct {
+		Pattern string
+		Channel string
+		Payload string
+	}
+
+	// Broker routes published messages to the subscriptions of matching channels and patterns.
+	Broker struct {
+		mutex    sync.RWMutex
+		channels map[string]map[*Subscription]struct{}
+		patterns map[string]map[*Subscription]struct{}
+	}
+
+	// Subscription receives the messages published to its channels and patterns.
+	Subscription struct {
+		broker   *Broker
+		messages chan Message
+		channels map[string]struct{}
+		patterns map[string]struct{}
+		closed   bool
+		dropped  atomic.Uint64
+	}
+)
+
+// CreateBroker creates a new message broker.
+func CreateBroker() *Broker {
+	return &Broker{
+		channels: make(map[string]map[*Subscription]struct{}),
+		patterns: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe creates a new subscription, with no channels or patterns.
+func (broker *Broker) Subscribe() *Subscription {
+	return &Subscription{
+		broker:   broker,
+		messages: make(chan Message, subscriptionBufferSize),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Publish sends a message to a channel and returns the number of subscriptions that received it.
+func (broker *Broker) Publish(channel string, payload string) (receivers int) {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+
+	for subscription := range broker.channels[channel] {
+		if subscription.deliver(Message{Channel: channel, Payload: payload}) {
+			receivers++
+		}
+	}
+
+	for pattern, subscriptions := range broker.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+
+		for subscription := range subscriptions {
+			if subscription.deliver(Message{Pattern: pattern, Channel: channel, Payload: payload}) {
+				receivers++
+			}
+		}
+	}
+
+	return
+}
+
+// GetChannels returns the active channels (channels with at least one subscriber) matching a pattern.
+func (broker *Broker) GetChannels(pattern string) (channels []string) {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+
+	channels = make([]string, 0)
+
+	for channel := range broker.channels {
+		if (pattern == "") || glob.Match(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Strings(channels)
+	return
+}
+
+// GetNumberOfSubscribers returns the number of subscribers for a channel (pattern subscribers are not counted).
+func (broker *Broker) GetNumberOfSubscribers(channel string) int {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+
+	return len(broker.channels[channel])
+}
+
+// GetNumberOfPatterns returns the number of patterns with at least one subscriber.
+func (broker *Broker) GetNumberOfPatterns() int {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+
+	return len(broker.patterns)
+}
+
+// deliver sends a message to the subscription without blocking, messages are dropped if the subscriber is not keeping up.
+func (subscription *Subscription) deliver(message Message) bool {
+	select {
+	case subscription.messages <- message:
+		return true
+	default:
+		subscription.dropped.Add(1)
+		return false
+	}
+}
+
+// Messages returns the subscription message channel, it is closed when the subscription is closed.
+func (subscription *Subscription) Messages() <-chan Message {
+	return subscription.messages
+}
+
+// GetDropped returns the number of messages dropped because the subscriber was not keeping up.
+func (subscription *Subscription) GetDropped() uint64 {
+	return subscription.dropped.Load()
+}
+
+// GetCount returns the number of channels and patterns the subscription is subscribed to.
+func (subscription *Subscription) GetCount() int {
+	subscription.broker.mutex.RLock()
+	defer subscription.broker.mutex.RUnlock()
+
+	return len(subscription.channels) + len(subscription.patterns)
+}
+
+// Subscribe subscribes to one or more channels.
+func (subscription *Subscription) Subscribe(channels ...string) {
+	subscription.add(subscription.broker.channels, subscription.channels, channels)
+}
+
+// PSubscribe subscribes to one or more channel patterns.
+func (subscription *Subscription) PSubscribe(patterns ...string) {
+	subscription.add(subscription.broker.patterns, subscription.patterns, patterns)
+}
+
+// Unsubscribe unsubscribes from the specified channels (or from all channels if none is specified).
+func (subscription *Subscription) Unsubscribe(channels ...string) {
+	subscription.remove(subscription.broker.channels, subscription.channels, channels)
+}
+
+// PUnsubscribe unsubscribes from the specified patterns (or from all patterns if none is specified).
+func (subscription *Subscription) PUnsubscribe(patterns ...string) {
+	subscription.remove(subscription.broker.patterns, subscription.patterns, patterns)
+}
+
+// Close removes all the subscription channels and patterns and closes the message channel.
+func (subscription *Subscription) Close() {
+	subscription.Unsubscribe()
+	subscription.PUnsubscribe()
+
+	subscription.broker.mutex.Lock()
+	defer subscription.broker.mutex.Unlock()
+
+	if !subscription.closed {
+		subscription.closed = true
+		close(subscription.messages)
+	}
+}
+
+func (subscription *Subscription) add(index map[string]map[*Subscription]struct{}, own map[string]struct{}, names []string) {
+	subscription.broker.mutex.Lock()
+	defer subscription.broker.mutex.Unlock()
+
+	if subscription.closed {
+		return
+	}
+
+	for _, name := range names {
+		if _, exists := index[name]; !exists {
+			index[name] = make(map[*Subscription]struct{})
+		}
+
+		index[name][subscription] = struct{}{}
+		own[name] = struct{}{}
+	}
+}
+
+func (subscription *Subscription) remove(index map[string]map[*Subscription]struct{}, own map[string]struct{}, names []string) {
+	subscription.broker.mutex.Lock()
+	defer subscription.broker.mutex.Unlock()
+
+	if len(names) == 0 {
+		for name := range own {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		delete(own, name)
+
+		if subscriptions, exists := index[name]; exists {
+			delete(subscriptions, subscription)
+
+			if len(subscriptions) == 0 {
+				delete(index, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestPublishSubscribe(test *testing.T) {
+	var testBroker = CreateBroker()
+	var channelSubscription = testBroker.Subscribe()
+	var patternSubscription = testBroker.Subscribe()
+
+	channelSubscription.Subscribe("news.sports")
+	patternSubscription.PSubscribe("news.*")
+
+	if testBroker.Publish("news.sports", "goal") != 2 {
+		test.Fail()
+	}
+
+	if testBroker.Publish("news.weather", "rain") != 1 {
+		test.Fail()
+	}
+
+	if message := <-channelSubscription.Messages(); (message.Channel != "news.sports") || (message.Payload != "goal") || (message.Pattern != "") {
+		test.Fail()
+	}
+
+	if message := <-patternSubscription.Messages(); (message.Channel != "news.sports") || (message.Pattern != "news.*") {
+		test.Fail()
+	}
+
+	channelSubscription.Close()
+
+	if (testBroker.Publish("news.sports", "again") != 1) || (len(testBroker.GetChannels("")) != 0) || (testBroker.GetNumberOfPatterns() != 1) {
+		test.Fail()
+	}
+
+	if _, open := <-channelSubscription.Messages(); open {
+		test.Fail()
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// BasicAuthenticator authenticates the requests with HTTP basic authentication, given the passwords of the users.
+// Requests without credentials, with an unknown user or with a wrong password are rejected, so anonymous clients
+// cannot run any command (including the admin ones) once users are configured.
+func BasicAuthenticator(passwords map[string]string) Authenticator {
+	return func(request *http.Request) (user string, valid bool) {
+		var password, hasCredentials = "", false
+
+		if user, password, hasCredentials = request.BasicAuth(); !hasCredentials {
+			return "", false
+		}
+
+		// Hashes are compared in constant time, so the time taken does not depend on the password.
+
+		var expected, exists = passwords[user]
+		var expectedHash, passwordHash = sha256.Sum256([]byte(expected)), sha256.Sum256([]byte(password))
+
+		if (subtle.ConstantTimeCompare(expectedHash[:], passwordHash[:]) != 1) || !exists {
+			return "", false
+		}
+
+		return user, true
+	}
+}
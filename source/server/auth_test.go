@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBasicAuthentication(test *testing.T) {
+	var httpServer, _ = createTestServer(test, func(server *Server) {
+		server.SetAuthenticator(BasicAuthenticator(map[string]string{"alice": "secret"}))
+	})
+
+	var tests = []struct {
+		user     string
+		password string
+		status   int
+		expected string
+	}{
+		{"", "", http.StatusUnauthorized, "invalid credentials"},
+		{"alice", "secret", http.StatusOK, "user=alice"},
+		{"alice", "wrong", http.StatusUnauthorized, "invalid credentials"},
+		{"bob", "secret", http.StatusUnauthorized, "invalid credentials"},
+	}
+
+	for _, testCase := range tests {
+		var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/?cmd="+url.QueryEscape("CLIENT INFO"), nil)
+
+		if testCase.user != "" {
+			request.SetBasicAuth(testCase.user, testCase.password)
+		}
+
+		var status, body = doRequest(test, request)
+
+		if (status != testCase.status) || !strings.Contains(body, testCase.expected) {
+			test.Errorf("CLIENT INFO as %q = %d %q", testCase.user, status, body)
+		}
+	}
+}
+
+func TestAnonymousRequests(test *testing.T) {
+	var httpServer, _ = createTestServer(test, func(server *Server) {
+		server.SetAuthenticator(BasicAuthenticator(map[string]string{"alice": "secret"}))
+	})
+
+	// Anonymous clients cannot run admin commands nor watch the other clients.
+
+	for _, path := range []string{"/?cmd=FLUSHALL", "/?cmd=" + url.QueryEscape("CONFIG SET maxmemory 1"), "/monitor", "/replication", "/values/k"} {
+		var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+path, nil)
+
+		if status, body := doRequest(test, request); status != http.StatusUnauthorized {
+			test.Errorf("anonymous GET %s = %d %q, expected 401", path, status, body)
+		}
+	}
+
+	var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/?cmd=FLUSHALL", nil)
+	request.SetBasicAuth("alice", "secret")
+
+	if status, body := doRequest(test, request); status != http.StatusOK {
+		test.Errorf("FLUSHALL as alice = %d %q", status, body)
+	}
+}
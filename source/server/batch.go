@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"arc/vm"
+)
+
+const (
+	batchPath = "/batch"
+)
+
+/*
+
+Batch execution
+===============
+POST /batch[?stop-on-error=true][&typed=true]
+
+The body is either a JSON array (when sent as application/json) of commands, each one of them a command line string or
+an array of arguments, or newline delimited command lines (empty lines are ignored):
+
+["SET first 1", ["SET", "second", "hello world"], "INCR first"]
+
+The commands are executed in order (but not atomically, so commands from other clients may run between them) and the
+response is a JSON array with the result set of each command. With stop-on-error the execution stops at the first
+command that fails, so the commands after it are not executed and have no results. With typed, each result is an
+object with its type ("values", "nil" or "error") and its values, so stored values are never mistaken for nil or
+error results:
+
+[{"type": "values", "values": ["OK"]}, {"type": "nil", "values": ["(nil)"]}]
+
+*/
+
+// readBatch reads the command lines of a batch request body.
+func readBatch(request *http.Request) (lines []string, err error) {
+	if strings.Contains(request.Header.Get("Content-Type"), jsonContentType) {
+		var commands []json.RawMessage
+
+		if err = json.NewDecoder(request.Body).Decode(&commands); err != nil {
+			return
+		}
+
+		lines = make([]string, len(commands))
+
+		for index := range commands {
+			var arguments []string
+
+			if json.Unmarshal(commands[index], &lines[index]) == nil {
+				continue
+			}
+
+			if err = json.Unmarshal(commands[index], &arguments); err != nil {
+				return nil, errors.New("commands must be strings or arrays of strings")
+			}
+
+			lines[index] = vm.FormatCommandLine(arguments)
+		}
+
+		return
+	}
+
+	var reader = bufio.NewReader(request.Body)
+
+	for {
+		var line, readError = reader.ReadString('\n')
+
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+
+		if readError == io.EOF {
+			return lines, nil
+		} else if readError != nil {
+			return nil, readError
+		}
+	}
+}
+
+func (server *httpServer) serveBatch(response http.ResponseWriter, request *http.Request) {
+	var stopOnError, typed = false, false
+
+	for name, option := range map[string]*bool{"stop-on-error": &stopOnError, "typed": &typed} {
+		if value := request.URL.Query().Get(name); value != "" {
+			var err error
+
+			if *option, err = strconv.ParseBool(value); err != nil {
+				response.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	var lines, err = readBatch(request)
+
+	if err != nil {
+		server.writeBodyError(response, "invalid batch", err)
+		return
+	}
+
+	var execution = getExecutionContext(request)
+	var results = make([]vm.TypedResult, 0, len(lines))
+	var rateLimited = false
+
+	for _, line := range lines {
+		var result = server.runtime.ExecuteTyped(execution, line)
+
+		if result.Values == nil {
+			result.Values = []string{}
+		}
+
+		results = append(results, result)
+		rateLimited = rateLimited || isRateLimited(result)
+
+		if stopOnError && (result.Type == vm.ErrorResult) {
+			break
+		}
+	}
+
+	log.Printf("BATCH: %s executed %d of %d commands", request.RemoteAddr, len(results), len(lines))
+
+	var resultJSON []byte
+
+	if typed {
+		resultJSON, _ = json.Marshal(results)
+	} else {
+		var values = make([][]string, len(results))
+
+		for index := range results {
+			values[index] = results[index].Values
+		}
+
+		resultJSON, _ = json.Marshal(values)
+	}
+
+	// The other commands were executed, the rejected ones report their error and the response when to retry them.
+
+	if rateLimited {
+		setRetryAfter(response, request)
+	}
+
+	response.Header().Set("Content-Type", jsonContentType)
+	response.Write(resultJSON)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"arc/vm"
+)
+
+// Every command can be executed with POST /commands/NAME and its parameters as a JSON array of strings, like
+// POST /commands/SET with ["key", "hello world", "EX", "10"]. The parameters are used as is (no quoting is needed)
+// and the result is always returned as a JSON array. An empty body means no parameters.
+const commandsPath = "/commands/"
+
+func (server *httpServer) serveCommand(response http.ResponseWriter, request *http.Request, requestID string) {
+	var name = strings.TrimPrefix(request.URL.EscapedPath(), commandsPath)
+
+	if request.Method != http.MethodPost {
+		log.Printf("RESP(%s): 405", requestID)
+		response.Header().Set("Allow", http.MethodPost)
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if (name == "") || strings.Contains(name, "/") {
+		log.Printf("RESP(%s): 404", requestID)
+		http.Error(response, "no such command", http.StatusNotFound)
+		return
+	}
+
+	var parameters []string
+
+	if err := json.NewDecoder(request.Body).Decode(&parameters); (err != nil) && !errors.Is(err, io.EOF) {
+		log.Printf("RESP(%s): %d", requestID, server.writeBodyError(response, "invalid parameters, a JSON array of strings is expected", err))
+		return
+	}
+
+	var arguments = append([]string{name}, parameters...)
+
+	log.Printf("REQ(%s): %s", requestID, vm.FormatCommandLine(arguments))
+
+	var result = server.runtime.ExecuteArgsTyped(getExecutionContext(request), arguments)
+
+	if address, _, isRedirection := vm.GetRedirection(result.Values); isRedirection {
+		log.Printf("RESP(%s): 307 %s", requestID, address)
+		http.Redirect(response, request, "http://"+address+request.URL.RequestURI(), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if writeRateLimited(response, request, result) {
+		log.Printf("RESP(%s): 429 %s", requestID, result.Values[0])
+		return
+	}
+
+	if result.Values == nil {
+		result.Values = []string{}
+	}
+
+	log.Printf("RESP(%s): %s", requestID, strings.Join(result.Values, " "))
+
+	var resultJSON, _ = json.Marshal(result.Values)
+	response.Header().Set("Content-Type", jsonContentType)
+	response.Write(resultJSON)
+}
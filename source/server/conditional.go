@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"arc/vm"
+)
+
+// Conditional requests use the version of the route key as entity tag, so clients can read-modify-write values without
+// transactions: the value is read with its ETag and written back with If-Match, failing with 412 when another client
+// changed it meanwhile. With If-None-Match: * values are only created (never replaced), and reads with If-None-Match
+// return 304 while the value is unchanged.
+
+// formatETag returns the entity tag of a key version.
+func formatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 36) + `"`
+}
+
+// matchETags returns if an entity tag list matches the version of a key, "*" matches any existing key. Weak tags (W/)
+// only match when using the weak comparison.
+func matchETags(tags string, version uint64, weak bool) bool {
+	if version == 0 {
+		return false
+	}
+
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+
+		if (tag == "*") || (tag == formatETag(version)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinHeaderValues joins the values of a list header given in many lines.
+func joinHeaderValues(values []string) string {
+	return strings.Join(values, ",")
+}
+
+// getCondition returns the key version condition of a request to a key, checking the If-Match and If-None-Match
+// headers (if any).
+func getCondition(request *http.Request, key string) (condition *vm.Condition) {
+	var ifMatch = joinHeaderValues(request.Header.Values("If-Match"))
+	var ifNoneMatch = joinHeaderValues(request.Header.Values("If-None-Match"))
+
+	condition = &vm.Condition{Key: key}
+
+	if (ifMatch != "") || (ifNoneMatch != "") {
+		condition.Check = func(version uint64) bool {
+			return ((ifMatch == "") || matchETags(ifMatch, version, false)) &&
+				((ifNoneMatch == "") || !matchETags(ifNoneMatch, version, true))
+		}
+	}
+
+	return
+}
+
+// writeConditionalResult sets the ETag header of a successful result, or writes the response of a failed
+// precondition: 304 for reads whose If-None-Match matched, 412 otherwise. Returns the status when it was written.
+func writeConditionalResult(response http.ResponseWriter, request *http.Request, condition *vm.Condition, result vm.TypedResult) (status int) {
+	var err error
+
+	if result.Type == vm.ErrorResult {
+		_, err = result.Get()
+	}
+
+	if (condition.Version != 0) && ((err == nil) || errors.Is(err, vm.ErrPreconditionFailed)) {
+		response.Header().Set("ETag", formatETag(condition.Version))
+	}
+
+	if !errors.Is(err, vm.ErrPreconditionFailed) {
+		return 0
+	}
+
+	var ifMatch = joinHeaderValues(request.Header.Values("If-Match"))
+
+	if (request.Method == http.MethodGet) && ((ifMatch == "") || matchETags(ifMatch, condition.Version, false)) {
+		status = http.StatusNotModified
+		response.WriteHeader(status)
+	} else {
+		status = http.StatusPreconditionFailed
+		http.Error(response, result.Values[0], status)
+	}
+
+	return
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchETags(test *testing.T) {
+	var tag = formatETag(42)
+
+	var tests = []struct {
+		tags     string
+		version  uint64
+		weak     bool
+		expected bool
+	}{
+		{tag, 42, false, true},
+		{tag, 43, false, false},
+		{`"a", ` + tag, 42, false, true},
+		{"*", 42, false, true},
+		{"*", 0, false, false},
+		{tag, 0, false, false},
+		{"W/" + tag, 42, false, false},
+		{"W/" + tag, 42, true, true},
+	}
+
+	for _, testCase := range tests {
+		if matched := matchETags(testCase.tags, testCase.version, testCase.weak); matched != testCase.expected {
+			test.Errorf("matchETags(%q, %d, %t) = %t", testCase.tags, testCase.version, testCase.weak, matched)
+		}
+	}
+}
+
+// sendConditional sends a request with a conditional header (when given), and returns the response status, ETag and
+// body.
+func sendConditional(test *testing.T, httpServer *httptest.Server, method string, path string, body string, header string, tag string) (status int, etag string, responseBody string) {
+	var request, _ = http.NewRequest(method, httpServer.URL+path, strings.NewReader(body))
+
+	if header != "" {
+		request.Header.Set(header, tag)
+	}
+
+	var response, err = http.DefaultClient.Do(request)
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	var data, _ = io.ReadAll(response.Body)
+	return response.StatusCode, response.Header.Get("ETag"), string(data)
+}
+
+func TestConditionalRequests(test *testing.T) {
+	var httpServer, _ = createTestServer(test, nil)
+
+	// Writes and reads return the ETag of the key.
+
+	var status, etag, body = sendConditional(test, httpServer, http.MethodPut, "/values/k", "v1", "", "")
+
+	if (status != http.StatusOK) || (etag == "") {
+		test.Fatalf("PUT /values/k = %d, ETag %q", status, etag)
+	}
+
+	if status, readETag, body := sendConditional(test, httpServer, http.MethodGet, "/values/k", "", "", ""); (status != http.StatusOK) || (readETag != etag) || (body != "v1") {
+		test.Errorf("GET /values/k = %d %q, ETag %q, expected %q", status, body, readETag, etag)
+	}
+
+	var tests = []struct {
+		method string
+		header string
+		tag    string
+		body   string
+		status int
+	}{
+		// Reads of an unchanged value are not modified.
+		{http.MethodGet, "If-None-Match", etag, "", http.StatusNotModified},
+		{http.MethodGet, "If-None-Match", "W/" + etag, "", http.StatusNotModified},
+		{http.MethodGet, "If-None-Match", `"other"`, "", http.StatusOK},
+		{http.MethodGet, "If-Match", `"other"`, "", http.StatusPreconditionFailed},
+
+		// Writes of another version or creations of an existing key fail.
+		{http.MethodPut, "If-Match", `"other"`, "lost", http.StatusPreconditionFailed},
+		{http.MethodPut, "If-None-Match", "*", "lost", http.StatusPreconditionFailed},
+		{http.MethodDelete, "If-Match", `"other"`, "", http.StatusPreconditionFailed},
+	}
+
+	for _, testCase := range tests {
+		var status, responseETag, body = sendConditional(test, httpServer, testCase.method, "/values/k", testCase.body, testCase.header, testCase.tag)
+
+		if (status != testCase.status) || (responseETag != etag) {
+			test.Errorf("%s /values/k with %s: %s = %d %q, ETag %q, expected %d", testCase.method, testCase.header, testCase.tag,
+				status, body, responseETag, testCase.status)
+		}
+	}
+
+	if _, _, body = sendConditional(test, httpServer, http.MethodGet, "/values/k", "", "", ""); body != "v1" {
+		test.Errorf("GET /values/k after failed preconditions = %q, expected v1", body)
+	}
+
+	// A write of the current version changes the ETag, so the former one does not match anymore.
+
+	var newETag string
+
+	if status, newETag, _ = sendConditional(test, httpServer, http.MethodPut, "/values/k", "v2", "If-Match", etag); (status != http.StatusOK) || (newETag == etag) {
+		test.Fatalf("PUT /values/k with If-Match = %d, ETag %q (was %q)", status, newETag, etag)
+	}
+
+	if status, _, _ = sendConditional(test, httpServer, http.MethodGet, "/values/k", "", "If-None-Match", etag); status != http.StatusOK {
+		test.Errorf("GET /values/k with the former ETag = %d, expected 200", status)
+	}
+
+	if status, _, _ = sendConditional(test, httpServer, http.MethodPut, "/values/k", "v3", "If-Match", etag); status != http.StatusPreconditionFailed {
+		test.Errorf("PUT /values/k with the former ETag = %d, expected 412", status)
+	}
+
+	// Missing keys are created with If-None-Match: *, and have no ETag.
+
+	if status, etag, _ = sendConditional(test, httpServer, http.MethodPut, "/values/new", "v", "If-None-Match", "*"); (status != http.StatusOK) || (etag == "") {
+		test.Errorf("PUT /values/new with If-None-Match: * = %d, ETag %q", status, etag)
+	}
+
+	if status, etag, _ = sendConditional(test, httpServer, http.MethodGet, "/values/missing", "", "", ""); (status != http.StatusNotFound) || (etag != "") {
+		test.Errorf("GET /values/missing = %d, ETag %q", status, etag)
+	}
+}
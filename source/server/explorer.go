@@ -0,0 +1,20 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The API explorer is a single self-contained page (no external scripts or styles) that reads the OpenAPI document
+// and sends requests to the server.
+
+const explorerPath = "/explorer"
+
+//go:embed explorer.html
+var explorerPage []byte
+
+// serveExplorer serves the interactive API explorer page.
+func (server *httpServer) serveExplorer(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	response.Write(explorerPage)
+}
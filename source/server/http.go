@@ -1,8 +1,10 @@
 package server
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
@@ -15,38 +17,214 @@ import (
 type (
 	httpServer struct {
 		http.Handler
-		runtime *vm.Runtime
+		runtime       *vm.Runtime
+		stats         *serverStats
+		authenticator Authenticator
+		limits        Limits
+		rateLimiter   *rateLimiter
+		requests      *requestLimiter
+		router        *restRouter
+		openAPI       []byte
+	}
+
+	// userContextKey is the request context key of the authenticated user.
+	userContextKey struct{}
+
+	endpointHandler func(server *httpServer, response http.ResponseWriter, request *http.Request)
+
+	endpoint struct {
+		method  string
+		handler endpointHandler
+
+		// streaming endpoints serve long lived responses, not limited by the concurrent requests.
+		streaming bool
 	}
 )
 
+// Results are sent as a JSON array (instead of space separated values) when the client accepts this content type.
+const jsonContentType = "application/json"
+
+// Non REST endpoints, each one of them accepts a single method.
+var endpoints = map[string]endpoint{
+	metricsPath:     {method: http.MethodGet, handler: (*httpServer).serveMetrics},
+	monitorPath:     {method: http.MethodGet, handler: (*httpServer).serveMonitor, streaming: true},
+	subscribePath:   {method: http.MethodGet, handler: (*httpServer).serveSubscribe, streaming: true},
+	replicationPath: {method: http.MethodGet, handler: (*httpServer).serveReplication, streaming: true},
+	batchPath:       {method: http.MethodPost, handler: (*httpServer).serveBatch},
+	openAPIPath:     {method: http.MethodGet, handler: (*httpServer).serveOpenAPI},
+	explorerPath:    {method: http.MethodGet, handler: (*httpServer).serveExplorer},
+	watchPath:       {method: http.MethodGet, handler: (*httpServer).serveWatch, streaming: true},
+}
+
 func (server *httpServer) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	var idString = request.RequestURI + strconv.FormatInt(time.Now().Unix(), 10)
 	var requestHash = md5.Sum([]byte(idString))
 	var requestID = hex.EncodeToString(requestHash[:])
-	var commandLine = ""
-
 	log.Printf("REQ(%s): %s", requestID, request.RequestURI)
 
-	if (request.Method == http.MethodGet) && (request.URL.EscapedPath() == "/") {
-		commandLine = request.URL.Query().Get("cmd")
+	server.stats.recordRequest(request)
+
+	if server.authenticator != nil {
+		var user, valid = server.authenticator(request)
+
+		if !valid {
+			log.Printf("RESP(%s): 401", requestID)
+			response.Header().Set("WWW-Authenticate", `Basic realm="arc"`)
+			http.Error(response, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+
+		request = request.WithContext(context.WithValue(request.Context(), userContextKey{}, user))
+	}
+
+	if server.rateLimiter != nil {
+		request = server.rateLimiter.withRateLimit(request)
+	}
+
+	if !server.limitBody(response, request) {
+		log.Printf("RESP(%s): 413", requestID)
+		return
+	}
+
+	var endpoint, isEndpoint = endpoints[request.URL.EscapedPath()]
+
+	if isEndpoint && (request.Method != endpoint.method) {
+		log.Printf("RESP(%s): 405", requestID)
+		response.Header().Set("Allow", endpoint.method)
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var holdsSlot = false
+
+	if !endpoint.streaming {
+		if !server.requests.tryAcquire() {
+			log.Printf("RESP(%s): 503 too many concurrent requests", requestID)
+			server.writeBusy(response)
+			return
+		}
+
+		holdsSlot = true
+
+		defer func() {
+			if holdsSlot {
+				server.requests.release()
+			}
+		}()
+	}
+
+	if isEndpoint {
+		endpoint.handler(server, response, request)
+		return
+	}
+
+	if strings.HasPrefix(request.URL.EscapedPath(), commandsPath) {
+		server.serveCommand(response, request, requestID)
+		return
+	}
+
+	var result vm.TypedResult
+	var route *restRoute
+	var condition *vm.Condition
+	var isREST = (request.Method != http.MethodGet) || (request.URL.EscapedPath() != "/")
+
+	if isREST {
+		var restRequest, ok = server.getRESTRequest(response, request)
+
+		if !ok {
+			log.Printf("RESP(%s): REST request rejected", requestID)
+			return
+		}
+
+		var execution = getExecutionContext(request)
+		route = restRequest.route
+
+		if restRequest.hasKey {
+			execution.Condition = getCondition(request, restRequest.key)
+		}
+
+		if restRequest.watchTimeout > 0 {
+			// Long polls don't hold their request slot while waiting.
+
+			log.Printf("REQ(%s): watching %q for %v", requestID, restRequest.key, restRequest.watchTimeout)
+			server.requests.release()
+			holdsSlot = false
+			server.waitForChange(response, request, restRequest.key, restRequest.watchTimeout)
+
+			if holdsSlot = server.requests.acquire(request.Context()); !holdsSlot {
+				log.Printf("RESP(%s): canceled while waiting for a request slot", requestID)
+				return
+			}
+		}
+
+		log.Printf("REQ(%s): %s", requestID, vm.FormatCommandLine(restRequest.arguments))
+		result = server.runtime.ExecuteArgsTyped(execution, restRequest.arguments)
+		condition = execution.Condition
 	} else {
-		commandLine = buildCommandLineFromREST(request)
+		var commandLine = request.URL.Query().Get("cmd")
+
+		if commandLine == "" {
+			log.Printf("RESP(%s): 400", requestID)
+			response.WriteHeader(400)
+			return
+		}
+
+		log.Printf("REQ(%s): %s", requestID, commandLine)
+		result = server.runtime.ExecuteTyped(getExecutionContext(request), commandLine)
 	}
 
-	if commandLine == "" {
-		log.Printf("RESP(%s): 400", requestID)
-		response.WriteHeader(400)
+	// REST clients are redirected with HTTP, the command line endpoint returns the MOVED / ASK result as is.
+
+	if address, _, isRedirection := vm.GetRedirection(result.Values); isREST && isRedirection {
+		log.Printf("RESP(%s): 307 %s", requestID, address)
+		http.Redirect(response, request, "http://"+address+request.URL.RequestURI(), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if writeRateLimited(response, request, result) {
+		log.Printf("RESP(%s): 429 %s", requestID, result.Values[0])
 		return
 	}
 
-	log.Printf("REQ(%s): %s", requestID, commandLine)
+	if condition != nil {
+		if status := writeConditionalResult(response, request, condition, result); status != 0 {
+			log.Printf("RESP(%s): %d", requestID, status)
+			return
+		}
+	}
+
+	var acceptsJSON = strings.Contains(request.Header.Get("Accept"), jsonContentType)
+
+	if (route != nil) && route.Raw && !acceptsJSON {
+		log.Printf("RESP(%s): %d raw value", requestID, writeRawResult(response, result))
+		return
+	}
 
-	if result := server.runtime.Execute(commandLine); result != nil {
-		var resultString = strings.Join(result, " ")
+	if result.Values != nil {
+		var resultString = strings.Join(result.Values, " ")
 		log.Printf("RESP(%s): %s", requestID, resultString)
-		response.Write([]byte(resultString))
+
+		if acceptsJSON {
+			var resultJSON, _ = json.Marshal(result.Values)
+			response.Header().Set("Content-Type", jsonContentType)
+			response.Write(resultJSON)
+		} else {
+			response.Write([]byte(resultString))
+		}
 	} else {
 		log.Printf("RESP(%s): 500", requestID)
 		response.WriteHeader(500)
 	}
 }
+
+// getExecutionContext returns the execution context of the commands of a request.
+func getExecutionContext(request *http.Request) vm.ExecutionContext {
+	var user, _ = request.Context().Value(userContextKey{}).(string)
+
+	return vm.ExecutionContext{
+		Context: request.Context(),
+		Client:  request.RemoteAddr,
+		User:    user,
+		Asking:  request.Header.Get(vm.AskingHeader) != "",
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// Limits protect the server from slow or abusive clients, zero values disable a limit. The limits of the command
+	// arguments are runtime configuration parameters (max-arguments and max-argument-length).
+	Limits struct {
+		// ReadHeaderTimeout is the time allowed to read the request headers, and ReadTimeout to read the whole
+		// request. WriteTimeout is the time allowed to serve the request and write its response. Event streams and
+		// long polls are not limited by the read and write timeouts once their headers were read.
+		ReadHeaderTimeout time.Duration
+		ReadTimeout       time.Duration
+		WriteTimeout      time.Duration
+
+		// IdleTimeout is the time idle keep-alive connections are kept open.
+		IdleTimeout time.Duration
+
+		// MaxHeaderBytes is the maximum size of the request headers (including the request line).
+		MaxHeaderBytes int
+
+		// MaxBodySize is the maximum size of the request bodies in bytes, larger bodies are rejected with 413.
+		MaxBodySize int64
+
+		// MaxConnections is the maximum number of open client connections, new connections are answered with 503
+		// and closed.
+		MaxConnections int
+
+		// MaxConcurrentRequests is the maximum number of requests served at the same time, new requests are answered
+		// with 503. Event streams (and long polls while waiting) are only limited by the connections.
+		MaxConcurrentRequests int
+	}
+
+	// requestLimiter limits the number of requests served at the same time (there is no limit without slots).
+	requestLimiter struct {
+		slots chan struct{}
+	}
+
+	// limitListener limits the number of open connections of a listener.
+	limitListener struct {
+		net.Listener
+		slots chan struct{}
+		stats *serverStats
+	}
+
+	limitConnection struct {
+		net.Conn
+		listener    *limitListener
+		releaseOnce sync.Once
+	}
+)
+
+// Retry-After delay (in seconds) of the requests rejected because the server is busy.
+const busyRetryAfter = 1
+
+// DefaultLimits returns the default server limits.
+func DefaultLimits() Limits {
+	return Limits{
+		ReadHeaderTimeout:     10 * time.Second,
+		ReadTimeout:           time.Minute,
+		WriteTimeout:          time.Minute,
+		IdleTimeout:           2 * time.Minute,
+		MaxHeaderBytes:        http.DefaultMaxHeaderBytes,
+		MaxBodySize:           64 * 1024 * 1024,
+		MaxConnections:        10000,
+		MaxConcurrentRequests: 1024,
+	}
+}
+
+func createRequestLimiter(maxRequests int) (limiter *requestLimiter) {
+	limiter = &requestLimiter{}
+
+	if maxRequests > 0 {
+		limiter.slots = make(chan struct{}, maxRequests)
+	}
+
+	return
+}
+
+// tryAcquire takes a request slot if there is one available.
+func (limiter *requestLimiter) tryAcquire() bool {
+	if limiter.slots == nil {
+		return true
+	}
+
+	select {
+	case limiter.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire takes a request slot, waiting until there is one available or the context is done. Returns false when no
+// slot was taken.
+func (limiter *requestLimiter) acquire(ctx context.Context) bool {
+	if limiter.slots == nil {
+		return true
+	}
+
+	select {
+	case limiter.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (limiter *requestLimiter) release() {
+	if limiter.slots != nil {
+		<-limiter.slots
+	}
+}
+
+// createLimitListener wraps a listener to accept up to maxConnections open connections at the same time.
+func createLimitListener(listener net.Listener, maxConnections int, stats *serverStats) net.Listener {
+	return &limitListener{
+		Listener: listener,
+		slots:    make(chan struct{}, maxConnections),
+		stats:    stats,
+	}
+}
+
+// Accept waits for the next connection, the connections over the limit are answered with 503 and closed.
+func (listener *limitListener) Accept() (net.Conn, error) {
+	for {
+		var connection, err = listener.Listener.Accept()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case listener.slots <- struct{}{}:
+			return &limitConnection{Conn: connection, listener: listener}, nil
+		default:
+			listener.stats.rejectedConnections.Increment()
+			go rejectConnection(connection)
+		}
+	}
+}
+
+// rejectConnection answers a connection over the limit with a 503 response and closes it.
+func rejectConnection(connection net.Conn) {
+	defer connection.Close()
+
+	const message = "too many connections\n"
+
+	log.Printf("CONN: %s rejected, too many connections", connection.RemoteAddr())
+	connection.SetWriteDeadline(time.Now().Add(time.Second))
+
+	io.WriteString(connection, "HTTP/1.1 503 Service Unavailable\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"Content-Length: "+strconv.Itoa(len(message))+"\r\n"+
+		"Retry-After: "+strconv.Itoa(busyRetryAfter)+"\r\n"+
+		"Connection: close\r\n\r\n"+message)
+}
+
+// Close closes the connection, releasing its slot.
+func (connection *limitConnection) Close() error {
+	var err = connection.Conn.Close()
+
+	connection.releaseOnce.Do(func() {
+		<-connection.listener.slots
+	})
+
+	return err
+}
+
+// writeBusy answers a request rejected because the server is serving too many requests.
+func (server *httpServer) writeBusy(response http.ResponseWriter) {
+	server.stats.rejectedRequests.Increment()
+	response.Header().Set("Retry-After", strconv.Itoa(busyRetryAfter))
+	http.Error(response, "too many concurrent requests", http.StatusServiceUnavailable)
+}
+
+// limitBody rejects the requests with a declared body larger than the maximum body size, and limits the body size of
+// the other requests (see writeBodyError).
+func (server *httpServer) limitBody(response http.ResponseWriter, request *http.Request) bool {
+	if server.limits.MaxBodySize <= 0 {
+		return true
+	}
+
+	if request.ContentLength > server.limits.MaxBodySize {
+		server.stats.rejectedBodies.Increment()
+		http.Error(response, fmt.Sprintf("request body too large, max %d bytes", server.limits.MaxBodySize), http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	request.Body = http.MaxBytesReader(response, request.Body, server.limits.MaxBodySize)
+	return true
+}
+
+// writeBodyError answers a request whose body could not be read: 413 when it is too large, and 400 otherwise.
+func (server *httpServer) writeBodyError(response http.ResponseWriter, message string, err error) (status int) {
+	var maxBytesError *http.MaxBytesError
+
+	if errors.As(err, &maxBytesError) {
+		status = http.StatusRequestEntityTooLarge
+		server.stats.rejectedBodies.Increment()
+		http.Error(response, fmt.Sprintf("request body too large, max %d bytes", maxBytesError.Limit), status)
+	} else {
+		status = http.StatusBadRequest
+		http.Error(response, fmt.Sprintf("%s: %v", message, err), status)
+	}
+
+	return
+}
+
+// disableDeadlines removes the connection read and write deadlines of long lived responses, so the server timeouts
+// don't close them.
+func disableDeadlines(response http.ResponseWriter) {
+	var controller = http.NewResponseController(response)
+
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiter(test *testing.T) {
+	var limiter = createRequestLimiter(1)
+
+	if !limiter.tryAcquire() || limiter.tryAcquire() {
+		test.Fatal("a single request slot is expected")
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if limiter.acquire(ctx) {
+		test.Error("acquire took a slot that was not available")
+	}
+
+	limiter.release()
+
+	if !limiter.acquire(context.Background()) {
+		test.Error("acquire did not take the available slot")
+	}
+
+	var unlimited = createRequestLimiter(0)
+
+	for index := 0; index < 3; index++ {
+		if !unlimited.tryAcquire() {
+			test.Error("requests are not limited without max requests")
+		}
+	}
+}
+
+func TestConcurrentRequestsLimit(test *testing.T) {
+	var httpServer, _ = createTestServer(test, func(server *Server) {
+		var limits = DefaultLimits()
+		limits.MaxConcurrentRequests = 1
+		server.SetLimits(limits)
+	})
+
+	// A batch whose body is not sent yet holds the only request slot.
+
+	var bodyReader, bodyWriter = io.Pipe()
+	var pending = make(chan int, 1)
+
+	go func() {
+		var response, err = http.Post(httpServer.URL+"/batch", jsonContentType, bodyReader)
+
+		if err != nil {
+			pending <- 0
+			return
+		}
+
+		response.Body.Close()
+		pending <- response.StatusCode
+	}()
+
+	var deadline = time.Now().Add(5 * time.Second)
+	var response *http.Response
+
+	for {
+		var err error
+
+		if response, err = http.Get(httpServer.URL + "/?cmd=GET%20a"); err != nil {
+			test.Fatal(err)
+		}
+
+		response.Body.Close()
+
+		if (response.StatusCode == http.StatusServiceUnavailable) || time.Now().After(deadline) {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if (response.StatusCode != http.StatusServiceUnavailable) || (response.Header.Get("Retry-After") != "1") {
+		test.Errorf("request over the limit = %d, Retry-After %q", response.StatusCode, response.Header.Get("Retry-After"))
+	}
+
+	io.WriteString(bodyWriter, `["SET a 1"]`)
+	bodyWriter.Close()
+
+	if status := <-pending; status != http.StatusOK {
+		test.Errorf("batch holding the request slot = %d", status)
+	}
+
+	if status, body := executeLine(test, httpServer, "GET a"); (status != http.StatusOK) || (body != "1") {
+		test.Errorf("request after the slot was released = %d %q", status, body)
+	}
+}
+
+func TestMaxBodySize(test *testing.T) {
+	var httpServer, _ = createTestServer(test, func(server *Server) {
+		var limits = DefaultLimits()
+		limits.MaxBodySize = 16
+		server.SetLimits(limits)
+	})
+
+	var tests = []struct {
+		method string
+		path   string
+		body   io.Reader
+		status int
+	}{
+		{http.MethodPut, "/values/small", strings.NewReader("hello"), http.StatusOK},
+		{http.MethodPut, "/values/large", strings.NewReader(strings.Repeat("x", 17)), http.StatusRequestEntityTooLarge},
+
+		// Bodies of unknown length are sent chunked, and rejected while they are read.
+		{http.MethodPost, "/batch", io.MultiReader(strings.NewReader(`["SET a 1"]`)), http.StatusOK},
+		{http.MethodPost, "/batch", io.MultiReader(strings.NewReader(`["SET large 0123456789"]`)), http.StatusRequestEntityTooLarge},
+		{http.MethodPost, "/commands/SET", io.MultiReader(strings.NewReader(`["large", "0123456789"]`)), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, testCase := range tests {
+		var request, _ = http.NewRequest(testCase.method, httpServer.URL+testCase.path, testCase.body)
+
+		if status, body := doRequest(test, request); status != testCase.status {
+			test.Errorf("%s %s = %d %q, expected %d", testCase.method, testCase.path, status, body, testCase.status)
+		}
+	}
+
+	if status, body := executeLine(test, httpServer, "GET large"); body != "(nil)" {
+		test.Errorf("GET large = %d %q, the rejected values must not be set", status, body)
+	}
+}
+
+func TestMaxConnections(test *testing.T) {
+	var httpServer, _ = createTestServer(test, func(server *Server) {
+		var limits = DefaultLimits()
+		limits.MaxConnections = 1
+		server.SetLimits(limits)
+	})
+
+	var address = strings.TrimPrefix(httpServer.URL, "http://")
+	var first, err = net.Dial("tcp", address)
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	defer first.Close()
+
+	var second net.Conn
+
+	if second, err = net.Dial("tcp", address); err != nil {
+		test.Fatal(err)
+	}
+
+	defer second.Close()
+
+	io.WriteString(second, "GET /?cmd=GET%20a HTTP/1.1\r\nHost: "+address+"\r\n\r\n")
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var response *http.Response
+
+	if response, err = http.ReadResponse(bufio.NewReader(second), nil); err != nil {
+		test.Fatal(err)
+	}
+
+	response.Body.Close()
+
+	if (response.StatusCode != http.StatusServiceUnavailable) || (response.Header.Get("Retry-After") != "1") {
+		test.Errorf("connection over the limit = %d, Retry-After %q", response.StatusCode, response.Header.Get("Retry-After"))
+	}
+
+	// Closing the first connection releases its slot.
+
+	first.Close()
+
+	var client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	var deadline = time.Now().Add(5 * time.Second)
+
+	for {
+		if response, err = client.Get(httpServer.URL + "/?cmd=GET%20a"); err == nil {
+			response.Body.Close()
+
+			if response.StatusCode == http.StatusOK {
+				break
+			}
+		}
+
+		if time.Now().After(deadline) {
+			test.Fatalf("connection after the slot was released = %v %v", response, err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"arc/metrics"
+)
+
+const (
+	metricsPath        = "/metrics"
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type (
+	serverStats struct {
+		activeConnections metrics.Gauge
+		totalConnections  metrics.Counter
+		requestSizes      *metrics.Histogram
+
+		rejectedConnections metrics.Counter
+		rejectedRequests    metrics.Counter
+		rejectedBodies      metrics.Counter
+		rejectedRateLimit   metrics.Counter
+	}
+)
+
+func createServerStats() *serverStats {
+	return &serverStats{
+		requestSizes: metrics.CreateHistogram(metrics.SizeBuckets),
+	}
+}
+
+// trackConnection is used as the HTTP server connection state hook to count client connections.
+func (stats *serverStats) trackConnection(connection net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		stats.activeConnections.Add(1)
+		stats.totalConnections.Increment()
+	case http.StateHijacked, http.StateClosed:
+		stats.activeConnections.Add(-1)
+	}
+}
+
+func (stats *serverStats) recordRequest(request *http.Request) {
+	var size = int64(len(request.RequestURI))
+
+	if request.ContentLength > 0 {
+		size += request.ContentLength
+	}
+
+	stats.requestSizes.Observe(float64(size))
+}
+
+func (server *httpServer) serveMetrics(response http.ResponseWriter, request *http.Request) {
+	var writer = metrics.CreateWriter(response)
+
+	response.Header().Set("Content-Type", metricsContentType)
+
+	writer.Header("arc_connections", metrics.GaugeType, "Number of open client connections.")
+	writer.Value("arc_connections", nil, float64(server.stats.activeConnections.Get()))
+
+	writer.Header("arc_connections_total", metrics.CounterType, "Number of accepted client connections.")
+	writer.Value("arc_connections_total", nil, float64(server.stats.totalConnections.Get()))
+
+	writer.Header("arc_request_size_bytes", metrics.HistogramType, "Size of the received requests (URI and body).")
+	writer.Histogram("arc_request_size_bytes", nil, server.stats.requestSizes)
+
+	writer.Header("arc_rejected_total", metrics.CounterType, "Number of connections, requests and commands rejected by the server limits.")
+	writer.Value("arc_rejected_total", metrics.Labels{"reason": "connections"}, float64(server.stats.rejectedConnections.Get()))
+	writer.Value("arc_rejected_total", metrics.Labels{"reason": "requests"}, float64(server.stats.rejectedRequests.Get()))
+	writer.Value("arc_rejected_total", metrics.Labels{"reason": "body_size"}, float64(server.stats.rejectedBodies.Get()))
+	writer.Value("arc_rejected_total", metrics.Labels{"reason": "rate_limit"}, float64(server.stats.rejectedRateLimit.Get()))
+
+	server.runtime.WriteMetrics(writer)
+}
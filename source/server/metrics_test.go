@@ -0,0 +1,135 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"arc/database"
+	"arc/vm"
+)
+
+// createTestServer creates a test HTTP server for a new database server (with its connections limit, like Run), closed
+// (with its database) at the end of the test.
+func createTestServer(test *testing.T, configure func(server *Server)) (*httptest.Server, *vm.Runtime) {
+	var testDB = database.Create()
+	var runtime = vm.CreateRuntime(vm.StandardLibrary, testDB)
+	var server = Create("localhost:0", runtime)
+
+	test.Cleanup(testDB.Close)
+
+	if configure != nil {
+		configure(server)
+	}
+
+	var httpServer = httptest.NewUnstartedServer(server.Handler())
+	httpServer.Config.ConnState = server.stats.trackConnection
+
+	if server.limits.MaxConnections > 0 {
+		httpServer.Listener = createLimitListener(httpServer.Listener, server.limits.MaxConnections, server.stats)
+	}
+
+	httpServer.Start()
+	test.Cleanup(httpServer.Close)
+
+	return httpServer, runtime
+}
+
+// executeLine executes a command line with the command line endpoint, and returns the response status and body.
+func executeLine(test *testing.T, httpServer *httptest.Server, line string) (status int, body string) {
+	var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/?cmd="+url.QueryEscape(line), nil)
+	return doRequest(test, request)
+}
+
+// doRequest sends a request, and returns the response status and body.
+func doRequest(test *testing.T, request *http.Request) (status int, body string) {
+	var response, err = http.DefaultClient.Do(request)
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	var data, _ = io.ReadAll(response.Body)
+	return response.StatusCode, string(data)
+}
+
+func TestMetrics(test *testing.T) {
+	var httpServer, _ = createTestServer(test, nil)
+
+	for _, line := range []string{"SET a 1", "SET b 2", "ZADD z 1 one", "INCR a", "INCR z"} {
+		executeLine(test, httpServer, line)
+	}
+
+	var response, err = http.Get(httpServer.URL + metricsPath)
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	if contentType := response.Header.Get("Content-Type"); contentType != metricsContentType {
+		test.Errorf("content type = %q", contentType)
+	}
+
+	var body, _ = io.ReadAll(response.Body)
+	var lines = strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	var sample = regexp.MustCompile(`^([a-z_]+)(\{[a-z_]+="[^"]*"(,[a-z_]+="[^"]*")*\})? ([0-9.e+-]+|\+Inf)$`)
+	var families = make(map[string]string)
+	var family string
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "# TYPE ") {
+			var fields = strings.Fields(line)
+			family = fields[2]
+			families[family] = fields[3]
+			continue
+		}
+
+		var match = sample.FindStringSubmatch(line)
+
+		if match == nil {
+			test.Errorf("invalid sample line %q", line)
+			continue
+		}
+
+		if (match[1] != family) && !((families[family] == "histogram") &&
+			((match[1] == family+"_bucket") || (match[1] == family+"_sum") || (match[1] == family+"_count"))) {
+			test.Errorf("sample %q outside of its family %s", line, family)
+		}
+	}
+
+	var expected = []string{
+		`arc_commands_total{command="SET"} 2`,
+		`arc_commands_total{command="INCR"} 2`,
+		`arc_command_errors_total{kind="invalid_data_type"} 1`,
+		`arc_command_duration_seconds_count{command="ZADD"} 1`,
+		`arc_keys{type="string"} 2`,
+		`arc_keys{type="zset"} 1`,
+		`arc_connections_total 1`,
+		`arc_request_size_bytes_count 6`,
+		`arc_rejected_total{reason="requests"} 0`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(string(body), line+"\n") {
+			test.Errorf("missing metric %q", line)
+		}
+	}
+
+	for name, metricType := range map[string]string{"arc_connections": "gauge", "arc_commands_total": "counter", "arc_command_duration_seconds": "histogram"} {
+		if families[name] != metricType {
+			test.Errorf("%s type = %q, expected %s", name, families[name], metricType)
+		}
+	}
+}
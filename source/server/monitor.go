@@ -0,0 +1,35 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"arc/vm"
+)
+
+const (
+	monitorPath = "/monitor"
+)
+
+// serveMonitor streams every command processed by the runtime as Server-Sent Events.
+func (server *httpServer) serveMonitor(response http.ResponseWriter, request *http.Request) {
+	var stream, ok = createEventStream(response)
+
+	if !ok {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var monitor = server.runtime.Monitor()
+	defer monitor.Close()
+
+	log.Printf("MON: %s started monitoring", request.RemoteAddr)
+
+	defer func() {
+		log.Printf("MON: %s stopped monitoring (%d events dropped)", request.RemoteAddr, monitor.GetDropped())
+	}()
+
+	streamEvents(request.Context(), stream, monitor.Events(), monitor.GetDropped, func(event vm.MonitorEvent) error {
+		return stream.send("", event.String())
+	})
+}
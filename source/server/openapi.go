@@ -0,0 +1,543 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"arc/vm"
+)
+
+// The OpenAPI 3 document of the server is generated from the command REST routes and metadata, so it never needs to
+// be updated by hand.
+
+const (
+	openAPIPath    = "/openapi.json"
+	openAPIVersion = "3.0.3"
+)
+
+type (
+	openAPIDocument struct {
+		OpenAPI    string                     `json:"openapi"`
+		Info       openAPIInfo                `json:"info"`
+		Tags       []openAPITag               `json:"tags,omitempty"`
+		Paths      map[string]openAPIPathItem `json:"paths"`
+		Components openAPIComponents          `json:"components"`
+	}
+
+	openAPIInfo struct {
+		Title       string `json:"title"`
+		Description string `json:"description,omitempty"`
+		Version     string `json:"version"`
+	}
+
+	openAPITag struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+	}
+
+	// openAPIPathItem holds the operations of a path by (lower case) method.
+	openAPIPathItem map[string]*openAPIOperation
+
+	openAPIOperation struct {
+		OperationID string                     `json:"operationId"`
+		Summary     string                     `json:"summary,omitempty"`
+		Description string                     `json:"description,omitempty"`
+		Tags        []string                   `json:"tags,omitempty"`
+		Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+		RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+		Responses   map[string]openAPIResponse `json:"responses"`
+	}
+
+	openAPIParameter struct {
+		Name            string         `json:"name"`
+		In              string         `json:"in"`
+		Description     string         `json:"description,omitempty"`
+		Required        bool           `json:"required,omitempty"`
+		AllowEmptyValue bool           `json:"allowEmptyValue,omitempty"`
+		Schema          *openAPISchema `json:"schema"`
+	}
+
+	openAPIRequestBody struct {
+		Description string                      `json:"description,omitempty"`
+		Required    bool                        `json:"required,omitempty"`
+		Content     map[string]openAPIMediaType `json:"content"`
+	}
+
+	openAPIResponse struct {
+		Description string                      `json:"description"`
+		Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+		Content     map[string]openAPIMediaType `json:"content,omitempty"`
+	}
+
+	openAPIHeader struct {
+		Description string         `json:"description,omitempty"`
+		Schema      *openAPISchema `json:"schema"`
+	}
+
+	openAPIMediaType struct {
+		Schema  *openAPISchema `json:"schema"`
+		Example interface{}    `json:"example,omitempty"`
+	}
+
+	openAPISchema struct {
+		Ref                  string                    `json:"$ref,omitempty"`
+		Type                 string                    `json:"type,omitempty"`
+		Format               string                    `json:"format,omitempty"`
+		Description          string                    `json:"description,omitempty"`
+		Pattern              string                    `json:"pattern,omitempty"`
+		Default              interface{}               `json:"default,omitempty"`
+		Items                *openAPISchema            `json:"items,omitempty"`
+		MinItems             *int                      `json:"minItems,omitempty"`
+		MaxItems             *int                      `json:"maxItems,omitempty"`
+		OneOf                []*openAPISchema          `json:"oneOf,omitempty"`
+		Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+		AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	}
+
+	openAPIComponents struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	}
+)
+
+var (
+	resultSchema       = &openAPISchema{Ref: "#/components/schemas/Result"}
+	commandErrorSchema = &openAPISchema{Ref: "#/components/schemas/CommandError"}
+	httpErrorSchema    = &openAPISchema{Ref: "#/components/schemas/HTTPError"}
+	stringSchema       = &openAPISchema{Type: "string"}
+	eventStreamSchema  = &openAPISchema{Type: "string", Description: "Server-Sent Events, one JSON event per data line."}
+
+	httpErrorResponse = openAPIResponse{
+		Description: "Invalid request (e.g. unknown parameter or invalid body).",
+		Content:     map[string]openAPIMediaType{"text/plain": {Schema: httpErrorSchema}},
+	}
+
+	redirectResponse = openAPIResponse{
+		Description: "Cluster redirection, the key is served by the node at the Location header.",
+	}
+
+	resultResponse = openAPIResponse{
+		Description: "The command result set (as space separated values unless JSON is accepted). Command errors are " +
+			"returned as a single value result starting with 'Error: ' (see CommandError).",
+		Content: map[string]openAPIMediaType{
+			"text/plain":    {Schema: stringSchema},
+			jsonContentType: {Schema: resultSchema},
+		},
+	}
+
+	jsonResultResponse = openAPIResponse{
+		Description: "The command result set, command errors are returned as a single value result (see CommandError).",
+		Content:     map[string]openAPIMediaType{jsonContentType: {Schema: resultSchema}},
+	}
+)
+
+// createOpenAPIDocument creates the OpenAPI document of the server REST routes, the commands endpoint (one operation
+// per command) and the other endpoints.
+func createOpenAPIDocument(runtime *vm.Runtime, routes []restRoute, authenticated bool, rateLimited bool) *openAPIDocument {
+	var document = &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:       "ARC",
+			Description: "ARC database REST API, generated from the command metadata.",
+			Version:     vm.Version,
+		},
+		Paths: make(map[string]openAPIPathItem),
+		Components: openAPIComponents{
+			Schemas: createOpenAPISchemas(),
+		},
+	}
+
+	var addOperation = func(path string, method string, operation *openAPIOperation) {
+		if document.Paths[path] == nil {
+			document.Paths[path] = make(openAPIPathItem)
+		}
+
+		if authenticated {
+			operation.Responses["401"] = openAPIResponse{Description: "Missing or invalid credentials."}
+		}
+
+		if operation.RequestBody != nil {
+			operation.Responses["413"] = openAPIResponse{Description: "The request body is larger than the server limit."}
+		}
+
+		operation.Responses["503"] = openAPIResponse{
+			Description: "The server is serving too many requests (retry after the Retry-After seconds).",
+		}
+
+		// The commands of the other endpoints (batches) fail one by one instead.
+
+		if _, isEndpoint := endpoints[path]; rateLimited && !isEndpoint {
+			operation.Responses["429"] = openAPIResponse{
+				Description: "The client is over its command rate limit (retry after the Retry-After seconds).",
+				Headers: map[string]openAPIHeader{
+					"Retry-After": {Description: "Seconds until the command is allowed.", Schema: &openAPISchema{Type: "integer"}},
+				},
+				Content: map[string]openAPIMediaType{"text/plain": {Schema: commandErrorSchema}},
+			}
+		}
+
+		document.Paths[path][strings.ToLower(method)] = operation
+	}
+
+	var tags = make(map[string]bool)
+
+	for index := range routes {
+		var operation = createRouteOperation(runtime, &routes[index])
+		addOperation(routes[index].Path, routes[index].Method, operation)
+
+		if !tags[operation.Tags[0]] {
+			tags[operation.Tags[0]] = true
+			document.Tags = append(document.Tags, openAPITag{Name: operation.Tags[0]})
+		}
+	}
+
+	document.Tags = append(document.Tags,
+		openAPITag{Name: "commands", Description: "Every command, executed with its parameters as a JSON array."},
+		openAPITag{Name: "server", Description: "Command lines, batches, metrics and event streams."},
+	)
+
+	for _, command := range runtime.GetCommands() {
+		addOperation(commandsPath+command.Name, http.MethodPost, createCommandOperation(command))
+	}
+
+	for path, operation := range createEndpointOperations() {
+		addOperation(path, http.MethodGet, operation)
+	}
+
+	addOperation(batchPath, http.MethodPost, &openAPIOperation{
+		OperationID: "batch",
+		Summary:     "Executes many commands in order (not atomically).",
+		Tags:        []string{"server"},
+		Parameters: []openAPIParameter{{
+			Name: "stop-on-error", In: "query", Description: "Stop at the first failed command.",
+			Schema: &openAPISchema{Type: "boolean"},
+		}, {
+			Name: "typed", In: "query", Description: "Return each result set with its type (values, nil or error).",
+			Schema: &openAPISchema{Type: "boolean"},
+		}},
+		RequestBody: &openAPIRequestBody{
+			Required: true,
+			Content: map[string]openAPIMediaType{
+				"text/plain": {Schema: &openAPISchema{Type: "string", Description: "One command line per line."}},
+				jsonContentType: {
+					Schema: &openAPISchema{Type: "array", Items: &openAPISchema{OneOf: []*openAPISchema{
+						{Type: "string", Description: "A command line."},
+						{Type: "array", Items: stringSchema, Description: "The command arguments."},
+					}}},
+					Example: []interface{}{"SET first 1", []string{"INCR", "first"}},
+				},
+			},
+		},
+		Responses: map[string]openAPIResponse{
+			"200": {
+				Description: "The result set of each executed command (with its type when typed).",
+				Content: map[string]openAPIMediaType{
+					jsonContentType: {Schema: &openAPISchema{Type: "array", Items: &openAPISchema{OneOf: []*openAPISchema{
+						resultSchema,
+						{Type: "object", Properties: map[string]*openAPISchema{
+							"type":   {Type: "string", Description: "The result type: values, nil or error."},
+							"values": resultSchema,
+						}},
+					}}}},
+				},
+			},
+			"400": httpErrorResponse,
+		},
+	})
+
+	return document
+}
+
+func createOpenAPISchemas() map[string]*openAPISchema {
+	return map[string]*openAPISchema{
+		"Result": {
+			Type:        "array",
+			Items:       stringSchema,
+			Description: fmt.Sprintf("A command result set, missing keys or members are returned as '%s'.", vm.NilMessage),
+		},
+		"CommandError": {
+			Type:    "string",
+			Pattern: "^" + vm.ErrorPrefix,
+			Description: "A command error, returned as the single value of the result set, like \"Error: syntax " +
+				"error: unexpected 'EXX'\". The error kinds (as reported by the metrics and the clients) are: " +
+				strings.Join(vm.GetErrorKinds(), ", ") + ".",
+		},
+		"HTTPError": {
+			Type:        "string",
+			Description: "A plain text description of an invalid HTTP request.",
+		},
+	}
+}
+
+// getOperationID returns an identifier like put_values_key for PUT /values/{key}.
+func getOperationID(method string, path string) string {
+	var replacer = strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_", ".", "_")
+	return strings.ToLower(method) + replacer.Replace(strings.TrimSuffix(path, "/"))
+}
+
+func getParameterSchema(parameter vm.RouteParameter) (schema *openAPISchema) {
+	schema = &openAPISchema{Type: parameter.Type}
+
+	if parameter.Default != "" {
+		schema.Default = parameter.Default
+
+		if parameter.Type != "string" {
+			schema.Default = json.Number(parameter.Default)
+		}
+	}
+
+	if parameter.Type == "flag" {
+		schema = &openAPISchema{Type: "boolean", Description: "Keyword option, given without value (like ?NX)."}
+	}
+
+	if parameter.Multiple {
+		schema = &openAPISchema{Type: "array", Items: schema}
+	}
+
+	return
+}
+
+func createRouteOperation(runtime *vm.Runtime, route *restRoute) (operation *openAPIOperation) {
+	var command = route.GetCommandInfo(runtime)
+	var category = "keyspace"
+
+	if len(command.Categories) > 0 {
+		category = command.Categories[0]
+	}
+
+	operation = &openAPIOperation{
+		OperationID: getOperationID(route.Method, route.Path),
+		Summary:     command.Summary,
+		Description: fmt.Sprintf("Runs `%s`, complexity %s.", command.Syntax, command.Complexity),
+		Tags:        []string{category},
+		Responses: map[string]openAPIResponse{
+			"200": resultResponse,
+			"307": redirectResponse,
+			"400": httpErrorResponse,
+		},
+	}
+
+	var bodyFields = make(map[string]*openAPISchema)
+
+	for _, parameter := range route.GetParameters() {
+		switch parameter.In {
+		case "body":
+			operation.RequestBody = &openAPIRequestBody{
+				Description: fmt.Sprintf("The raw %s (any bytes and content type).", parameter.Name),
+				Required:    true,
+				Content: map[string]openAPIMediaType{
+					"application/octet-stream": {Schema: &openAPISchema{Type: "string", Format: "binary"}},
+				},
+			}
+		case "query":
+			if parameter.Required || parameter.Multiple {
+				bodyFields[parameter.Name] = getParameterSchema(parameter)
+			}
+
+			operation.Parameters = append(operation.Parameters, openAPIParameter{
+				Name:            parameter.Name,
+				In:              parameter.In,
+				AllowEmptyValue: parameter.Type == "flag",
+				Schema:          getParameterSchema(parameter),
+			})
+		default:
+			operation.Parameters = append(operation.Parameters, openAPIParameter{
+				Name:     parameter.Name,
+				In:       parameter.In,
+				Required: true,
+				Schema:   getParameterSchema(parameter),
+			})
+		}
+	}
+
+	// Parameters not given in the path or the query follow as body words, a JSON array or a JSON object.
+
+	if (operation.RequestBody == nil) && (len(bodyFields) > 0) {
+		operation.RequestBody = &openAPIRequestBody{
+			Description: "The parameters not given in the query, as command line words, a JSON array or a JSON object.",
+			Content: map[string]openAPIMediaType{
+				"text/plain": {Schema: stringSchema},
+				jsonContentType: {Schema: &openAPISchema{OneOf: []*openAPISchema{
+					{Type: "array", Items: stringSchema},
+					{Type: "object", Properties: bodyFields},
+				}}},
+			},
+		}
+	}
+
+	if route.Raw {
+		operation.Responses["200"] = openAPIResponse{
+			Description: "The raw value (JSON result set when JSON is accepted).",
+			Content: map[string]openAPIMediaType{
+				"application/octet-stream": {Schema: &openAPISchema{Type: "string", Format: "binary"}},
+				jsonContentType:            {Schema: resultSchema},
+			},
+		}
+		operation.Responses["400"] = openAPIResponse{
+			Description: "Invalid request or command error.",
+			Content:     map[string]openAPIMediaType{"text/plain": {Schema: commandErrorSchema}},
+		}
+		operation.Responses["404"] = openAPIResponse{Description: "The key does not exist."}
+	}
+
+	if route.HasKey() {
+		addConditionalHeaders(operation, route.Method)
+	}
+
+	return
+}
+
+// addConditionalHeaders documents the conditional request headers and the ETag of the routes with a key.
+func addConditionalHeaders(operation *openAPIOperation, method string) {
+	operation.Parameters = append(operation.Parameters,
+		openAPIParameter{
+			Name: "If-Match", In: "header", Schema: stringSchema,
+			Description: "Runs only if the key ETag is one of the given tags (* matches any existing key).",
+		},
+		openAPIParameter{
+			Name: "If-None-Match", In: "header", Schema: stringSchema,
+			Description: "Runs only if the key ETag is none of the given tags (* only matches missing keys, to create them).",
+		},
+	)
+
+	var response = operation.Responses["200"]
+	response.Headers = map[string]openAPIHeader{
+		"ETag": {Description: "The key version (after writes), missing when the key does not exist.", Schema: stringSchema},
+	}
+	operation.Responses["200"] = response
+
+	operation.Responses["412"] = openAPIResponse{
+		Description: "The key version does not match the conditional request headers.",
+		Content:     map[string]openAPIMediaType{"text/plain": {Schema: commandErrorSchema}},
+	}
+
+	if method == http.MethodGet {
+		operation.Responses["304"] = openAPIResponse{Description: "The value did not change (If-None-Match matched)."}
+
+		operation.Parameters = append(operation.Parameters,
+			openAPIParameter{
+				Name: watchParameter, In: "query", AllowEmptyValue: true, Schema: &openAPISchema{Type: "boolean"},
+				Description: "Waits until the key changes (or while If-None-Match matches) before reading it.",
+			},
+			openAPIParameter{
+				Name: watchTimeoutParameter, In: "query",
+				Schema:      &openAPISchema{Type: "string", Default: defaultWatchTimeout.String()},
+				Description: fmt.Sprintf("Maximum time to wait when watching, up to %v.", maxWatchTimeout),
+			},
+		)
+	}
+}
+
+func createCommandOperation(command vm.CommandInfo) *openAPIOperation {
+	var parametersSchema = &openAPISchema{
+		Type:        "array",
+		Items:       stringSchema,
+		Description: "The command parameters, used as is (no quoting needed).",
+		MinItems:    &command.MinArgs,
+	}
+
+	if command.MaxArgs >= 0 {
+		parametersSchema.MaxItems = &command.MaxArgs
+	}
+
+	return &openAPIOperation{
+		OperationID: "command_" + strings.ToLower(command.Name),
+		Summary:     command.Summary,
+		Description: fmt.Sprintf("Runs `%s`, complexity %s. Flags: %s.", command.Syntax, command.Complexity,
+			strings.Join(command.Flags, ", ")),
+		Tags: []string{"commands"},
+		RequestBody: &openAPIRequestBody{
+			Content: map[string]openAPIMediaType{jsonContentType: {Schema: parametersSchema}},
+		},
+		Responses: map[string]openAPIResponse{
+			"200": jsonResultResponse,
+			"307": redirectResponse,
+			"400": httpErrorResponse,
+		},
+	}
+}
+
+func createEndpointOperations() map[string]*openAPIOperation {
+	return map[string]*openAPIOperation{
+		"/": {
+			OperationID: "command_line",
+			Summary:     "Executes a command line.",
+			Tags:        []string{"server"},
+			Parameters: []openAPIParameter{{
+				Name: "cmd", In: "query", Required: true, Description: "The command line, like SET key \"a value\".",
+				Schema: stringSchema,
+			}},
+			Responses: map[string]openAPIResponse{"200": resultResponse, "400": httpErrorResponse},
+		},
+		metricsPath: {
+			OperationID: "metrics",
+			Summary:     "Returns the server metrics in the Prometheus text format.",
+			Tags:        []string{"server"},
+			Responses: map[string]openAPIResponse{"200": {
+				Description: "The metrics.",
+				Content:     map[string]openAPIMediaType{"text/plain": {Schema: stringSchema}},
+			}},
+		},
+		monitorPath: {
+			OperationID: "monitor",
+			Summary:     "Streams every command processed by the server.",
+			Tags:        []string{"server"},
+			Responses: map[string]openAPIResponse{"200": {
+				Description: "The command stream.",
+				Content:     map[string]openAPIMediaType{"text/event-stream": {Schema: eventStreamSchema}},
+			}},
+		},
+		subscribePath: {
+			OperationID: "subscribe",
+			Summary:     "Streams the messages published to channels or to channels matching patterns.",
+			Tags:        []string{"server"},
+			Parameters: []openAPIParameter{
+				{Name: "channel", In: "query", Schema: &openAPISchema{Type: "array", Items: stringSchema}},
+				{Name: "pattern", In: "query", Schema: &openAPISchema{Type: "array", Items: stringSchema}},
+			},
+			Responses: map[string]openAPIResponse{"200": {
+				Description: "The message stream.",
+				Content:     map[string]openAPIMediaType{"text/event-stream": {Schema: eventStreamSchema}},
+			}},
+		},
+		watchPath: {
+			OperationID: "watch",
+			Summary:     "Streams the changes of the keys matching patterns (event name, key and new ETag).",
+			Tags:        []string{"server"},
+			Parameters: []openAPIParameter{
+				{Name: watchPatternParameter, In: "query", Required: true, Schema: &openAPISchema{Type: "array", Items: stringSchema}},
+			},
+			Responses: map[string]openAPIResponse{"200": {
+				Description: "The key change stream.",
+				Content:     map[string]openAPIMediaType{"text/event-stream": {Schema: eventStreamSchema}},
+			}},
+		},
+		openAPIPath: {
+			OperationID: "openapi",
+			Summary:     "Returns this OpenAPI document.",
+			Tags:        []string{"server"},
+			Responses: map[string]openAPIResponse{"200": {
+				Description: "The OpenAPI document.",
+				Content:     map[string]openAPIMediaType{jsonContentType: {Schema: &openAPISchema{Type: "object"}}},
+			}},
+		},
+	}
+}
+
+// serveOpenAPI serves the OpenAPI document of the server.
+func (server *httpServer) serveOpenAPI(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", jsonContentType)
+	response.Write(server.openAPI)
+}
+
+// marshalOpenAPIDocument returns the JSON OpenAPI document of the server.
+func marshalOpenAPIDocument(runtime *vm.Runtime, router *restRouter, authenticated bool, rateLimited bool) []byte {
+	var documentJSON, err = json.MarshalIndent(createOpenAPIDocument(runtime, router.routes, authenticated, rateLimited), "", "  ")
+
+	if err != nil {
+		panic("server: invalid OpenAPI document: " + err.Error())
+	}
+
+	return documentJSON
+}
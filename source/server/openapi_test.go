@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocument(test *testing.T) {
+	var httpServer, runtime = createTestServer(test, nil)
+
+	var response, err = http.Get(httpServer.URL + openAPIPath)
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	var document struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]map[string]struct {
+			OperationID string                     `json:"operationId"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+	}
+
+	if err = json.NewDecoder(response.Body).Decode(&document); err != nil {
+		test.Fatal(err)
+	}
+
+	if (response.StatusCode != http.StatusOK) || (response.Header.Get("Content-Type") != jsonContentType) {
+		test.Fatalf("GET %s = %d %q", openAPIPath, response.StatusCode, response.Header.Get("Content-Type"))
+	}
+
+	if !strings.HasPrefix(document.OpenAPI, "3.") || (document.Info.Title == "") || (document.Info.Version == "") {
+		test.Errorf("openapi %q, info %+v, expected an OpenAPI 3 document", document.OpenAPI, document.Info)
+	}
+
+	// Every operation has a unique id and responses.
+
+	var operationIDs = make(map[string]string)
+
+	for path, item := range document.Paths {
+		for method, operation := range item {
+			if other, exists := operationIDs[operation.OperationID]; exists || (operation.OperationID == "") {
+				test.Errorf("%s %s: operation id %q already used by %s", method, path, operation.OperationID, other)
+			}
+
+			if len(operation.Responses) == 0 {
+				test.Errorf("%s %s: no responses", method, path)
+			}
+
+			operationIDs[operation.OperationID] = method + " " + path
+		}
+	}
+
+	// Every REST route and command of the library is documented.
+
+	for _, route := range runtime.GetRoutes() {
+		if _, exists := document.Paths[route.Path][strings.ToLower(route.Method)]; !exists {
+			test.Errorf("route %s %s (%s) is missing", route.Method, route.Path, route.Command)
+		}
+	}
+
+	for _, command := range runtime.GetCommands() {
+		if _, exists := document.Paths[commandsPath+command.Name]["post"]; !exists {
+			test.Errorf("command %s is missing", command.Name)
+		}
+	}
+}
+
+func TestExplorer(test *testing.T) {
+	var httpServer, _ = createTestServer(test, nil)
+
+	var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+explorerPath, nil)
+	var status, body = doRequest(test, request)
+
+	if (status != http.StatusOK) || !strings.Contains(body, "<html") || !strings.Contains(body, openAPIPath) {
+		test.Errorf("GET %s = %d, expected the page reading %s", explorerPath, status, openAPIPath)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"arc/pubsub"
+)
+
+const (
+	subscribePath             = "/subscribe"
+	subscribeChannelParameter = "channel"
+	subscribePatternParameter = "pattern"
+)
+
+type (
+	pubsubEvent struct {
+		Type    string `json:"type"`
+		Pattern string `json:"pattern,omitempty"`
+		Channel string `json:"channel,omitempty"`
+		Payload string `json:"payload,omitempty"`
+		Count   int    `json:"count,omitempty"`
+	}
+)
+
+/*
+
+SUBSCRIBE channel [channel...]
+PSUBSCRIBE pattern [pattern...]
+===============================
+GET /subscribe?channel=first&channel=second&pattern=news.*
+
+Messages are streamed as Server-Sent Events with JSON data, the subscription ends when the client disconnects.
+
+*/
+
+func (server *httpServer) serveSubscribe(response http.ResponseWriter, request *http.Request) {
+	var channels = request.URL.Query()[subscribeChannelParameter]
+	var patterns = request.URL.Query()[subscribePatternParameter]
+
+	if (len(channels) == 0) && (len(patterns) == 0) {
+		response.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var stream, ok = createEventStream(response)
+
+	if !ok {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var subscription = server.runtime.GetBroker().Subscribe()
+	defer subscription.Close()
+
+	subscription.Subscribe(channels...)
+	subscription.PSubscribe(patterns...)
+
+	log.Printf("SUB: %s subscribed to %d channels and %d patterns", request.RemoteAddr, len(channels), len(patterns))
+
+	defer func() {
+		log.Printf("SUB: %s unsubscribed (%d messages dropped)", request.RemoteAddr, subscription.GetDropped())
+	}()
+
+	for _, channel := range channels {
+		if sendPubsubEvent(stream, pubsubEvent{Type: "subscribe", Channel: channel, Count: subscription.GetCount()}) != nil {
+			return
+		}
+	}
+
+	for _, pattern := range patterns {
+		if sendPubsubEvent(stream, pubsubEvent{Type: "psubscribe", Pattern: pattern, Count: subscription.GetCount()}) != nil {
+			return
+		}
+	}
+
+	streamEvents(request.Context(), stream, subscription.Messages(), subscription.GetDropped, func(message pubsub.Message) error {
+		var event = pubsubEvent{Type: "message", Channel: message.Channel, Payload: message.Payload}
+
+		if message.Pattern != "" {
+			event.Type = "pmessage"
+			event.Pattern = message.Pattern
+		}
+
+		return sendPubsubEvent(stream, event)
+	})
+}
+
+func sendPubsubEvent(stream *eventStream, event pubsubEvent) error {
+	var data, _ = json.Marshal(event)
+	return stream.send(event.Type, string(data))
+}
@@ -0,0 +1,384 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"arc/vm"
+)
+
+const (
+	// Idle clients are removed from the limiter, once their budgets are full again, at this interval.
+	rateLimitSweepInterval = time.Minute
+
+	// Interval of the idle clients removal while the limiter is full.
+	rateLimitFullSweepInterval = time.Second
+
+	// Default maximum number of clients with their own budgets.
+	defaultMaxRateLimitClients = 100000
+
+	// Key of the budgets shared by the new clients while the limiter is full.
+	overflowRateLimitKey = "overflow"
+)
+
+type (
+	// RateLimit is a token bucket budget: Rate commands per second on average, with bursts up to Burst commands. A
+	// zero rate means no limit, and a zero burst a burst of one second of commands.
+	RateLimit struct {
+		Rate  float64
+		Burst int
+	}
+
+	// RateLimits are the command budgets of each client. Write commands (like SET or DEL) use the Write budget, the
+	// other commands the Read budget. Key identifies the clients (RateLimitByIP when not set). At most MaxClients
+	// clients (100000 when not set) have their own budgets, the new clients share the same budgets while the limiter
+	// is full.
+	RateLimits struct {
+		Read       RateLimit
+		Write      RateLimit
+		Key        RateLimitKey
+		MaxClients int
+	}
+
+	// RateLimitKey returns the key identifying the client of a request given its authenticated user (empty for
+	// anonymous requests), the clients with the same key share their budgets.
+	RateLimitKey func(request *http.Request, user string) string
+
+	// rateLimiter keeps a read and a write token bucket for each client key.
+	rateLimiter struct {
+		limits    RateLimits
+		runtime   *vm.Runtime
+		stats     *serverStats
+		mutex     sync.Mutex
+		clients   map[string]*rateLimitClient
+		lastSweep time.Time
+	}
+
+	rateLimitClient struct {
+		read     tokenBucket
+		write    tokenBucket
+		limited  uint64
+		lastSeen time.Time
+	}
+
+	tokenBucket struct {
+		tokens  float64
+		updated time.Time
+	}
+
+	// rateLimitRequest is the rate limit state of a request, stored in its context: the client key, and the retry
+	// delay once a command of the request was rejected.
+	rateLimitRequest struct {
+		key        string
+		mutex      sync.Mutex
+		retryAfter time.Duration
+	}
+
+	// rateLimitContextKey is the request context key of the rate limit state.
+	rateLimitContextKey struct{}
+)
+
+// RateLimitByIP identifies the clients by their IP address.
+func RateLimitByIP(request *http.Request, user string) string {
+	if host, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
+		return host
+	}
+
+	return request.RemoteAddr
+}
+
+// RateLimitByUser identifies the clients by their authenticated user, and the anonymous clients by their IP address.
+func RateLimitByUser(request *http.Request, user string) string {
+	if user != "" {
+		return "user:" + user
+	}
+
+	return RateLimitByIP(request, user)
+}
+
+// RateLimitByAPIKey identifies the clients by the API key sent in a request header, only for the given API keys: the
+// clients with another key or without one are identified by their IP address, so made up keys never get their own
+// budgets. Keys are reported as a hash prefix, so the RATELIMIT command never shows them.
+func RateLimitByAPIKey(header string, apiKeys []string) RateLimitKey {
+	var hashes = make(map[[sha256.Size]byte]bool, len(apiKeys))
+
+	for _, apiKey := range apiKeys {
+		hashes[sha256.Sum256([]byte(apiKey))] = true
+	}
+
+	return func(request *http.Request, user string) string {
+		if apiKey := request.Header.Get(header); apiKey != "" {
+			if hash := sha256.Sum256([]byte(apiKey)); hashes[hash] {
+				return "api-key:" + hex.EncodeToString(hash[:6])
+			}
+		}
+
+		return RateLimitByIP(request, user)
+	}
+}
+
+func createRateLimiter(limits RateLimits, runtime *vm.Runtime, stats *serverStats) *rateLimiter {
+	if limits.Key == nil {
+		limits.Key = RateLimitByIP
+	}
+
+	if limits.MaxClients <= 0 {
+		limits.MaxClients = defaultMaxRateLimitClients
+	}
+
+	for _, limit := range []*RateLimit{&limits.Read, &limits.Write} {
+		if (limit.Rate > 0) && (limit.Burst <= 0) {
+			limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+		}
+	}
+
+	return &rateLimiter{
+		limits:    limits,
+		runtime:   runtime,
+		stats:     stats,
+		clients:   make(map[string]*rateLimitClient),
+		lastSweep: time.Now(),
+	}
+}
+
+// take takes a token from the bucket, or returns the time until there is one available.
+func (bucket *tokenBucket) take(limit RateLimit, now time.Time) (retryAfter time.Duration) {
+	bucket.refill(limit, now)
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+}
+
+func (bucket *tokenBucket) refill(limit RateLimit, now time.Time) {
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate)
+	bucket.updated = now
+}
+
+// full reports if the bucket would be full at the given time.
+func (bucket *tokenBucket) full(limit RateLimit, now time.Time) bool {
+	return (limit.Rate <= 0) || (bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate >= float64(limit.Burst))
+}
+
+// available returns the tokens left in the bucket at the given time (-1 without limit).
+func (bucket *tokenBucket) available(limit RateLimit, now time.Time) float64 {
+	if limit.Rate <= 0 {
+		return -1
+	}
+
+	return math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate)
+}
+
+// allow takes a token from the read or write budget of a client, or returns the time until the command is allowed.
+func (limiter *rateLimiter) allow(key string, write bool) (retryAfter time.Duration) {
+	var limit = limiter.limits.Read
+
+	if write {
+		limit = limiter.limits.Write
+	}
+
+	if limit.Rate <= 0 {
+		return 0
+	}
+
+	var now = time.Now()
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.sweep(now, rateLimitSweepInterval)
+
+	var client, exists = limiter.clients[key]
+
+	if !exists && (len(limiter.clients) >= limiter.limits.MaxClients) {
+		limiter.sweep(now, rateLimitFullSweepInterval)
+
+		if len(limiter.clients) >= limiter.limits.MaxClients {
+			key = overflowRateLimitKey
+			client, exists = limiter.clients[key]
+		}
+	}
+
+	if !exists {
+		client = &rateLimitClient{
+			read:  tokenBucket{tokens: float64(limiter.limits.Read.Burst), updated: now},
+			write: tokenBucket{tokens: float64(limiter.limits.Write.Burst), updated: now},
+		}
+
+		limiter.clients[key] = client
+	}
+
+	client.lastSeen = now
+
+	if write {
+		retryAfter = client.write.take(limit, now)
+	} else {
+		retryAfter = client.read.take(limit, now)
+	}
+
+	if retryAfter > 0 {
+		client.limited++
+	}
+
+	return
+}
+
+// sweep removes the clients whose budgets are full (they are the same as new clients), if the last sweep is older
+// than the interval.
+func (limiter *rateLimiter) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(limiter.lastSweep) < interval {
+		return
+	}
+
+	limiter.lastSweep = now
+
+	for key, client := range limiter.clients {
+		if client.read.full(limiter.limits.Read, now) && client.write.full(limiter.limits.Write, now) {
+			delete(limiter.clients, key)
+		}
+	}
+}
+
+// middleware rejects the commands of the requests whose client is over its budget. Commands executed outside of a
+// request (without rate limit state) are not limited.
+func (limiter *rateLimiter) middleware(next vm.Handler) vm.Handler {
+	return func(execution *vm.ExecutionContext) vm.TypedResult {
+		var state *rateLimitRequest
+
+		if execution.Context != nil {
+			state, _ = execution.Context.Value(rateLimitContextKey{}).(*rateLimitRequest)
+		}
+
+		if state == nil {
+			return next(execution)
+		}
+
+		var flags, _ = limiter.runtime.GetCommandFlags(execution.Command)
+
+		if retryAfter := limiter.allow(state.key, slices.Contains(flags, "write")); retryAfter > 0 {
+			state.setRetryAfter(retryAfter)
+			limiter.stats.rejectedRateLimit.Increment()
+			return vm.RateLimitedResult(retryAfter)
+		}
+
+		return next(execution)
+	}
+}
+
+// withRateLimit returns the request with its rate limit state.
+func (limiter *rateLimiter) withRateLimit(request *http.Request) *http.Request {
+	var user, _ = request.Context().Value(userContextKey{}).(string)
+	var state = &rateLimitRequest{key: limiter.limits.Key(request, user)}
+
+	return request.WithContext(context.WithValue(request.Context(), rateLimitContextKey{}, state))
+}
+
+// GetRateLimitSettings returns the limiter settings as name and value pairs.
+func (limiter *rateLimiter) GetRateLimitSettings() []string {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	var formatRate = func(rate float64) string {
+		return strconv.FormatFloat(rate, 'f', -1, 64)
+	}
+
+	return []string{
+		"read-rate", formatRate(limiter.limits.Read.Rate),
+		"read-burst", strconv.Itoa(limiter.limits.Read.Burst),
+		"write-rate", formatRate(limiter.limits.Write.Rate),
+		"write-burst", strconv.Itoa(limiter.limits.Write.Burst),
+		"clients", strconv.Itoa(len(limiter.clients)),
+		"limited", strconv.FormatUint(limiter.stats.rejectedRateLimit.Get(), 10),
+	}
+}
+
+// GetRateLimitClients returns the state of the known clients.
+func (limiter *rateLimiter) GetRateLimitClients() (states []vm.RateLimitState) {
+	var now = time.Now()
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	for key, client := range limiter.clients {
+		states = append(states, vm.RateLimitState{
+			Key:      key,
+			Read:     client.read.available(limiter.limits.Read, now),
+			Write:    client.write.available(limiter.limits.Write, now),
+			Limited:  client.limited,
+			LastSeen: client.lastSeen,
+		})
+	}
+
+	return
+}
+
+// ResetRateLimit refills the budgets of a client, and returns false when the client is unknown.
+func (limiter *rateLimiter) ResetRateLimit(key string) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if _, exists := limiter.clients[key]; !exists {
+		return false
+	}
+
+	log.Printf("RATELIMIT: reset the budgets of %s", key)
+	delete(limiter.clients, key)
+	return true
+}
+
+func (state *rateLimitRequest) setRetryAfter(retryAfter time.Duration) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.retryAfter = max(state.retryAfter, retryAfter)
+}
+
+func (state *rateLimitRequest) getRetryAfter() time.Duration {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return state.retryAfter
+}
+
+// writeRateLimited answers the requests whose command was rejected because the client is over its budget with 429,
+// and the delay until a command is allowed again as Retry-After.
+func writeRateLimited(response http.ResponseWriter, request *http.Request, result vm.TypedResult) bool {
+	if !isRateLimited(result) {
+		return false
+	}
+
+	setRetryAfter(response, request)
+	http.Error(response, result.Values[0], http.StatusTooManyRequests)
+	return true
+}
+
+// isRateLimited reports if a command was rejected because the client is over its budget.
+func isRateLimited(result vm.TypedResult) bool {
+	var _, err = result.Get()
+	return errors.Is(err, vm.ErrRateLimited)
+}
+
+// setRetryAfter sets the Retry-After header of a request with rejected commands, to the delay (in whole seconds)
+// until a command is allowed again.
+func setRetryAfter(response http.ResponseWriter, request *http.Request) {
+	var retryAfter = time.Second
+
+	if state, _ := request.Context().Value(rateLimitContextKey{}).(*rateLimitRequest); state != nil {
+		retryAfter = max(retryAfter, state.getRetryAfter())
+	}
+
+	response.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+}
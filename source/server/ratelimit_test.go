@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"arc/vm"
+)
+
+func TestTokenBucket(test *testing.T) {
+	var limit = RateLimit{Rate: 2, Burst: 3}
+	var start = time.Now()
+	var bucket = tokenBucket{tokens: float64(limit.Burst), updated: start}
+
+	var tests = []struct {
+		elapsed    time.Duration
+		retryAfter time.Duration
+	}{
+		// The burst is taken at once, then a token is available every 500ms.
+		{0, 0},
+		{0, 0},
+		{0, 0},
+		{0, 500 * time.Millisecond},
+		{200 * time.Millisecond, 300 * time.Millisecond},
+		{500 * time.Millisecond, 0},
+		{500 * time.Millisecond, 500 * time.Millisecond},
+
+		// The refill stops at the burst.
+		{time.Minute, 0},
+		{time.Minute, 0},
+		{time.Minute, 0},
+		{time.Minute, 500 * time.Millisecond},
+	}
+
+	for index, testCase := range tests {
+		var retryAfter = bucket.take(limit, start.Add(testCase.elapsed))
+
+		if (retryAfter - testCase.retryAfter).Abs() > time.Millisecond {
+			test.Errorf("take %d after %v = %v, expected %v", index, testCase.elapsed, retryAfter, testCase.retryAfter)
+		}
+	}
+}
+
+func TestRateLimitByAPIKey(test *testing.T) {
+	var key = RateLimitByAPIKey("X-API-Key", []string{"known"})
+
+	var tests = []struct {
+		apiKey   string
+		expected string
+	}{
+		{"known", "api-key:"},
+		{"made-up", "192.0.2.1"},
+		{"", "192.0.2.1"},
+	}
+
+	for _, testCase := range tests {
+		var request = httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+
+		if testCase.apiKey != "" {
+			request.Header.Set("X-API-Key", testCase.apiKey)
+		}
+
+		if clientKey := key(request, ""); !strings.HasPrefix(clientKey, testCase.expected) || strings.Contains(clientKey, "known") {
+			test.Errorf("key of API key %q = %q, expected %q", testCase.apiKey, clientKey, testCase.expected)
+		}
+	}
+}
+
+func TestRateLimitMaxClients(test *testing.T) {
+	var limiter = createRateLimiter(RateLimits{Read: RateLimit{Rate: 1, Burst: 1}, MaxClients: 2}, nil, nil)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if retryAfter := limiter.allow(key, false); retryAfter > 0 {
+			test.Errorf("first command of %s limited for %v", key, retryAfter)
+		}
+	}
+
+	// The clients over the maximum share the overflow budgets.
+
+	if retryAfter := limiter.allow("d", false); retryAfter <= 0 {
+		test.Error("the overflow budget is not shared")
+	}
+
+	if _, exists := limiter.clients[overflowRateLimitKey]; !exists || (len(limiter.clients) != 3) {
+		test.Errorf("clients = %v, expected a, b and overflow", limiter.clients)
+	}
+
+	if retryAfter := limiter.allow("a", false); retryAfter <= 0 {
+		test.Error("the known clients keep their budgets")
+	}
+}
+
+func TestRateLimits(test *testing.T) {
+	var httpServer, _ = createTestServer(test, func(server *Server) {
+		server.SetRateLimits(RateLimits{Read: RateLimit{Rate: 0.1, Burst: 2}, Write: RateLimit{Rate: 0.1}})
+	})
+
+	var get = func(path string) *http.Response {
+		var response, err = http.Get(httpServer.URL + path)
+
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		response.Body.Close()
+		return response
+	}
+
+	// Write commands use the write budget (a burst of one second of commands, at least one), the others the read
+	// budget.
+
+	var tests = []struct {
+		line   string
+		status int
+	}{
+		{"SET a 1", http.StatusOK},
+		{"SET a 2", http.StatusTooManyRequests},
+		{"GET a", http.StatusOK},
+		{"GET a", http.StatusOK},
+		{"GET a", http.StatusTooManyRequests},
+	}
+
+	for _, testCase := range tests {
+		var response = get("/?cmd=" + url.QueryEscape(testCase.line))
+
+		if response.StatusCode != testCase.status {
+			test.Errorf("%s = %d, expected %d", testCase.line, response.StatusCode, testCase.status)
+		}
+
+		if retryAfter := response.Header.Get("Retry-After"); (response.StatusCode == http.StatusTooManyRequests) && (retryAfter != "10") {
+			test.Errorf("%s Retry-After = %q, expected 10", testCase.line, retryAfter)
+		}
+	}
+
+	if response := get("/values/a"); (response.StatusCode != http.StatusTooManyRequests) || (response.Header.Get("Retry-After") == "") {
+		test.Errorf("GET /values/a = %d, Retry-After %q", response.StatusCode, response.Header.Get("Retry-After"))
+	}
+
+	// Batches report the error of each rejected command.
+
+	var response, err = http.Post(httpServer.URL+"/batch?typed=true", jsonContentType, strings.NewReader(`["GET a", "SET b 1"]`))
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	var results []vm.TypedResult
+
+	if err = json.NewDecoder(response.Body).Decode(&results); err != nil {
+		test.Fatal(err)
+	}
+
+	if (response.StatusCode != http.StatusOK) || (response.Header.Get("Retry-After") != "10") || (len(results) != 2) {
+		test.Fatalf("batch = %d, Retry-After %q, %v", response.StatusCode, response.Header.Get("Retry-After"), results)
+	}
+
+	for _, result := range results {
+		if !isRateLimited(result) {
+			test.Errorf("batch result = %v, expected a rate limit error", result)
+		}
+	}
+}
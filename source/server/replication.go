@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"arc/vm"
+)
+
+const (
+	replicationPath        = "/replication"
+	replicationContentType = "application/x-ndjson"
+)
+
+/*
+
+Replication stream
+==================
+GET /replication?replica=replica-id&id=replication-id&offset=offset
+
+The leader answers with a stream of newline delimited JSON messages: a full (or partial) resynchronization header,
+the snapshot commands (for full resynchronizations), and then every write command, until the replica disconnects.
+
+*/
+
+func (server *httpServer) serveReplication(response http.ResponseWriter, request *http.Request) {
+	var query = request.URL.Query()
+	var offset, err = strconv.ParseInt(query.Get("offset"), 10, 64)
+
+	if (err != nil) || (query.Get("replica") == "") {
+		response.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var flusher, ok = response.(http.Flusher)
+
+	if !ok {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	disableDeadlines(response)
+
+	response.Header().Set("Content-Type", replicationContentType)
+	response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.Printf("REPL: replica %s connected from %s", query.Get("replica"), request.RemoteAddr)
+
+	var encoder = json.NewEncoder(response)
+
+	err = server.runtime.ServeReplica(request.Context(), query.Get("replica"), request.RemoteAddr, query.Get("id"), offset, func(message vm.ReplicationMessage) (err error) {
+		if err = encoder.Encode(message); err == nil {
+			flusher.Flush()
+		}
+
+		return
+	})
+
+	log.Printf("REPL: replica %s disconnected (%v)", query.Get("replica"), err)
+}
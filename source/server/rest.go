@@ -2,214 +2,266 @@ package server
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+
+	"arc/vm"
 )
 
-// These are used to convert a REST request to a database command line.
+// REST resource routes are declared by the library commands (see vm.Route), for example:
+//
+//	GET    /db/size                       DBSIZE
+//	PUT    /values/key   (raw value)      SET key value [NX|XX] [EX seconds|PX milliseconds] (?EX=10&NX)
+//	PUT    /values       (key value)      SET key value ...
+//	GET    /values/key                    GET key (the raw value)
+//	PATCH  /values/key                    INCR key
+//	DELETE /values/key                    DEL key
+//	PUT    /sets         (key score ...)  ZADD key score member [score member ...]
+//	GET    /sets/key?start=0&stop=2       ZRANGE key start stop (the whole set by default)
+//	GET    /sets/key/size                 ZCARD key
+//	GET    /sets/key/rank/member          ZRANK key member
+//
+// Every command (with or without resource routes) is also available at POST /commands/NAME.
 
 type (
-	commandBuilder func(method string, parameters []string) string
+	restRoute struct {
+		vm.Route
+		segments []string
+	}
+
+	// restRequest is a REST request matched to a command route.
+	restRequest struct {
+		route     *restRoute
+		arguments []string
 
-	requestInterpreter struct {
-		validMethods []string
-		builder      commandBuilder
+		// key is the route key (for conditional requests and watches), when hasKey is set.
+		key    string
+		hasKey bool
+
+		// watchTimeout is the time to wait for a change of the key before running the command (when watching).
+		watchTimeout time.Duration
 	}
-)
 
-var (
-	validRequests = map[string]requestInterpreter{
-		"db": {
-			validMethods: []string{http.MethodGet},
-			builder:      dbBuilder,
-		},
-		"values": {
-			validMethods: []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete},
-			builder:      valuesBuilder,
-		},
-		"sets": {
-			validMethods: []string{http.MethodGet, http.MethodPut},
-			builder:      setsBuilder,
-		},
+	// restRouter matches the REST requests to the command routes.
+	restRouter struct {
+		routes []restRoute
 	}
 )
 
-func buildCommandLineFromREST(request *http.Request) (commandLine string) {
-	// Join the command parts: url path + paramters + data
+func getPathSegments(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
 
-	var commandParts = strings.Split(request.URL.EscapedPath(), "/")[1:]
+// createRESTRouter creates the router of the command routes, non REST endpoints paths must never be used as REST
+// roots.
+func createRESTRouter(routes []vm.Route) (router *restRouter) {
+	router = &restRouter{}
 
-	for httpName, httpValue := range request.URL.Query() {
-		commandParts = append(commandParts, []string{httpName, httpValue[0]}...)
-	}
+	for _, route := range routes {
+		var segments = getPathSegments(route.Path)
 
-	var bodyBuffer = new(bytes.Buffer)
+		if _, exists := endpoints["/"+segments[0]]; exists || ("/"+segments[0]+"/" == commandsPath) {
+			panic("server: REST root collides with endpoint path: " + route.Path)
+		}
 
-	if bodySize, err := bodyBuffer.ReadFrom(request.Body); (err == nil) && (bodySize > 0) {
-		commandParts = append(commandParts, strings.Split(bodyBuffer.String(), " ")...)
+		router.routes = append(router.routes, restRoute{Route: route, segments: segments})
 	}
 
-	// Get the root, check method and build the command.
+	return
+}
 
-	if len(commandParts) < 1 {
-		return
+// matchPath returns the path variables and the number of literal segments if the route path matches.
+func (route *restRoute) matchPath(segments []string) (variables map[string]string, literals int, matches bool) {
+	if len(segments) != len(route.segments) {
+		return nil, 0, false
 	}
 
-	var root = commandParts[0]
-	var parameters = commandParts[1:]
+	variables = make(map[string]string)
 
-	if interpreter, exists := validRequests[root]; exists {
-		for index := range interpreter.validMethods {
-			if request.Method == interpreter.validMethods[index] {
-				commandLine = interpreter.builder(request.Method, parameters)
+	for index, segment := range route.segments {
+		if name, isVariable := strings.CutPrefix(segment, "{"); isVariable {
+			if segments[index] == "" {
+				return nil, 0, false
 			}
+
+			variables[strings.TrimSuffix(name, "}")] = segments[index]
+		} else if segment == segments[index] {
+			literals++
+		} else {
+			return nil, 0, false
 		}
 	}
 
-	return
+	return variables, literals, true
 }
 
-/*
+// match returns the route (and its path variables) for a request, preferring the routes with more literal segments.
+// When the path matches but the method doesn't, the allowed methods are returned instead.
+func (router *restRouter) match(method string, path string) (route *restRoute, variables map[string]string, allowed []string) {
+	var segments = getPathSegments(path)
+	var mostLiterals = -1
 
-DBSIZE
-======
-GET /db/size
+	for index := range router.routes {
+		var candidate = &router.routes[index]
+		var candidateVariables, literals, matches = candidate.matchPath(segments)
 
-*/
+		if !matches {
+			continue
+		}
 
-const (
-	dbSizeParemeterName = "size"
-)
+		if candidate.Method != method {
+			allowed = append(allowed, candidate.Method)
+			continue
+		}
 
-func dbBuilder(method string, parameters []string) (command string) {
-	if (len(parameters) == 1) && (strings.ToLower(parameters[0]) == dbSizeParemeterName) {
-		command = "DBSIZE"
+		if literals > mostLiterals {
+			route, variables, mostLiterals = candidate, candidateVariables, literals
+		}
 	}
 
 	return
 }
 
-/*
+// getRESTRequest returns the route and the command arguments of a REST request. The path variables are unescaped,
+// the raw request body is the value of the route body argument (if any), and otherwise the request body may be a JSON
+// array of parameters, a JSON object with the named parameters (as the query parameters) or text words (quoted as in
+// command lines) that follow the parameters taken from the path and the query.
+func (server *httpServer) getRESTRequest(response http.ResponseWriter, request *http.Request) (matched *restRequest, ok bool) {
+	var route, variables, allowed = server.router.match(request.Method, request.URL.EscapedPath())
+
+	if route == nil {
+		if len(allowed) > 0 {
+			response.Header().Set("Allow", strings.Join(allowed, ", "))
+			http.Error(response, "method not allowed", http.StatusMethodNotAllowed)
+		} else {
+			http.Error(response, "no such resource", http.StatusNotFound)
+		}
 
-SET​ key value
-=============
-PUT /values
-key value
+		return nil, false
+	}
 
-SET key value EX seconds
-========================
-PUT /values
-key value seconds
+	for name, value := range variables {
+		var unescaped, err = url.PathUnescape(value)
 
-GET​ key
-=======
-GET /values/key
+		if err != nil {
+			http.Error(response, fmt.Sprintf("invalid path: %v", err), http.StatusBadRequest)
+			return nil, false
+		}
 
-DEL​ key [key ...]
-=================
-DELETE /values/key
+		variables[name] = unescaped
+	}
 
-INCR​ key
-========
-PATCH /values/key
+	matched = &restRequest{route: route}
+	matched.key, matched.hasKey = route.GetKey(variables)
 
-*/
+	var query = request.URL.Query()
+	var err error
 
-func valuesBuilder(method string, parameters []string) (command string) {
-	switch method {
-	case http.MethodGet:
-		if len(parameters) == 1 {
-			command = fmt.Sprintf("GET %s", parameters[0])
-		}
-	case http.MethodPut:
-		switch len(parameters) {
-		case 2:
-			command = fmt.Sprintf("SET %s %s", parameters[0], parameters[1])
-		case 3:
-			command = fmt.Sprintf("SET %s %s EX %s", parameters[0], parameters[1], parameters[2])
-		}
-	case http.MethodPatch:
-		if len(parameters) == 1 {
-			command = fmt.Sprintf("INCR %s", parameters[0])
-		}
-	case http.MethodDelete:
-		if len(parameters) == 1 {
-			command = fmt.Sprintf("DEL %s", parameters[0])
+	if (route.Method == http.MethodGet) && matched.hasKey {
+		if matched.watchTimeout, err = getWatchTimeout(query); err != nil {
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return nil, false
 		}
 	}
 
-	return
-}
+	var body []string
+	var bodyBytes []byte
 
-/*
+	if bodyBytes, err = io.ReadAll(request.Body); err != nil {
+		server.writeBodyError(response, "invalid body", err)
+		return nil, false
+	}
 
-ZADD​ key score member
-=====================
-PUT /sets
-key score member
+	switch {
+	case route.Body != "":
+		variables[route.Body] = string(bodyBytes)
+	case len(bodyBytes) == 0:
+	case strings.HasPrefix(request.Header.Get("Content-Type"), jsonContentType):
+		err = readJSONBody(bodyBytes, &body, query)
+	default:
+		body, err = vm.Tokenize(string(bodyBytes))
+	}
 
-ZCARD​ key
-=========
-GET /sets/key/size
+	if err != nil {
+		http.Error(response, fmt.Sprintf("invalid body: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
 
-ZRANK​ key member
-================
-GET /sets/key/rank/member
+	if matched.arguments, err = route.GetArguments(variables, query, body); err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
 
-ZRANGE key start stop
-=====================
-GET /sets/key
-GET /sets/key?start=0&stop=2
+	return matched, true
+}
 
-*/
+// readJSONBody reads a JSON array of parameters, or a JSON object whose fields are added to the query parameters:
+// strings and numbers are the field values, arrays hold repeated values, true is a keyword option without value (like
+// NX) and false or null omit the field.
+func readJSONBody(data []byte, body *[]string, query url.Values) error {
+	var decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
 
-const (
-	setSizeParameterName  = "size"
-	setRankParameterName  = "rank"
-	setStartParameterName = "start"
-	setStopParameterName  = "stop"
-)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return decoder.Decode(body)
+	}
 
-func setsBuilder(method string, parameters []string) (command string) {
-	switch method {
-	case http.MethodGet:
-		switch len(parameters) {
-		case 1:
-			command = fmt.Sprintf("ZRANGE %s 0 -1", parameters[0])
-		case 2:
-			if parameters[1] == setSizeParameterName {
-				command = fmt.Sprintf("ZCARD %s", parameters[0])
-			}
-		case 3:
-			switch parameters[1] {
-			case setRankParameterName:
-				command = fmt.Sprintf("ZRANK %s %s", parameters[0], parameters[2])
-			case setStartParameterName:
-				command = fmt.Sprintf("ZRANGE %s %s -1", parameters[0], parameters[2])
-			case setStopParameterName:
-				command = fmt.Sprintf("ZRANGE %s 0 %s", parameters[0], parameters[2])
-			}
-		case 5:
-			if ((parameters[1] == setStartParameterName) && (parameters[3] == setStopParameterName)) ||
-				((parameters[1] == setStopParameterName) && (parameters[3] == setStartParameterName)) {
+	var fields map[string]interface{}
 
-				var startIndex = 2
-				var stopIndex = 4
+	if err := decoder.Decode(&fields); err != nil {
+		return err
+	}
 
-				if parameters[1] == setStopParameterName {
-					startIndex = 4
-					stopIndex = 2
-				}
+	for name, field := range fields {
+		var values = []interface{}{field}
 
-				command = fmt.Sprintf("ZRANGE %s %s %s", parameters[0], parameters[startIndex], parameters[stopIndex])
-			}
+		if array, isArray := field.([]interface{}); isArray {
+			values = array
 		}
-	case http.MethodPut:
-		if (len(parameters) >= 3) && (len(parameters)%2 == 1) {
-			command = "ZADD " + strings.Join(parameters, " ")
+
+		for _, value := range values {
+			switch typedValue := value.(type) {
+			case string:
+				query.Add(name, typedValue)
+			case json.Number:
+				query.Add(name, typedValue.String())
+			case bool:
+				if typedValue {
+					query.Add(name, "")
+				}
+			case nil:
+			default:
+				return fmt.Errorf("invalid value for '%s'", name)
+			}
 		}
 	}
 
+	return nil
+}
+
+// writeRawResult writes the single value of a result as is: nil results are not found, and errors are bad requests.
+// The result type tells them apart, so a stored value looking like a nil or an error result is written as is.
+func writeRawResult(response http.ResponseWriter, result vm.TypedResult) (status int) {
+	switch {
+	case result.Type == vm.NilResult:
+		status = http.StatusNotFound
+		response.WriteHeader(status)
+	case result.Type == vm.ErrorResult:
+		status = http.StatusBadRequest
+		http.Error(response, strings.Join(result.Values, " "), status)
+	case len(result.Values) == 1:
+		status = http.StatusOK
+		response.Header().Set("Content-Type", "application/octet-stream")
+		response.Write([]byte(result.Values[0]))
+	default:
+		status = http.StatusInternalServerError
+		response.WriteHeader(status)
+	}
+
 	return
 }
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRawValues(test *testing.T) {
+	var httpServer, _ = createTestServer(test, nil)
+
+	var tests = []struct {
+		key   string
+		value string
+	}{
+		{"plain", "hello world"},
+		{"nil", "(nil)"},
+		{"error", "Error: not an error"},
+		{"precondition", "Error: precondition failed"},
+	}
+
+	for _, testCase := range tests {
+		var request, _ = http.NewRequest(http.MethodPut, httpServer.URL+"/values/"+testCase.key, strings.NewReader(testCase.value))
+
+		if status, body := doRequest(test, request); status != http.StatusOK {
+			test.Fatalf("PUT /values/%s = %d %q", testCase.key, status, body)
+		}
+
+		request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/values/"+testCase.key, nil)
+
+		if status, body := doRequest(test, request); (status != http.StatusOK) || (body != testCase.value) {
+			test.Errorf("GET /values/%s = %d %q, expected 200 %q", testCase.key, status, body, testCase.value)
+		}
+	}
+
+	var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/values/missing", nil)
+
+	if status, body := doRequest(test, request); status != http.StatusNotFound {
+		test.Errorf("GET /values/missing = %d %q, expected 404", status, body)
+	}
+
+	executeLine(test, httpServer, "ZADD scores 1 a")
+	request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/values/scores", nil)
+
+	if status, body := doRequest(test, request); status != http.StatusBadRequest {
+		test.Errorf("GET /values/scores = %d %q, expected 400", status, body)
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"net"
 	"net/http"
 
 	"arc/vm"
@@ -9,22 +10,109 @@ import (
 type (
 	// Server defines a simple database server type.
 	Server struct {
-		address string
-		runtime *vm.Runtime
+		address       string
+		runtime       *vm.Runtime
+		stats         *serverStats
+		authenticator Authenticator
+		limits        Limits
+		rateLimiter   *rateLimiter
 	}
+
+	// Authenticator returns the user making a request (empty for anonymous requests), or false when the request
+	// credentials are not valid.
+	Authenticator func(request *http.Request) (user string, valid bool)
 )
 
 // Create creates a new database server.
 func Create(address string, runtime *vm.Runtime) (server *Server) {
-	return &Server{
+	server = &Server{
 		address: address,
 		runtime: runtime,
+		stats:   createServerStats(),
+		limits:  DefaultLimits(),
+	}
+
+	runtime.SetServerInfo(server)
+	return
+}
+
+// GetMode returns the server mode name.
+func (server *Server) GetMode() string {
+	return "server"
+}
+
+// GetAddress returns the address the server listens at.
+func (server *Server) GetAddress() string {
+	return server.address
+}
+
+// GetConnectedClients returns the number of open client connections.
+func (server *Server) GetConnectedClients() int64 {
+	return server.stats.activeConnections.Get()
+}
+
+// GetTotalConnections returns the number of accepted client connections.
+func (server *Server) GetTotalConnections() uint64 {
+	return server.stats.totalConnections.Get()
+}
+
+// SetAuthenticator sets the authenticator of the server requests, the user is given to the commands in their
+// execution context. Requests are anonymous when there is no authenticator.
+func (server *Server) SetAuthenticator(authenticator Authenticator) {
+	server.authenticator = authenticator
+}
+
+// SetLimits sets the server limits (DefaultLimits when not set).
+func (server *Server) SetLimits(limits Limits) {
+	server.limits = limits
+}
+
+// SetRateLimits enables the per-client rate limits of the commands, to be called once before serving. Clients over
+// their budget get 429 responses with a Retry-After delay, and the RATELIMIT command reports the limiter state.
+func (server *Server) SetRateLimits(limits RateLimits) {
+	server.rateLimiter = createRateLimiter(limits, server.runtime, server.stats)
+	server.runtime.Use(server.rateLimiter.middleware)
+	server.runtime.SetRateLimiter(server.rateLimiter)
+}
+
+// Handler returns the server HTTP handler (to be used by other HTTP servers). The handler applies the body size and
+// concurrent requests limits, the other limits are applied by Run.
+func (server *Server) Handler() http.Handler {
+	var router = createRESTRouter(server.runtime.GetRoutes())
+
+	return &httpServer{
+		runtime:       server.runtime,
+		stats:         server.stats,
+		authenticator: server.authenticator,
+		limits:        server.limits,
+		rateLimiter:   server.rateLimiter,
+		requests:      createRequestLimiter(server.limits.MaxConcurrentRequests),
+		router:        router,
+		openAPI:       marshalOpenAPIDocument(server.runtime, router, server.authenticator != nil, server.rateLimiter != nil),
 	}
 }
 
 // Run starts the server and listens for connections and commands.
 func (server *Server) Run() (err error) {
-	return http.ListenAndServe(server.address, &httpServer{
-		runtime: server.runtime,
-	})
+	var httpServer = &http.Server{
+		Handler:           server.Handler(),
+		ConnState:         server.stats.trackConnection,
+		ReadHeaderTimeout: server.limits.ReadHeaderTimeout,
+		ReadTimeout:       server.limits.ReadTimeout,
+		WriteTimeout:      server.limits.WriteTimeout,
+		IdleTimeout:       server.limits.IdleTimeout,
+		MaxHeaderBytes:    server.limits.MaxHeaderBytes,
+	}
+
+	var listener net.Listener
+
+	if listener, err = net.Listen("tcp", server.address); err != nil {
+		return
+	}
+
+	if server.limits.MaxConnections > 0 {
+		listener = createLimitListener(listener, server.limits.MaxConnections, server.stats)
+	}
+
+	return httpServer.Serve(listener)
 }
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Interval between keep alive comments sent on idle event streams.
+const streamKeepAliveInterval = 15 * time.Second
+
+type (
+	// eventStream writes Server-Sent Events to a HTTP response.
+	eventStream struct {
+		response http.ResponseWriter
+		flusher  http.Flusher
+	}
+)
+
+func createEventStream(response http.ResponseWriter) (stream *eventStream, ok bool) {
+	var flusher http.Flusher
+
+	if flusher, ok = response.(http.Flusher); !ok {
+		return nil, false
+	}
+
+	disableDeadlines(response)
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &eventStream{
+		response: response,
+		flusher:  flusher,
+	}, true
+}
+
+// send writes a single event, multi line data is split in multiple data fields.
+func (stream *eventStream) send(event string, data string) (err error) {
+	var builder strings.Builder
+
+	if event != "" {
+		builder.WriteString("event: " + event + "\n")
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		builder.WriteString("data: " + line + "\n")
+	}
+
+	builder.WriteString("\n")
+
+	if _, err = stream.response.Write([]byte(builder.String())); err == nil {
+		stream.flusher.Flush()
+	}
+
+	return
+}
+
+func (stream *eventStream) keepAlive() (err error) {
+	if _, err = fmt.Fprint(stream.response, ": keep-alive\n\n"); err == nil {
+		stream.flusher.Flush()
+	}
+
+	return
+}
+
+// streamEvents sends the events to the stream with send until the context is done or a write fails, and keep alive
+// comments while idle. getDropped returns the number of events dropped so far, the events dropped since the previous
+// event are reported with a "dropped" event before it.
+func streamEvents[Event any](ctx context.Context, stream *eventStream, events <-chan Event, getDropped func() uint64, send func(event Event) error) {
+	var keepAlive = time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	var lastDropped uint64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if stream.keepAlive() != nil {
+				return
+			}
+		case event := <-events:
+			if dropped := getDropped(); dropped != lastDropped {
+				if stream.send("dropped", fmt.Sprint(dropped-lastDropped)) != nil {
+					return
+				}
+
+				lastDropped = dropped
+			}
+
+			if send(event) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"arc/vm"
+)
+
+const (
+	watchPath             = "/watch"
+	watchPatternParameter = "pattern"
+	watchParameter        = "watch"
+	watchTimeoutParameter = "timeout"
+	defaultWatchTimeout   = 30 * time.Second
+	maxWatchTimeout       = 5 * time.Minute
+)
+
+type (
+	keyChangeEvent struct {
+		Type string `json:"type"`
+		Key  string `json:"key"`
+		ETag string `json:"etag,omitempty"`
+	}
+)
+
+/*
+
+Long polling
+============
+GET /values/key?watch=true&timeout=30s
+
+Reads of a key (GET routes with a key) wait until the key changes, or until the timeout (30 seconds by default, up to
+5 minutes), before running the command as usual. With If-None-Match the request only waits while the key ETag matches
+it, so changes between two polls are never missed: the response of a timeout is 304, and otherwise the new value.
+
+Key changes stream
+==================
+GET /watch?pattern=user:*&pattern=session:*
+
+The changes of the keys matching any of the patterns are streamed as Server-Sent Events with JSON data (the database
+event name, the key and its new ETag), until the client disconnects.
+
+*/
+
+// getWatchTimeout returns the watch timeout of a key read request (zero when not watching), removing the watch
+// parameters from the query.
+func getWatchTimeout(query url.Values) (timeout time.Duration, err error) {
+	if !query.Has(watchParameter) {
+		return 0, nil
+	}
+
+	var watch = true
+
+	if value := query.Get(watchParameter); value != "" {
+		if watch, err = strconv.ParseBool(value); err != nil {
+			return 0, fmt.Errorf("invalid watch parameter '%s'", value)
+		}
+	}
+
+	timeout = defaultWatchTimeout
+
+	if value := query.Get(watchTimeoutParameter); value != "" {
+		if timeout, err = time.ParseDuration(value); (err != nil) || (timeout <= 0) || (timeout > maxWatchTimeout) {
+			return 0, fmt.Errorf("invalid watch timeout '%s', expected a duration up to %v", value, maxWatchTimeout)
+		}
+	}
+
+	query.Del(watchParameter)
+	query.Del(watchTimeoutParameter)
+
+	if !watch {
+		return 0, nil
+	}
+
+	return timeout, nil
+}
+
+// waitForChange blocks until a key changes, the timeout expires or the client goes away. Requests with If-None-Match
+// only wait while it matches the current key ETag. The server timeouts start again once the wait is over.
+func (server *httpServer) waitForChange(response http.ResponseWriter, request *http.Request, key string, timeout time.Duration) {
+	var watcher = server.runtime.WatchKey(key)
+	defer watcher.Close()
+
+	if tags := request.Header.Values("If-None-Match"); len(tags) > 0 {
+		var version = server.runtime.GetKeyVersion(key)
+
+		if !matchETags(joinHeaderValues(tags), version, true) {
+			return
+		}
+	}
+
+	disableDeadlines(response)
+
+	if server.limits.WriteTimeout > 0 {
+		defer http.NewResponseController(response).SetWriteDeadline(time.Now().Add(server.limits.WriteTimeout))
+	}
+
+	var timer = time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-watcher.Changes():
+	case <-timer.C:
+	case <-request.Context().Done():
+	}
+}
+
+// serveWatch streams the changes of the keys matching the pattern parameters as Server-Sent Events.
+func (server *httpServer) serveWatch(response http.ResponseWriter, request *http.Request) {
+	var patterns = request.URL.Query()[watchPatternParameter]
+
+	if len(patterns) == 0 {
+		http.Error(response, "missing pattern parameter", http.StatusBadRequest)
+		return
+	}
+
+	var stream, ok = createEventStream(response)
+
+	if !ok {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var watcher = server.runtime.Watch(patterns...)
+	defer watcher.Close()
+
+	log.Printf("WATCH: %s watching %d patterns", request.RemoteAddr, len(patterns))
+
+	defer func() {
+		log.Printf("WATCH: %s stopped watching (%d changes dropped)", request.RemoteAddr, watcher.GetDropped())
+	}()
+
+	streamEvents(request.Context(), stream, watcher.Changes(), watcher.GetDropped, func(change vm.KeyChange) error {
+		var event = keyChangeEvent{Type: change.Event, Key: change.Key}
+
+		if change.Version != 0 {
+			event.ETag = formatETag(change.Version)
+		}
+
+		var data, _ = json.Marshal(event)
+		return stream.send(event.Type, string(data))
+	})
+}
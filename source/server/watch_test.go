@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForWatchers waits until the server has the given number of watching clients.
+func waitForWatchers(test *testing.T, httpServer *httptest.Server, count string) {
+	var deadline = time.Now().Add(5 * time.Second)
+
+	for {
+		var _, body = executeLine(test, httpServer, "INFO clients")
+
+		if strings.Contains(body, "watching_clients:"+count+"\n") || strings.HasSuffix(body, "watching_clients:"+count) {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			test.Fatalf("expected %s watching clients: %q", count, body)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLongPoll(test *testing.T) {
+	var httpServer, _ = createTestServer(test, nil)
+
+	executeLine(test, httpServer, "SET k v")
+
+	var response, err = http.Get(httpServer.URL + "/values/k")
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	response.Body.Close()
+
+	var etag = response.Header.Get("ETag")
+
+	// The timeout of an unchanged value is not modified.
+
+	var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/values/k?watch=true&timeout=100ms", nil)
+	request.Header.Set("If-None-Match", etag)
+
+	var start = time.Now()
+
+	if status, body := doRequest(test, request); (status != http.StatusNotModified) || (time.Since(start) < 100*time.Millisecond) {
+		test.Errorf("long poll timeout = %d %q after %v", status, body, time.Since(start))
+	}
+
+	// A write wakes the long poll up with the new value.
+
+	type pollResult struct {
+		status int
+		body   string
+	}
+
+	var results = make(chan pollResult, 1)
+
+	go func() {
+		var request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/values/k?watch=true&timeout=10s", nil)
+		request.Header.Set("If-None-Match", etag)
+
+		var response, err = http.DefaultClient.Do(request)
+
+		if err != nil {
+			results <- pollResult{body: err.Error()}
+			return
+		}
+
+		defer response.Body.Close()
+
+		var body, _ = io.ReadAll(response.Body)
+		results <- pollResult{response.StatusCode, string(body)}
+	}()
+
+	waitForWatchers(test, httpServer, "1")
+	executeLine(test, httpServer, "SET k w")
+
+	select {
+	case result := <-results:
+		if (result.status != http.StatusOK) || (result.body != "w") {
+			test.Errorf("long poll woken up by a write = %d %q", result.status, result.body)
+		}
+	case <-time.After(5 * time.Second):
+		test.Fatal("the long poll was not woken up by the write")
+	}
+
+	waitForWatchers(test, httpServer, "0")
+
+	// A canceled long poll stops watching.
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var canceled = make(chan error, 1)
+
+	go func() {
+		var request, _ = http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/values/k?watch=true&timeout=10s", nil)
+		var response, err = http.DefaultClient.Do(request)
+
+		if err == nil {
+			response.Body.Close()
+		}
+
+		canceled <- err
+	}()
+
+	waitForWatchers(test, httpServer, "1")
+	cancel()
+
+	if err := <-canceled; err == nil {
+		test.Error("the canceled long poll returned a response")
+	}
+
+	waitForWatchers(test, httpServer, "0")
+}
+
+func TestWatchStream(test *testing.T) {
+	var httpServer, _ = createTestServer(test, nil)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var request, _ = http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/watch?pattern=user:*", nil)
+	var response, err = http.DefaultClient.Do(request)
+
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	if contentType := response.Header.Get("Content-Type"); (response.StatusCode != http.StatusOK) || (contentType != "text/event-stream") {
+		test.Fatalf("GET /watch = %d %q", response.StatusCode, contentType)
+	}
+
+	waitForWatchers(test, httpServer, "1")
+
+	for _, line := range []string{"SET user:1 a", "SET other b", "DEL user:1"} {
+		executeLine(test, httpServer, line)
+	}
+
+	var reader = bufio.NewReader(response.Body)
+	var events []string
+
+	for len(events) < 2 {
+		var line, err = reader.ReadString('\n')
+
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if data, found := strings.CutPrefix(strings.TrimSpace(line), "data: "); found {
+			events = append(events, data)
+		}
+	}
+
+	if !strings.HasPrefix(events[0], `{"type":"set","key":"user:1","etag":"\"`) || (events[1] != `{"type":"del","key":"user:1"}`) {
+		test.Errorf("events = %q", events)
+	}
+
+	cancel()
+	waitForWatchers(test, httpServer, "0")
+}
@@ -0,0 +1,340 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"arc/cluster"
+	"arc/database"
+)
+
+// AskingHeader is the HTTP header clients set when following an ASK redirection.
+const AskingHeader = "X-Arc-Asking"
+
+// Cluster redirection result prefixes.
+const (
+	MovedPrefix = "MOVED"
+	AskPrefix   = "ASK"
+)
+
+// Timeout used when migrating keys to another node.
+const migrateTimeout = 5 * time.Second
+
+// GetRedirection returns the address a client must be redirected to for a MOVED or ASK result (if any).
+func GetRedirection(result []string) (address string, asking bool, isRedirection bool) {
+	if len(result) != 1 {
+		return
+	}
+
+	var fields = strings.Fields(result[0])
+
+	if (len(fields) != 3) || ((fields[0] != MovedPrefix) && (fields[0] != AskPrefix)) {
+		return
+	}
+
+	return fields[2], fields[0] == AskPrefix, true
+}
+
+// routeKeys checks if the keys are handled by this node, returning a redirection (or error) result if they are not.
+func (runtime *Runtime) routeKeys(keys []string, asking bool) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var slot = cluster.KeySlot(keys[0])
+
+	for index := 1; index < len(keys); index++ {
+		if cluster.KeySlot(keys[index]) != slot {
+			return crossSlotResult
+		}
+	}
+
+	var route = runtime.cluster.GetRoute(slot, func() bool {
+		for index := range keys {
+			if !runtime.db.Has(keys[index]) {
+				return false
+			}
+		}
+
+		return true
+	}, asking)
+
+	switch {
+	case route.Local:
+		return nil
+	case route.Redirect:
+		return []string{fmt.Sprintf("%s %d %s", MovedPrefix, route.Slot, route.Address)}
+	case route.Asking:
+		return []string{fmt.Sprintf("%s %d %s", AskPrefix, route.Slot, route.Address)}
+	}
+
+	return clusterDownResult
+}
+
+// getMyAddress returns the address announced to the other nodes and clients.
+func (runtime *Runtime) getMyAddress() string {
+	if address := runtime.cluster.GetMyself().Address; address != "" {
+		return address
+	}
+
+	var host, port, err = net.SplitHostPort(runtime.serverInfo.GetAddress())
+
+	if err != nil {
+		return ""
+	}
+
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// parseSlots parses a list of slots, each one of them either a single slot or an inclusive "start-end" range.
+func parseSlots(parameters []string) (slots []int, ok bool) {
+	for index := range parameters {
+		var start, end, isRange = strings.Cut(parameters[index], "-")
+
+		if !isRange {
+			end = start
+		}
+
+		var first, firstError = strconv.Atoi(start)
+		var last, lastError = strconv.Atoi(end)
+
+		if (firstError != nil) || (lastError != nil) || (first < 0) || (first > last) || (last >= cluster.NumberOfSlots) {
+			return nil, false
+		}
+
+		for slot := first; slot <= last; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots, len(slots) > 0
+}
+
+func clusterErrorResult(err error) []string {
+	if err != nil {
+		return []string{"Error: " + err.Error()}
+	}
+
+	return okResult
+}
+
+// CLUSTER INFO | MYID | NODES | SLOTS | ADDSLOTS slot [slot...] | DELSLOTS slot [slot...] | MEET node-id address |
+// FORGET node-id | SETSLOT slot IMPORTING|MIGRATING|NODE node-id | SETSLOT slot STABLE | KEYSLOT key |
+// COUNTKEYSINSLOT slot | GETKEYSINSLOT slot count
+//
+// There is no gossip between nodes, so the topology must be configured on every node. Slots may be given as "start-end"
+// ranges by ADDSLOTS, DELSLOTS and SETSLOT.
+func stdCluster(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	runtime.cluster.SetMyAddress(runtime.getMyAddress())
+
+	switch parameters.Get("subcommand") {
+	case "INFO":
+		var assigned = runtime.cluster.CountSlots()
+		var state = "fail"
+
+		if assigned == cluster.NumberOfSlots {
+			state = "ok"
+		}
+
+		return []string{strings.Join([]string{
+			fmt.Sprintf("cluster_enabled:%d", boolToInt(runtime.clusterEnabled.Load())),
+			"cluster_state:" + state,
+			fmt.Sprintf("cluster_slots_assigned:%d", assigned),
+			fmt.Sprintf("cluster_known_nodes:%d", len(runtime.cluster.GetNodes())),
+			"cluster_my_id:" + runtime.cluster.GetMyself().ID,
+		}, "\n")}
+	case "MYID":
+		return []string{runtime.cluster.GetMyself().ID}
+	case "NODES":
+		return []string{strings.Join(runtime.cluster.Describe(), "\n")}
+	case "SLOTS":
+		var ranges = runtime.cluster.GetSlotRanges()
+		var lines = make([]string, len(ranges))
+
+		for index := range ranges {
+			lines[index] = fmt.Sprintf("%d %d %s %s", ranges[index].Start, ranges[index].End, ranges[index].Node.Address, ranges[index].Node.ID)
+		}
+
+		return []string{strings.Join(lines, "\n")}
+	case "ADDSLOTS", "DELSLOTS":
+		var slots, ok = parseSlots(parameters.GetAll("slot"))
+
+		if !ok {
+			return invalidParameterValueResult
+		}
+
+		if parameters.Has("ADDSLOTS") {
+			return clusterErrorResult(runtime.cluster.AddSlots(slots))
+		}
+
+		return clusterErrorResult(runtime.cluster.DeleteSlots(slots))
+	case "MEET":
+		runtime.cluster.Meet(parameters.Get("node-id"), parameters.Get("address"))
+		return okResult
+	case "FORGET":
+		return clusterErrorResult(runtime.cluster.Forget(parameters.Get("node-id")))
+	case "SETSLOT":
+		var slots, ok = parseSlots(parameters.GetAll("slot"))
+
+		if !ok {
+			return invalidParameterValueResult
+		}
+
+		var nodeID = parameters.Get("node-id")
+		var migrationState, setOwner = cluster.Stable, false
+
+		switch parameters.Get("state") {
+		case "IMPORTING":
+			migrationState = cluster.Importing
+		case "MIGRATING":
+			migrationState = cluster.Migrating
+		case "NODE":
+			setOwner = true
+		}
+
+		for _, slot := range slots {
+			if err := runtime.cluster.SetSlot(slot, migrationState, nodeID, setOwner); err != nil {
+				return clusterErrorResult(err)
+			}
+		}
+
+		return okResult
+	case "KEYSLOT":
+		return []string{strconv.Itoa(cluster.KeySlot(parameters.Get("key")))}
+	}
+
+	var slot = int(parameters.GetInt("slot"))
+
+	if (slot < 0) || (slot >= cluster.NumberOfSlots) {
+		return invalidParameterValueResult
+	}
+
+	if parameters.Has("COUNTKEYSINSLOT") {
+		return []string{strconv.Itoa(runtime.slotKeys.Count(slot))}
+	}
+
+	return execution.dataResult(runtime.slotKeys.GetKeys(slot, int(parameters.GetInt("count"))))
+}
+
+// indexKeySlots is the database listener that keeps the keys of each hash slot.
+func (runtime *Runtime) indexKeySlots(event database.Event) {
+	if event.Version == 0 {
+		runtime.slotKeys.Remove(event.Key)
+	} else {
+		runtime.slotKeys.Add(event.Key)
+	}
+}
+
+// MIGRATE host port key [key...]
+func stdMigrate(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	// Keys are transferred without the write lock, which is only taken to remove them once transferred (if they did
+	// not change meanwhile). The migrated keys are propagated to replicas as deletions.
+
+	var address = "http://" + net.JoinHostPort(parameters.Get("host"), parameters.Get("port"))
+	var httpClient = &http.Client{Timeout: migrateTimeout}
+	var migrated = 0
+
+	for _, key := range parameters.GetAll("key") {
+		var version = runtime.db.GetVersion(key)
+		var entry, exists = runtime.db.SnapshotKey(key)
+
+		if !exists {
+			continue
+		}
+
+		var arguments = snapshotToCommand(entry)
+
+		if arguments == nil {
+			continue
+		}
+
+		// Sorted sets are added to, so the destination key is deleted first to replace it.
+
+		var commands = [][]string{arguments}
+
+		if entry.Type == database.SortedSetValue {
+			commands = [][]string{{"DEL", key}, arguments}
+		}
+
+		if errorResult := migrateKey(execution.Context, httpClient, address, key, commands); errorResult != nil {
+			return errorResult
+		}
+
+		runtime.replication.writeMutex.Lock()
+		var changed = runtime.db.GetVersion(key) != version
+
+		if !changed && runtime.db.Unset(key) {
+			runtime.replication.propagate([]string{"DEL", key})
+		}
+
+		runtime.replication.writeMutex.Unlock()
+
+		if changed {
+			return []string{fmt.Sprintf("Error: migrate failed for key %s: the key changed during the migration", key)}
+		}
+
+		migrated++
+	}
+
+	if migrated == 0 {
+		return []string{"NOKEY"}
+	}
+
+	return okResult
+}
+
+// migrateKey runs the commands recreating a key on the destination node as a single batch (stopped at the first
+// error), and returns the error result unless every command succeeded.
+func migrateKey(ctx context.Context, httpClient *http.Client, address string, key string, commands [][]string) []string {
+	var body, _ = json.Marshal(commands)
+	var request, _ = http.NewRequestWithContext(ctx, http.MethodPost, address+"/batch?typed=true&stop-on-error=true", bytes.NewReader(body))
+	request.Header.Set(AskingHeader, "1")
+	request.Header.Set("Content-Type", "application/json")
+
+	var response, err = httpClient.Do(request)
+
+	if err != nil {
+		if cancellation := getCancellationResult(ctx.Err()); cancellation != nil {
+			return cancellation
+		}
+
+		return []string{"Error: migrate failed: " + err.Error()}
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var message, _ = io.ReadAll(io.LimitReader(response.Body, 1024))
+		return []string{fmt.Sprintf("Error: migrate failed for key %s: %s %s", key, response.Status, strings.TrimSpace(string(message)))}
+	}
+
+	var results []TypedResult
+
+	if err = json.NewDecoder(response.Body).Decode(&results); err != nil {
+		return []string{fmt.Sprintf("Error: migrate failed for key %s: %v", key, err)}
+	}
+
+	for index := range results {
+		if results[index].Type == ErrorResult {
+			return []string{fmt.Sprintf("Error: migrate failed for key %s: %s", key, strings.Join(results[index].Values, " "))}
+		}
+	}
+
+	if len(results) != len(commands) {
+		return []string{fmt.Sprintf("Error: migrate failed for key %s: %d of %d commands executed", key, len(results), len(commands))}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package vm
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"arc/cluster"
+)
+
+func TestKeysInSlot(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var slot = strconv.Itoa(cluster.KeySlot("user"))
+
+	testRuntime.Execute("SET {user}.name alice")
+	testRuntime.Execute("ZADD {user}.scores 1 game")
+	testRuntime.Execute("SET {user}.email alice@example.com")
+	testRuntime.Execute("SET other 1")
+	testRuntime.Execute("RENAME {user}.email {user}.mail")
+	testRuntime.Execute("DEL {user}.name")
+
+	var tests = []struct {
+		line     string
+		expected string
+	}{
+		{"CLUSTER COUNTKEYSINSLOT " + slot, "2"},
+		{"CLUSTER GETKEYSINSLOT " + slot + " 10", "{user}.mail {user}.scores"},
+		{"CLUSTER GETKEYSINSLOT " + slot + " 1", "{user}.mail"},
+		{"CLUSTER COUNTKEYSINSLOT " + strconv.Itoa((cluster.KeySlot("user")+1)%cluster.NumberOfSlots), "0"},
+	}
+
+	for _, testCase := range tests {
+		if result := strings.Join(testRuntime.Execute(testCase.line), " "); result != testCase.expected {
+			test.Errorf("%s = %q, expected %q", testCase.line, result, testCase.expected)
+		}
+	}
+}
+
+func TestMigrate(test *testing.T) {
+	var source = createTestRuntime(test)
+	var destination = createTestRuntime(test)
+	var writeOnce sync.Once
+	var failedCommand atomic.Value
+	var largeValue = strings.Repeat("x", 2*1024*1024)
+
+	failedCommand.Store("")
+
+	var server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		// Writes on the source node are not blocked while keys are transferred.
+
+		writeOnce.Do(func() {
+			source.Execute("SET during-migration 1")
+		})
+
+		var commands [][]string
+		var results []TypedResult
+
+		if (request.Method != http.MethodPost) || (request.URL.Path != "/batch") || (json.NewDecoder(request.Body).Decode(&commands) != nil) {
+			response.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		for _, command := range commands {
+			var result = destination.ExecuteArgsTyped(ExecutionContext{Client: "test", Asking: true}, command)
+
+			if command[0] == failedCommand.Load() {
+				result = TypedResult{Type: ErrorResult, Values: []string{"Error: failed"}}
+			}
+
+			if results = append(results, result); result.Type == ErrorResult {
+				break
+			}
+		}
+
+		json.NewEncoder(response).Encode(results)
+	}))
+
+	defer server.Close()
+
+	var host, port, _ = net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+
+	source.Execute("SET {user}.name alice")
+	source.Execute("ZADD {user}.scores 1 chess 2 go")
+	source.ExecuteArgs([]string{"SET", "{user}.large", largeValue})
+	destination.Execute("ZADD {user}.scores 10 poker")
+
+	if result := strings.Join(source.Execute("MIGRATE "+host+" "+port+" {user}.name {user}.scores {user}.large {user}.missing"), " "); result != "OK" {
+		test.Fatalf("MIGRATE = %q", result)
+	}
+
+	if result := destination.ExecuteArgs([]string{"GET", "{user}.large"}); (len(result) != 1) || (result[0] != largeValue) {
+		test.Errorf("GET {user}.large returned %d values", len(result))
+	}
+
+	// A key whose commands failed on the destination is kept on the source.
+
+	source.Execute("ZADD {user}.kept 1 chess")
+	failedCommand.Store("ZADD")
+
+	if result := source.Execute("MIGRATE " + host + " " + port + " {user}.kept"); !strings.Contains(strings.Join(result, " "), "migrate failed for key {user}.kept") {
+		test.Errorf("MIGRATE of a failed key = %q", result)
+	}
+
+	var tests = []struct {
+		runtime  *Runtime
+		line     string
+		expected string
+	}{
+		{destination, "GET {user}.name", "alice"},
+		{destination, "ZRANGE {user}.scores 0 -1", "chess go"},
+		{source, "TYPE {user}.name", "none"},
+		{source, "TYPE {user}.scores", "none"},
+		{source, "TYPE {user}.large", "none"},
+		{source, "GET during-migration", "1"},
+		{source, "MIGRATE " + host + " " + port + " {user}.name", "NOKEY"},
+		{source, "ZRANGE {user}.kept 0 -1", "chess"},
+	}
+
+	for _, testCase := range tests {
+		if result := strings.Join(testCase.runtime.Execute(testCase.line), " "); result != testCase.expected {
+			test.Errorf("%s = %q, expected %q", testCase.line, result, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// commandDescription holds the metadata of a library command.
+type commandDescription struct {
+	name       string
+	arity      arity
+	flags      int
+	keys       keySpec
+	categories []string
+	syntax     string
+	summary    string
+	complexity string
+	since      string
+}
+
+// defaultCommandGroup is the documentation group of the commands without categories.
+const defaultCommandGroup = "generic"
+
+// describeLibrary returns the description of each library command, sorted by name.
+func describeLibrary(library Library) (descriptions []*commandDescription) {
+	for index := range library {
+		var function = &library[index]
+
+		descriptions = append(descriptions, &commandDescription{
+			name:       function.command,
+			arity:      getArity(function.arguments),
+			flags:      function.flags,
+			keys:       function.keys,
+			categories: function.categories,
+			syntax:     function.GetHelp(),
+			summary:    function.summary,
+			complexity: function.complexity,
+			since:      function.since,
+		})
+	}
+
+	sort.Slice(descriptions, func(i, j int) bool {
+		return descriptions[i].name < descriptions[j].name
+	})
+
+	return
+}
+
+// CommandInfo holds the public metadata of a library command, as reported by COMMAND INFO and COMMAND DOCS.
+type CommandInfo struct {
+	Name       string
+	Summary    string
+	Syntax     string
+	Complexity string
+	Since      string
+	Flags      []string
+	Categories []string
+
+	// MinArgs and MaxArgs are the minimum and maximum (negative for unlimited) number of parameters.
+	MinArgs int
+	MaxArgs int
+}
+
+// GetCommands returns the metadata of the library commands, sorted by name.
+func (runtime *Runtime) GetCommands() (commands []CommandInfo) {
+	for _, description := range runtime.commands {
+		commands = append(commands, CommandInfo{
+			Name:       description.name,
+			Summary:    description.summary,
+			Syntax:     description.syntax,
+			Complexity: description.complexity,
+			Since:      description.since,
+			Flags:      description.getFlagNames(),
+			Categories: description.getCategories(),
+			MinArgs:    description.arity.minimum,
+			MaxArgs:    description.arity.maximum,
+		})
+	}
+
+	return
+}
+
+func (runtime *Runtime) getCommandDescription(name string) *commandDescription {
+	name = strings.ToUpper(name)
+
+	for _, description := range runtime.commands {
+		if description.name == name {
+			return description
+		}
+	}
+
+	return nil
+}
+
+// getFlagNames returns the names of the command flags.
+func (description *commandDescription) getFlagNames() (names []string) {
+	for _, flag := range flagNames {
+		if description.flags&flag.flag != 0 {
+			names = append(names, flag.name)
+		}
+	}
+
+	return
+}
+
+// getCategories returns the command categories, including the ones given by its flags (write, read, admin, blocking
+// and fast or slow).
+func (description *commandDescription) getCategories() (categories []string) {
+	categories = append(categories, description.categories...)
+
+	if description.flags&writeFlag != 0 {
+		categories = append(categories, "write")
+	}
+
+	if description.flags&readOnlyFlag != 0 {
+		categories = append(categories, "read")
+	}
+
+	if description.flags&adminFlag != 0 {
+		categories = append(categories, "admin")
+	}
+
+	if description.flags&blockingFlag != 0 {
+		categories = append(categories, "blocking")
+	}
+
+	if description.flags&fastFlag != 0 {
+		categories = append(categories, "fast")
+	} else {
+		categories = append(categories, "slow")
+	}
+
+	return
+}
+
+// info formats the command information as a single line, key positions are 1 based (counting the command name) as
+// in the command line.
+func (description *commandDescription) info() string {
+	var firstKey, lastKey = 0, 0
+
+	if description.keys.step != 0 {
+		firstKey = description.keys.first + 1
+		lastKey = description.keys.last
+
+		if lastKey >= 0 {
+			lastKey++
+		}
+	}
+
+	return fmt.Sprintf("%s min-args:%d max-args:%d flags:%s first-key:%d last-key:%d key-step:%d categories:%s",
+		description.name, description.arity.minimum, description.arity.maximum, strings.Join(description.getFlagNames(), ","),
+		firstKey, lastKey, description.keys.step, strings.Join(description.getCategories(), ","))
+}
+
+// docs formats the command documentation as INFO like "field:value" lines.
+func (description *commandDescription) docs() string {
+	var group = defaultCommandGroup
+
+	if len(description.categories) > 0 {
+		group = description.categories[0]
+	}
+
+	return strings.Join([]string{
+		description.name,
+		"summary:" + description.summary,
+		"syntax:" + description.syntax,
+		"complexity:" + description.complexity,
+		"since:" + description.since,
+		"group:" + group,
+	}, "\n")
+}
+
+// COMMAND | COMMAND COUNT | COMMAND INFO command [command...] | COMMAND DOCS [command...]
+func stdCommand(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var names = parameters.GetAll("command")
+
+	switch parameters.Get("subcommand") {
+	case "COUNT":
+		return []string{strconv.Itoa(len(runtime.commands))}
+	case "INFO":
+		var result = make([]string, len(names))
+
+		for index, name := range names {
+			if description := runtime.getCommandDescription(name); description != nil {
+				result[index] = description.info()
+			} else {
+				result[index] = NilMessage
+			}
+		}
+
+		return result
+	case "DOCS":
+		if len(names) == 0 {
+			for _, description := range runtime.commands {
+				names = append(names, description.name)
+			}
+		}
+
+		var result = make([]string, len(names))
+
+		for index, name := range names {
+			if description := runtime.getCommandDescription(name); description != nil {
+				result[index] = description.docs()
+			} else {
+				result[index] = NilMessage
+			}
+		}
+
+		return result
+	}
+
+	var result = make([]string, len(runtime.commands))
+
+	for index, description := range runtime.commands {
+		result[index] = description.info()
+	}
+
+	return result
+}
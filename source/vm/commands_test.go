@@ -0,0 +1,50 @@
+package vm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLibraryMetadata(test *testing.T) {
+	for _, function := range StandardLibrary {
+		if (function.summary == "") || (function.complexity == "") || (function.since == "") || (len(function.categories) == 0) {
+			test.Errorf("%s: missing documentation", function.command)
+		}
+
+		if (function.flags&writeFlag != 0) && (function.flags&readOnlyFlag != 0) {
+			test.Errorf("%s: both write and read only", function.command)
+		}
+	}
+}
+
+func TestCommandDocsGroup(test *testing.T) {
+	var descriptions = describeLibrary(Library{{command: "PLAIN", summary: "A command without categories."}})
+
+	if docs := descriptions[0].docs(); !strings.HasSuffix(docs, "group:"+defaultCommandGroup) {
+		test.Errorf("docs of a command without categories = %q", docs)
+	}
+}
+
+func TestCommand(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	if result := testRuntime.Execute("COMMAND COUNT"); (len(result) != 1) || (result[0] != strconv.Itoa(len(testRuntime.commands))) {
+		test.Errorf("COMMAND COUNT = %q", result)
+	}
+
+	var result = testRuntime.Execute("COMMAND INFO set nope")
+
+	if (len(result) != 2) || (result[1] != NilMessage) ||
+		(result[0] != "SET min-args:2 max-args:5 flags:write,denyoom first-key:1 last-key:1 key-step:1 categories:string,write,slow") {
+		test.Errorf("COMMAND INFO = %q", result)
+	}
+
+	if result = testRuntime.Execute("COMMAND DOCS get"); (len(result) != 1) || !strings.Contains(result[0], "syntax:GET key") {
+		test.Errorf("COMMAND DOCS = %q", result)
+	}
+
+	if result = testRuntime.Execute("DEL"); result[0] != invalidParametersErrorMessage+": missing 'key'" {
+		test.Errorf("DEL without keys = %q", result)
+	}
+}
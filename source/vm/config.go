@@ -0,0 +1,284 @@
+package vm
+
+import (
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	configParameter struct {
+		get func(runtime *Runtime) string
+		set func(runtime *Runtime, value string) bool
+	}
+)
+
+// Runtime configuration parameters available through the CONFIG command.
+var configParameters = map[string]configParameter{
+	"cluster-enabled": {
+		get: func(runtime *Runtime) string {
+			return formatBoolean(runtime.clusterEnabled.Load())
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if enabled, ok := parseBoolean(value); ok {
+				runtime.clusterEnabled.Store(enabled)
+				return true
+			}
+
+			return false
+		},
+	},
+	"cluster-announce-address": {
+		get: func(runtime *Runtime) string {
+			return runtime.cluster.GetMyself().Address
+		},
+		set: func(runtime *Runtime, value string) bool {
+			runtime.cluster.SetMyAddress(value)
+			return true
+		},
+	},
+	"command-timeout": {
+		get: func(runtime *Runtime) string {
+			return strconv.FormatInt(time.Duration(runtime.commandTimeout.Load()).Milliseconds(), 10)
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if milliseconds, err := strconv.ParseInt(value, 10, 64); (err == nil) && (milliseconds >= 0) {
+				runtime.commandTimeout.Store(int64(time.Duration(milliseconds) * time.Millisecond))
+				return true
+			}
+
+			return false
+		},
+	},
+	"max-arguments": {
+		get: func(runtime *Runtime) string {
+			return strconv.FormatInt(runtime.maxArguments.Load(), 10)
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if maxArguments, err := strconv.ParseInt(value, 10, 64); (err == nil) && ((maxArguments == 0) || (maxArguments >= minMaxArguments)) {
+				runtime.maxArguments.Store(maxArguments)
+				return true
+			}
+
+			return false
+		},
+	},
+	"max-argument-length": {
+		get: func(runtime *Runtime) string {
+			return strconv.FormatInt(runtime.maxArgumentLength.Load(), 10)
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if maxLength, ok := parseMemorySize(value); ok && ((maxLength == 0) || (maxLength >= minMaxArgumentLength)) {
+				runtime.maxArgumentLength.Store(maxLength)
+				return true
+			}
+
+			return false
+		},
+	},
+	"maxmemory": {
+		get: func(runtime *Runtime) string {
+			return strconv.FormatInt(runtime.db.GetMaxMemory(), 10)
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if maxMemory, ok := parseMemorySize(value); ok {
+				runtime.db.SetMaxMemory(maxMemory)
+				return true
+			}
+
+			return false
+		},
+	},
+	"maxmemory-policy": {
+		get: func(runtime *Runtime) string {
+			return runtime.db.GetEvictionPolicy()
+		},
+		set: func(runtime *Runtime, value string) bool {
+			return runtime.db.SetEvictionPolicy(value)
+		},
+	},
+	"maxmemory-samples": {
+		get: func(runtime *Runtime) string {
+			return strconv.Itoa(runtime.db.GetEvictionSamples())
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if samples, err := strconv.Atoi(value); (err == nil) && (samples > 0) {
+				runtime.db.SetEvictionSamples(samples)
+				return true
+			}
+
+			return false
+		},
+	},
+	"notify-keyspace-events": {
+		get: func(runtime *Runtime) string {
+			return formatNotifyFlags(runtime.notifyFlags.Load())
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if flags, ok := parseNotifyFlags(value); ok {
+				runtime.notifyFlags.Store(flags)
+				return true
+			}
+
+			return false
+		},
+	},
+	"replica-read-only": {
+		get: func(runtime *Runtime) string {
+			runtime.replication.mutex.Lock()
+			defer runtime.replication.mutex.Unlock()
+
+			return formatBoolean(runtime.replication.readOnly)
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if readOnly, ok := parseBoolean(value); ok {
+				runtime.replication.mutex.Lock()
+				runtime.replication.readOnly = readOnly
+				runtime.replication.mutex.Unlock()
+				return true
+			}
+
+			return false
+		},
+	},
+	"repl-backlog-size": {
+		get: func(runtime *Runtime) string {
+			runtime.replication.mutex.Lock()
+			defer runtime.replication.mutex.Unlock()
+
+			return strconv.Itoa(runtime.replication.backlogSize)
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if size, err := strconv.Atoi(value); (err == nil) && (size > 0) {
+				runtime.replication.mutex.Lock()
+				runtime.replication.backlogSize = size
+				runtime.replication.mutex.Unlock()
+				return true
+			}
+
+			return false
+		},
+	},
+	"slowlog-log-slower-than": {
+		get: func(runtime *Runtime) string {
+			return strconv.FormatInt(runtime.slowLog.getThreshold().Microseconds(), 10)
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if microseconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				runtime.slowLog.setThreshold(time.Duration(microseconds) * time.Microsecond)
+				return true
+			}
+
+			return false
+		},
+	},
+	"slowlog-max-len": {
+		get: func(runtime *Runtime) string {
+			return strconv.Itoa(runtime.slowLog.getMaxLength())
+		},
+		set: func(runtime *Runtime, value string) bool {
+			if maxLength, err := strconv.Atoi(value); (err == nil) && (maxLength >= 0) {
+				runtime.slowLog.setMaxLength(maxLength)
+				return true
+			}
+
+			return false
+		},
+	},
+}
+
+// Memory size units accepted by memory configuration parameters (e.g. 100mb).
+var memoryUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"kb", 1024}, {"mb", 1024 * 1024}, {"gb", 1024 * 1024 * 1024},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+func parseBoolean(value string) (result bool, ok bool) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return true, true
+	case "no":
+		return false, true
+	}
+
+	return false, false
+}
+
+func formatBoolean(value bool) string {
+	if value {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// parseMemorySize parses a memory size in bytes with an optional unit (like 100mb), sizes that don't fit in an int64
+// are invalid.
+func parseMemorySize(value string) (size int64, ok bool) {
+	var multiplier int64 = 1
+
+	value = strings.ToLower(value)
+
+	for index := range memoryUnits {
+		if strings.HasSuffix(value, memoryUnits[index].suffix) {
+			value = strings.TrimSuffix(value, memoryUnits[index].suffix)
+			multiplier = memoryUnits[index].multiplier
+			break
+		}
+	}
+
+	if parsed, err := strconv.ParseInt(value, 10, 64); (err == nil) && (parsed >= 0) && (parsed <= math.MaxInt64/multiplier) {
+		return parsed * multiplier, true
+	}
+
+	return 0, false
+}
+
+// SetConfig sets a runtime configuration parameter value.
+func (runtime *Runtime) SetConfig(name string, value string) (ok bool) {
+	if parameter, exists := configParameters[strings.ToLower(name)]; exists {
+		return parameter.set(runtime, value)
+	}
+
+	return false
+}
+
+// CONFIG GET pattern | CONFIG SET parameter value
+func stdConfig(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if parameters.Get("subcommand") == "SET" {
+		if _, exists := configParameters[strings.ToLower(parameters.Get("parameter"))]; !exists {
+			return invalidParametersResult
+		}
+
+		if !runtime.SetConfig(parameters.Get("parameter"), parameters.Get("value")) {
+			return invalidParameterValueResult
+		}
+
+		return okResult
+	}
+
+	var names = make([]string, 0)
+
+	for name := range configParameters {
+		if matched, _ := path.Match(strings.ToLower(parameters.Get("pattern")), name); matched {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var result = make([]string, 0, len(names)*2)
+
+	for index := range names {
+		result = append(result, names[index], configParameters[names[index]].get(runtime))
+	}
+
+	return result
+}
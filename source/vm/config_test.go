@@ -0,0 +1,42 @@
+package vm
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParseMemorySize(test *testing.T) {
+	var tests = []struct {
+		value    string
+		expected int64
+		ok       bool
+	}{
+		{"0", 0, true},
+		{"100", 100, true},
+		{"1kb", 1024, true},
+		{"2MB", 2 * 1024 * 1024, true},
+		{"3g", 3 * 1000 * 1000 * 1000, true},
+		{"10b", 10, true},
+		{strconv.FormatInt(math.MaxInt64, 10), math.MaxInt64, true},
+		{strconv.FormatInt(math.MaxInt64/1024, 10) + "kb", math.MaxInt64 / 1024 * 1024, true},
+		{strconv.FormatInt(math.MaxInt64/1024+1, 10) + "kb", 0, false},
+		{"9999999999g", 0, false},
+		{"9999999999gb", 0, false},
+		{"-1", 0, false},
+		{"1tb", 0, false},
+		{"", 0, false},
+	}
+
+	for _, testCase := range tests {
+		if size, ok := parseMemorySize(testCase.value); (size != testCase.expected) || (ok != testCase.ok) {
+			test.Errorf("parseMemorySize(%q) = %d %v, expected %d %v", testCase.value, size, ok, testCase.expected, testCase.ok)
+		}
+	}
+
+	var testRuntime = createTestRuntime(test)
+
+	if result := testRuntime.Execute("CONFIG SET maxmemory 9999999999g"); GetError(result) == nil {
+		test.Errorf("CONFIG SET maxmemory 9999999999g = %q", result)
+	}
+}
@@ -0,0 +1,212 @@
+package vm
+
+// Embedding: programs can use ARC as a library by creating a database and a runtime, and then executing commands with
+// Do (or ExecuteArgs) without building or parsing command lines:
+//
+//	var db = database.Create()
+//	var runtime = vm.CreateRuntime(vm.StandardLibrary, db)
+//	db.StartActiveExpiration()
+//	defer db.Close()
+//
+//	runtime.Do(ctx, "SET", "greeting", "hello world")
+//	var value, err = runtime.Do(ctx, "GET", "greeting") // value.Text() == "hello world"
+//
+// Error results are returned as *Error values (compared with errors.Is, e.g. ErrInvalidDataType) and missing keys as
+// ErrNil, while stored values are always returned as is (even "(nil)"). The same types are used by the client package,
+// so code can switch between embedded and remote databases.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Result types.
+const (
+	// ValuesResult results hold values (which may be stored data looking like a nil or an error result).
+	ValuesResult ResultType = iota
+
+	// NilResult results report a missing key or member.
+	NilResult
+
+	// ErrorResult results hold a single error message.
+	ErrorResult
+)
+
+// Names of the result types, in the JSON encoding of typed results.
+var resultTypeNames = []string{"values", "nil", "error"}
+
+type (
+	// ResultType tells if a result set holds values, or is a nil or an error result.
+	ResultType int
+
+	// TypedResult is a result set along with its type, so stored values are never mistaken for nil or error results
+	// (like a value set to "(nil)", or starting with "Error: ").
+	TypedResult struct {
+		Type   ResultType `json:"type"`
+		Values []string   `json:"values"`
+	}
+
+	// Result is the result of a command executed with Do, with typed accessors for single value results.
+	Result []string
+)
+
+// GetResultType returns the type of a result set from its values alone: a "(nil)" value is a nil result and a value
+// starting with "Error: " an error result. It is meant for results whose type is unknown, since stored values may look
+// like nil or error results (the typed results returned by the runtime never mistake them).
+func GetResultType(result []string) ResultType {
+	switch {
+	case GetError(result) != nil:
+		return ErrorResult
+	case (len(result) == 1) && (result[0] == NilMessage):
+		return NilResult
+	}
+
+	return ValuesResult
+}
+
+func (resultType ResultType) String() string {
+	if (resultType >= 0) && (int(resultType) < len(resultTypeNames)) {
+		return resultTypeNames[resultType]
+	}
+
+	return "unknown"
+}
+
+// MarshalText encodes the result type name.
+func (resultType ResultType) MarshalText() ([]byte, error) {
+	return []byte(resultType.String()), nil
+}
+
+// UnmarshalText decodes a result type name.
+func (resultType *ResultType) UnmarshalText(text []byte) error {
+	for index, name := range resultTypeNames {
+		if name == string(text) {
+			*resultType = ResultType(index)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown result type: %q", text)
+}
+
+// Get returns the values of the result, error results are returned as an *Error and nil results as ErrNil.
+func (result TypedResult) Get() (Result, error) {
+	switch result.Type {
+	case ErrorResult:
+		if err := GetError(result.Values); err != nil {
+			return nil, err
+		}
+
+		return nil, &Error{Kind: GenericErrorKind, Message: strings.Join(result.Values, " ")}
+	case NilResult:
+		return nil, ErrNil
+	}
+
+	return Result(result.Values), nil
+}
+
+// Text returns the single value of the result.
+func (result Result) Text() (string, error) {
+	if len(result) != 1 {
+		return "", fmt.Errorf("unexpected result: %q", []string(result))
+	}
+
+	return result[0], nil
+}
+
+// Int returns the single integer value of the result.
+func (result Result) Int() (int64, error) {
+	var value, err = result.Text()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var number, parseError = strconv.ParseInt(value, 10, 64)
+
+	if parseError != nil {
+		return 0, fmt.Errorf("unexpected result: %q", value)
+	}
+
+	return number, nil
+}
+
+// Float returns the single floating point value of the result.
+func (result Result) Float() (float64, error) {
+	var value, err = result.Text()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var number, parseError = strconv.ParseFloat(value, 64)
+
+	if parseError != nil {
+		return 0, fmt.Errorf("unexpected result: %q", value)
+	}
+
+	return number, nil
+}
+
+// OK checks that the result is the OK status.
+func (result Result) OK() error {
+	if value, err := result.Text(); err != nil {
+		return err
+	} else if value != okMessage {
+		return fmt.Errorf("unexpected result: %q", value)
+	}
+
+	return nil
+}
+
+// Strings returns the result values.
+func (result Result) Strings() []string {
+	return result
+}
+
+// ExecuteArgs executes an already tokenized command (the command name followed by its parameters) and returns the
+// result set (if any). The arguments are used as is, so they never need to be quoted or escaped.
+func (runtime *Runtime) ExecuteArgs(args []string) []string {
+	return runtime.ExecuteArgsWith(ExecutionContext{Client: localClient}, args)
+}
+
+// ExecuteArgsWith executes an already tokenized command in the specified execution context (whose command and
+// parameters are taken from the arguments) and returns the result set (if any).
+func (runtime *Runtime) ExecuteArgsWith(execution ExecutionContext, args []string) []string {
+	return runtime.ExecuteArgsTyped(execution, args).Values
+}
+
+// ExecuteArgsTyped executes an already tokenized command like ExecuteArgsWith, and returns the typed result.
+func (runtime *Runtime) ExecuteArgsTyped(execution ExecutionContext, args []string) TypedResult {
+	if len(args) == 0 {
+		var result = TypedResult{Type: ErrorResult, Values: invlaidCommandLineResult}
+
+		runtime.stats.processed.Increment()
+		runtime.stats.recordResult(result)
+		return result
+	}
+
+	// The parameters are copied since write commands keep them in the replication backlog.
+
+	execution.Command = args[0]
+	execution.Parameters = append([]string(nil), args[1:]...)
+
+	return runtime.run(&execution)
+}
+
+// Do executes a command with its arguments, error results are returned as an *Error and nil results as ErrNil. The
+// command is not executed if the context is already done.
+func (runtime *Runtime) Do(ctx context.Context, command string, args ...string) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return runtime.run(&ExecutionContext{
+		Context:    ctx,
+		Client:     localClient,
+		Command:    command,
+		Parameters: append([]string(nil), args...),
+	}).Get()
+}
@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"arc/database"
+)
+
+func TestDo(test *testing.T) {
+	var testDB = database.Create()
+	var testRuntime = CreateRuntime(StandardLibrary, testDB)
+	var ctx = context.Background()
+
+	defer testDB.Close()
+
+	if result, err := testRuntime.Do(ctx, "SET", "spaced key", `a "quoted" value`); (err != nil) || (result.OK() != nil) {
+		test.Fail()
+	}
+
+	if result, err := testRuntime.Do(ctx, "GET", "spaced key"); err != nil {
+		test.Fail()
+	} else if value, _ := result.Text(); value != `a "quoted" value` {
+		test.Fail()
+	}
+
+	if _, err := testRuntime.Do(ctx, "GET", "missing"); err != ErrNil {
+		test.Fail()
+	}
+
+	if result, err := testRuntime.Do(ctx, "incr", "counter"); err != nil {
+		test.Fail()
+	} else if value, _ := result.Int(); value != 1 {
+		test.Fail()
+	}
+
+	if _, err := testRuntime.Do(ctx, "INCR", "spaced key"); !errors.Is(err, ErrInvalidDataType) {
+		test.Fail()
+	}
+
+	if result := testRuntime.ExecuteArgs([]string{"DEL", "spaced key", "counter"}); (len(result) != 1) || (result[0] != "2") {
+		test.Fail()
+	}
+
+	if result := testRuntime.ExecuteArgs(nil); GetError(result) == nil {
+		test.Fail()
+	}
+
+	var cancelledContext, cancel = context.WithCancel(ctx)
+	cancel()
+
+	if _, err := testRuntime.Do(cancelledContext, "SET", "key", "value"); (err != context.Canceled) || testDB.Has("key") {
+		test.Fail()
+	}
+}
+
+func TestTypedResults(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var ctx = context.Background()
+
+	testRuntime.ExecuteArgs([]string{"SET", "nil", NilMessage})
+	testRuntime.ExecuteArgs([]string{"SET", "error", "Error: not an error"})
+	testRuntime.ExecuteArgs([]string{"ZADD", "members", "1", NilMessage})
+
+	for _, key := range []string{"nil", "error"} {
+		if result, err := testRuntime.Do(ctx, "GET", key); err != nil {
+			test.Errorf("GET %s failed: %v", key, err)
+		} else if value, _ := result.Text(); value != map[string]string{"nil": NilMessage, "error": "Error: not an error"}[key] {
+			test.Errorf("GET %s = %q", key, value)
+		}
+	}
+
+	var tests = []struct {
+		line     string
+		expected TypedResult
+	}{
+		{"GET nil", TypedResult{Type: ValuesResult, Values: []string{NilMessage}}},
+		{"GET error", TypedResult{Type: ValuesResult, Values: []string{"Error: not an error"}}},
+		{"GET missing", TypedResult{Type: NilResult, Values: []string{NilMessage}}},
+		{"ZRANGE members 0 -1", TypedResult{Type: ValuesResult, Values: []string{NilMessage}}},
+		{"KEYS n*", TypedResult{Type: ValuesResult, Values: []string{"nil"}}},
+		{"SET nil value NX", TypedResult{Type: NilResult, Values: []string{NilMessage}}},
+		{"INCR error", TypedResult{Type: ErrorResult, Values: []string{invalidDataTypeErrorMessage}}},
+		{"UNKNOWN", TypedResult{Type: ErrorResult, Values: []string{unknownCommandErrorMessage}}},
+	}
+
+	for _, testCase := range tests {
+		var result = testRuntime.ExecuteTyped(ExecutionContext{}, testCase.line)
+
+		if (result.Type != testCase.expected.Type) || (strings.Join(result.Values, " ") != strings.Join(testCase.expected.Values, " ")) {
+			test.Errorf("%s = %+v, expected %+v", testCase.line, result, testCase.expected)
+		}
+	}
+
+	var encoded, _ = json.Marshal(TypedResult{Type: NilResult, Values: []string{NilMessage}})
+	var decoded TypedResult
+
+	if string(encoded) != `{"type":"nil","values":["(nil)"]}` {
+		test.Errorf("encoded typed result = %s", encoded)
+	}
+
+	if (json.Unmarshal(encoded, &decoded) != nil) || (decoded.Type != NilResult) {
+		test.Errorf("decoded typed result = %+v", decoded)
+	}
+
+	if json.Unmarshal([]byte(`{"type":"other"}`), &decoded) == nil {
+		test.Error("unknown result types are decoded")
+	}
+}
@@ -0,0 +1,81 @@
+package vm
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Error is an error result returned by a command.
+type Error struct {
+	// Kind identifies the error (as reported by the metrics), unknown errors are of the GenericErrorKind.
+	Kind string
+
+	// Message is the error message returned by the command.
+	Message string
+}
+
+// GenericErrorKind is the kind of the error results without a specific kind.
+const GenericErrorKind = "error"
+
+// ErrNil is returned when a key or member does not exist.
+var ErrNil = errors.New("nil result")
+
+// Command errors, to be compared using errors.Is.
+var (
+	ErrUnknownCommand        = &Error{Kind: "unknown_command"}
+	ErrInvalidCommandLine    = &Error{Kind: "invalid_command_line"}
+	ErrInvalidParameters     = &Error{Kind: "invalid_parameters"}
+	ErrInvalidParameterValue = &Error{Kind: "invalid_parameter_value"}
+	ErrInvalidDataType       = &Error{Kind: "invalid_data_type"}
+	ErrStreamingOnly         = &Error{Kind: "streaming_only"}
+	ErrNoSuchKey             = &Error{Kind: "no_such_key"}
+	ErrOutOfMemory           = &Error{Kind: "out_of_memory"}
+	ErrReadOnlyReplica       = &Error{Kind: "read_only_replica"}
+	ErrCrossSlot             = &Error{Kind: "cross_slot"}
+	ErrClusterDown           = &Error{Kind: "cluster_down"}
+	ErrSyntax                = &Error{Kind: "syntax_error"}
+	ErrReadOnly              = &Error{Kind: "read_only"}
+	ErrTimeout               = &Error{Kind: "timeout"}
+	ErrCancelled             = &Error{Kind: "cancelled"}
+	ErrPreconditionFailed    = &Error{Kind: "precondition_failed"}
+	ErrArgumentLimit         = &Error{Kind: "argument_limit"}
+	ErrRateLimited           = &Error{Kind: "rate_limited"}
+)
+
+func (err *Error) Error() string {
+	if err.Message == "" {
+		return err.Kind
+	}
+
+	return err.Message
+}
+
+// Is reports if both errors are of the same kind.
+func (err *Error) Is(target error) bool {
+	var targetError, isError = target.(*Error)
+	return isError && (targetError.Kind == err.Kind)
+}
+
+// GetErrorKinds returns the kinds of the command errors (besides the GenericErrorKind), sorted by name.
+func GetErrorKinds() (kinds []string) {
+	for _, kind := range errorKinds {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+	return
+}
+
+// GetError returns the error of an error result (if any).
+func GetError(result []string) error {
+	if (len(result) != 1) || !strings.HasPrefix(result[0], ErrorPrefix) {
+		return nil
+	}
+
+	if kind, known := getErrorKind(result[0]); known {
+		return &Error{Kind: kind, Message: result[0]}
+	}
+
+	return &Error{Kind: GenericErrorKind, Message: result[0]}
+}
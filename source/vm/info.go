@@ -0,0 +1,222 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"arc/database"
+)
+
+type (
+	infoSection struct {
+		name    string
+		builder func(rtm *Runtime, snapshot *infoSnapshot) []string
+	}
+
+	// infoSnapshot holds the figures shared by the sections of a single INFO call, so they are computed once.
+	infoSnapshot struct {
+		dbStats *database.Statistics
+	}
+)
+
+// Sections reported by the INFO command (in output order).
+var infoSections = []infoSection{
+	{name: "server", builder: serverInfo},
+	{name: "clients", builder: clientsInfo},
+	{name: "memory", builder: memoryInfo},
+	{name: "persistence", builder: persistenceInfo},
+	{name: "stats", builder: statsInfo},
+	{name: "replication", builder: replicationInfo},
+	{name: "cluster", builder: clusterInfo},
+	{name: "runtime", builder: runtimeInfo},
+	{name: "keyspace", builder: keyspaceInfo},
+}
+
+// INFO [section]
+func stdInfo(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var section = strings.ToLower(parameters.Get("section"))
+
+	var lines = make([]string, 0)
+	var snapshot = &infoSnapshot{}
+
+	for index := range infoSections {
+		if (section != "") && (section != "all") && (section != infoSections[index].name) {
+			continue
+		}
+
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, "# "+strings.ToUpper(infoSections[index].name[:1])+infoSections[index].name[1:])
+		lines = append(lines, infoSections[index].builder(rtm, snapshot)...)
+	}
+
+	return []string{strings.Join(lines, "\n")}
+}
+
+// getDatabaseStatistics returns the database statistics, scanning the database only the first time.
+func (snapshot *infoSnapshot) getDatabaseStatistics(rtm *Runtime) database.Statistics {
+	if snapshot.dbStats == nil {
+		var dbStats = rtm.db.GetStatistics()
+		snapshot.dbStats = &dbStats
+	}
+
+	return *snapshot.dbStats
+}
+
+func serverInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	var uptime = time.Since(rtm.startTime)
+
+	return []string{
+		"arc_version:" + Version,
+		"arc_mode:" + rtm.serverInfo.GetMode(),
+		"go_version:" + runtime.Version(),
+		fmt.Sprintf("os:%s %s", runtime.GOOS, runtime.GOARCH),
+		fmt.Sprintf("process_id:%d", os.Getpid()),
+		fmt.Sprintf("uptime_in_seconds:%d", int64(uptime.Seconds())),
+		fmt.Sprintf("uptime_in_days:%d", int64(uptime.Hours()/24)),
+	}
+}
+
+func clientsInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	return []string{
+		fmt.Sprintf("connected_clients:%d", rtm.serverInfo.GetConnectedClients()),
+		fmt.Sprintf("watching_clients:%d", rtm.watchers.count.Load()),
+	}
+}
+
+func memoryInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	var memoryStats runtime.MemStats
+	runtime.ReadMemStats(&memoryStats)
+
+	var dbStats = snapshot.getDatabaseStatistics(rtm)
+
+	return []string{
+		fmt.Sprintf("used_memory:%d", memoryStats.HeapAlloc),
+		fmt.Sprintf("used_memory_human:%s", formatBytes(memoryStats.HeapAlloc)),
+		fmt.Sprintf("used_memory_dataset:%d", dbStats.Memory),
+		fmt.Sprintf("used_memory_dataset_human:%s", formatBytes(uint64(dbStats.Memory))),
+		fmt.Sprintf("maxmemory:%d", rtm.db.GetMaxMemory()),
+		fmt.Sprintf("maxmemory_human:%s", formatBytes(uint64(rtm.db.GetMaxMemory()))),
+		fmt.Sprintf("maxmemory_policy:%s", rtm.db.GetEvictionPolicy()),
+		fmt.Sprintf("used_memory_os:%d", memoryStats.Sys),
+		fmt.Sprintf("used_memory_os_human:%s", formatBytes(memoryStats.Sys)),
+		fmt.Sprintf("gc_cycles:%d", memoryStats.NumGC),
+	}
+}
+
+func persistenceInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	return []string{
+		"persistence_enabled:0",
+	}
+}
+
+func statsInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	var dbStats = snapshot.getDatabaseStatistics(rtm)
+
+	return []string{
+		fmt.Sprintf("total_connections_received:%d", rtm.serverInfo.GetTotalConnections()),
+		fmt.Sprintf("total_commands_processed:%d", rtm.stats.processed.Get()),
+		fmt.Sprintf("total_error_replies:%d", rtm.stats.getTotalErrors()),
+		fmt.Sprintf("expired_keys:%d", dbStats.ExpiredKeys),
+		fmt.Sprintf("evicted_keys:%d", dbStats.EvictedKeys),
+	}
+}
+
+func replicationInfo(rtm *Runtime, snapshot *infoSnapshot) (lines []string) {
+	var state = rtm.replication
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.leader != nil {
+		var status, lastIO = state.leader.getStatus()
+
+		lines = append(lines,
+			"role:replica",
+			"leader_host:"+state.leader.host,
+			"leader_port:"+state.leader.port,
+			"leader_link_status:"+status,
+			fmt.Sprintf("leader_last_io_seconds_ago:%d", int64(time.Since(lastIO).Seconds())),
+			fmt.Sprintf("replica_read_only:%d", boolToInt(state.readOnly)),
+		)
+	} else {
+		lines = append(lines, "role:leader")
+	}
+
+	lines = append(lines, fmt.Sprintf("connected_replicas:%d", len(state.replicas)))
+
+	var index = 0
+
+	for feed := range state.replicas {
+		lines = append(lines, fmt.Sprintf("replica%d:id=%s,address=%s,offset=%d,lag=%d,connected=%d",
+			index, feed.id, feed.address, feed.ackOffset, int64(time.Since(feed.ackTime).Seconds()), int64(time.Since(feed.connection).Seconds())))
+		index++
+	}
+
+	var keptEntries = min(len(state.backlog), state.backlogSize)
+	var firstOffset int64
+
+	if keptEntries > 0 {
+		firstOffset = state.backlog[len(state.backlog)-keptEntries].offset
+	}
+
+	lines = append(lines,
+		"replication_id:"+state.id,
+		fmt.Sprintf("replication_offset:%d", state.offset),
+		fmt.Sprintf("replication_backlog_size:%d", state.backlogSize),
+		fmt.Sprintf("replication_backlog_first_offset:%d", firstOffset),
+		fmt.Sprintf("replication_backlog_length:%d", keptEntries),
+	)
+
+	return
+}
+
+func clusterInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	return []string{
+		fmt.Sprintf("cluster_enabled:%d", boolToInt(rtm.clusterEnabled.Load())),
+		fmt.Sprintf("cluster_slots_assigned:%d", rtm.cluster.CountSlots()),
+		fmt.Sprintf("cluster_known_nodes:%d", len(rtm.cluster.GetNodes())),
+	}
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+func runtimeInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	return []string{
+		fmt.Sprintf("library_functions:%d", len(rtm.library)),
+		fmt.Sprintf("library_commands:%d", len(rtm.stats.commandNames)),
+		fmt.Sprintf("library_cache_entries:%d", len(rtm.libraryCache)),
+	}
+}
+
+func keyspaceInfo(rtm *Runtime, snapshot *infoSnapshot) []string {
+	var dbStats = snapshot.getDatabaseStatistics(rtm)
+
+	return []string{
+		fmt.Sprintf("db0:keys=%d,expires=%d,strings=%d,zsets=%d", dbStats.SingleValues+dbStats.SortedSets, dbStats.Expires, dbStats.SingleValues, dbStats.SortedSets),
+	}
+}
+
+func formatBytes(size uint64) string {
+	var units = []string{"B", "K", "M", "G", "T"}
+	var value = float64(size)
+	var unit = 0
+
+	for (value >= 1024) && (unit < len(units)-1) {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.2f%s", value, units[unit])
+}
@@ -0,0 +1,51 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInfo(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	testRuntime.Execute("SET a 1")
+	testRuntime.Execute("SET b 2 EX 100")
+	testRuntime.Execute("ZADD z 1 one")
+	testRuntime.Execute("INCR z")
+
+	var tests = []struct {
+		section  string
+		expected []string
+	}{
+		{"keyspace", []string{"# Keyspace", "db0:keys=3,expires=1,strings=2,zsets=1"}},
+		{"KEYSPACE", []string{"# Keyspace", "db0:keys=3,expires=1,strings=2,zsets=1"}},
+		{"server", []string{"# Server", "arc_version:" + Version, "arc_mode:standalone"}},
+		{"stats", []string{"# Stats", "total_commands_processed:", "total_error_replies:1", "expired_keys:0"}},
+		{"memory", []string{"# Memory", "used_memory_os:", "maxmemory:0", "maxmemory_policy:noeviction"}},
+		{"replication", []string{"# Replication", "role:leader", "connected_replicas:0"}},
+		{"", []string{"# Server", "# Clients", "# Memory", "# Stats", "# Cluster", "# Keyspace", "connected_clients:1"}},
+	}
+
+	for _, testCase := range tests {
+		var result = testRuntime.Execute(strings.TrimSpace("INFO " + testCase.section))
+
+		if len(result) != 1 {
+			test.Errorf("INFO %s = %q", testCase.section, result)
+			continue
+		}
+
+		for _, line := range testCase.expected {
+			if !strings.Contains(result[0]+"\n", line+"\n") && !strings.Contains(result[0], "\n"+line) {
+				test.Errorf("INFO %s is missing %q", testCase.section, line)
+			}
+		}
+	}
+
+	if result := testRuntime.Execute("INFO keyspace"); strings.Contains(result[0], "# Server") {
+		test.Errorf("INFO keyspace returned other sections: %q", result[0])
+	}
+
+	if result := testRuntime.Execute("INFO unknown"); (len(result) != 1) || (result[0] != "") {
+		test.Errorf("INFO unknown = %q", result)
+	}
+}
@@ -0,0 +1,135 @@
+package vm
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+
+	"arc/database"
+)
+
+// KEYS pattern
+func stdKeys(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var keys, err = rtm.db.GetKeysContext(execution.Context, parameters.Get("pattern"))
+
+	if err != nil {
+		return getCancellationResult(err)
+	}
+
+	return execution.dataResult(keys)
+}
+
+// Number of keys examined by each SCAN call by default.
+const defaultScanCount = 10
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+func stdScan(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var cursor, pattern, count = parameters.GetInt("cursor"), "*", int64(defaultScanCount)
+
+	if parameters.Has("pattern") {
+		pattern = parameters.Get("pattern")
+	}
+
+	if parameters.Has("count") {
+		count = parameters.GetInt("count")
+	}
+
+	if (cursor < 0) || (count < 1) {
+		return invalidParameterValueResult
+	}
+
+	var next, keys = rtm.db.Scan(uint64(cursor), pattern, int(count))
+
+	return execution.dataResult(append([]string{strconv.FormatUint(next, 10)}, keys...))
+}
+
+// TYPE key
+func stdType(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if information, exists := rtm.db.GetKeyInformation(parameters.Get("key")); exists {
+		return []string{database.GetTypeName(information.Type)}
+	}
+
+	return []string{"none"}
+}
+
+// PTTL key
+func stdPttl(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var information, exists = rtm.db.GetKeyInformation(parameters.Get("key"))
+
+	switch {
+	case !exists:
+		return []string{"-2"}
+	case information.Expires == 0:
+		return []string{"-1"}
+	}
+
+	return []string{strconv.FormatInt(max(0, information.Expires-time.Now().UnixMilli()), 10)}
+}
+
+// MEMORY USAGE key | MEMORY STATS
+func stdMemory(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	switch parameters.Get("subcommand") {
+	case "USAGE":
+		if information, exists := rtm.db.GetKeyInformation(parameters.Get("key")); exists {
+			return []string{strconv.FormatInt(information.Memory, 10)}
+		}
+
+		return nilResult
+	case "STATS":
+		var memoryStats runtime.MemStats
+		runtime.ReadMemStats(&memoryStats)
+
+		var dbStats = rtm.db.GetStatistics()
+		var keys = dbStats.SingleValues + dbStats.SortedSets
+		var bytesPerKey int64
+
+		if keys > 0 {
+			bytesPerKey = dbStats.Memory / keys
+		}
+
+		return []string{
+			"total.allocated", strconv.FormatUint(memoryStats.HeapAlloc, 10),
+			"heap.inuse", strconv.FormatUint(memoryStats.HeapInuse, 10),
+			"system", strconv.FormatUint(memoryStats.Sys, 10),
+			"dataset.bytes", strconv.FormatInt(dbStats.Memory, 10),
+			"keys.count", strconv.FormatInt(keys, 10),
+			"keys.bytes-per-key", strconv.FormatInt(bytesPerKey, 10),
+			"maxmemory", strconv.FormatInt(rtm.db.GetMaxMemory(), 10),
+		}
+	}
+
+	return invalidParametersResult
+}
+
+// OBJECT ENCODING key | OBJECT IDLETIME key | OBJECT FREQ key
+func stdObject(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var information, exists = rtm.db.GetKeyInformation(parameters.Get("key"))
+
+	switch parameters.Get("subcommand") {
+	case "ENCODING":
+		if exists {
+			return []string{information.Encoding}
+		}
+	case "IDLETIME":
+		if exists {
+			return []string{strconv.FormatInt(int64(information.IdleTime.Seconds()), 10)}
+		}
+	case "FREQ":
+		if exists {
+			return []string{strconv.FormatUint(uint64(information.Frequency), 10)}
+		}
+	}
+
+	return nilResult
+}
+
+// CLIENT INFO
+func stdClient(rtm *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var user = execution.User
+
+	if user == "" {
+		user = "default"
+	}
+
+	return []string{"client=" + execution.Client, "user=" + user, "db=" + strconv.Itoa(execution.Database)}
+}
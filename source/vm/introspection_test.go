@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMemoryAndObject(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	testRuntime.Execute("SET number 12345")
+	testRuntime.Execute("SET text " + strings.Repeat("x", 1000))
+	testRuntime.Execute("ZADD set 1 one 2 two")
+
+	var tests = []struct {
+		line     string
+		expected string
+	}{
+		{"OBJECT ENCODING number", "int"},
+		{"OBJECT ENCODING text", "raw"},
+		{"OBJECT ENCODING set", "sortedset"},
+		{"OBJECT ENCODING missing", NilMessage},
+		{"OBJECT IDLETIME number", "0"},
+		{"OBJECT FREQ missing", NilMessage},
+		{"MEMORY USAGE missing", NilMessage},
+		{"MEMORY", invalidParametersErrorMessage},
+	}
+
+	for _, testCase := range tests {
+		if result := strings.Join(testRuntime.Execute(testCase.line), " "); !strings.HasPrefix(result, testCase.expected) {
+			test.Errorf("%s = %q, expected %q", testCase.line, result, testCase.expected)
+		}
+	}
+
+	var usage = func(key string) int64 {
+		var size, _ = strconv.ParseInt(testRuntime.Execute("MEMORY USAGE " + key)[0], 10, 64)
+		return size
+	}
+
+	if (usage("text") < 1000) || (usage("number") >= usage("text")) || (usage("set") <= 0) {
+		test.Errorf("MEMORY USAGE number %d, text %d, set %d", usage("number"), usage("text"), usage("set"))
+	}
+
+	var stats = testRuntime.Execute("MEMORY STATS")
+	var values = make(map[string]string)
+
+	for index := 0; index+1 < len(stats); index += 2 {
+		values[stats[index]] = stats[index+1]
+	}
+
+	if (values["keys.count"] != "3") || (values["dataset.bytes"] != strconv.FormatInt(usage("number")+usage("text")+usage("set"), 10)) {
+		test.Errorf("MEMORY STATS = %q", stats)
+	}
+
+	if frequency := testRuntime.Execute("OBJECT FREQ number")[0]; frequency == "0" {
+		test.Errorf("OBJECT FREQ of an accessed key = %s", frequency)
+	}
+}
+
+func TestScan(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var expected = make(map[string]bool)
+
+	for index := 0; index < 100; index++ {
+		var key = "key:" + strconv.Itoa(index)
+		testRuntime.Execute("SET " + key + " value")
+		expected[key] = true
+	}
+
+	testRuntime.Execute("SET other value")
+
+	var found = make(map[string]bool)
+	var cursor = "0"
+	var calls = 0
+
+	for {
+		var result = testRuntime.Execute("SCAN " + cursor + " MATCH key:* COUNT 7")
+
+		if len(result) == 0 {
+			test.Fatal("SCAN returned no cursor")
+		}
+
+		for _, key := range result[1:] {
+			found[key] = true
+		}
+
+		// Keys removed during the scan must not make it skip other keys.
+
+		if calls++; calls == 3 {
+			for index := 0; index < 10; index++ {
+				testRuntime.Execute("DEL key:" + strconv.Itoa(index))
+				delete(expected, "key:"+strconv.Itoa(index))
+			}
+		}
+
+		if cursor = result[0]; cursor == "0" {
+			break
+		}
+	}
+
+	for key := range expected {
+		if !found[key] {
+			test.Errorf("SCAN missed %s", key)
+		}
+	}
+
+	if found["other"] || (calls > 101/7+1) {
+		test.Errorf("SCAN found other %v in %d calls", found["other"], calls)
+	}
+
+	if result := testRuntime.Execute("SCAN 0 COUNT 0"); result[0] != invalidParameterValueErrorMessage {
+		test.Errorf("SCAN with COUNT 0 = %q", result)
+	}
+}
@@ -0,0 +1,203 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is returned when a command line can't be tokenized.
+type ParseError struct {
+	// Column is the (1 based) position of the character where the error was found.
+	Column  int
+	Message string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%s at column %d", err.Message, err.Column)
+}
+
+func isSpace(char byte) bool {
+	return (char == ' ') || (char == '\t') || (char == '\r') || (char == '\n')
+}
+
+func getHexValue(char byte) (value byte, ok bool) {
+	switch {
+	case (char >= '0') && (char <= '9'):
+		return char - '0', true
+	case (char >= 'a') && (char <= 'f'):
+		return char - 'a' + 10, true
+	case (char >= 'A') && (char <= 'F'):
+		return char - 'A' + 10, true
+	}
+
+	return 0, false
+}
+
+// Tokenize splits a command line into its arguments, separated by spaces (or tabs and new lines):
+//
+//   - Unquoted arguments end at the next space and may contain any other character (quotes and backslashes are kept
+//     as is).
+//   - Double quoted arguments support the \" \\ \n \r \t \0 and \xHH (any byte) escape sequences.
+//   - Single quoted arguments are taken literally, except for the \' escape sequence.
+//
+// Quoted arguments may be empty and must be followed by a space or the end of the line.
+func Tokenize(line string) (arguments []string, err error) {
+	var position = 0
+
+	var fail = func(offset int, message string) ([]string, error) {
+		return nil, &ParseError{Column: utf8.RuneCountInString(line[:offset]) + 1, Message: message}
+	}
+
+	arguments = make([]string, 0)
+
+	for {
+		for (position < len(line)) && isSpace(line[position]) {
+			position++
+		}
+
+		if position == len(line) {
+			return arguments, nil
+		}
+
+		var argument strings.Builder
+		var quote = line[position]
+
+		if (quote != '"') && (quote != '\'') {
+			for (position < len(line)) && !isSpace(line[position]) {
+				argument.WriteByte(line[position])
+				position++
+			}
+
+			arguments = append(arguments, argument.String())
+			continue
+		}
+
+		var start = position
+		var closed = false
+
+		for position++; !closed && (position < len(line)); position++ {
+			var char = line[position]
+
+			switch {
+			case char == quote:
+				closed = true
+			case (char == '\\') && (quote == '\''):
+				if (position+1 < len(line)) && (line[position+1] == '\'') {
+					position++
+					char = '\''
+				}
+
+				argument.WriteByte(char)
+			case char == '\\':
+				if position+1 == len(line) {
+					return fail(start, "unterminated quoted argument")
+				}
+
+				position++
+
+				switch line[position] {
+				case '"', '\\', '\'':
+					argument.WriteByte(line[position])
+				case 'n':
+					argument.WriteByte('\n')
+				case 'r':
+					argument.WriteByte('\r')
+				case 't':
+					argument.WriteByte('\t')
+				case '0':
+					argument.WriteByte(0)
+				case 'x':
+					if position+2 >= len(line) {
+						return fail(position-1, "invalid hexadecimal escape sequence")
+					}
+
+					var high, highOK = getHexValue(line[position+1])
+					var low, lowOK = getHexValue(line[position+2])
+
+					if !highOK || !lowOK {
+						return fail(position-1, "invalid hexadecimal escape sequence")
+					}
+
+					argument.WriteByte((high << 4) | low)
+					position += 2
+				default:
+					return fail(position-1, "invalid escape sequence")
+				}
+			default:
+				argument.WriteByte(char)
+			}
+		}
+
+		if !closed {
+			return fail(start, "unterminated quoted argument")
+		}
+
+		if (position < len(line)) && !isSpace(line[position]) {
+			return fail(position, "closing quote must be followed by a space")
+		}
+
+		arguments = append(arguments, argument.String())
+	}
+}
+
+// needsQuotes reports if an argument must be quoted to be tokenized back to the same value.
+func needsQuotes(argument string) bool {
+	if argument == "" {
+		return true
+	}
+
+	for index := 0; index < len(argument); index++ {
+		if char := argument[index]; isSpace(char) || (char == '"') || (char == '\'') || (char == '\\') ||
+			(char < ' ') || (char >= 0x7f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FormatCommandLine formats a list of arguments as a command line, quoting and escaping the arguments when needed, so
+// the command line is tokenized back to the same arguments.
+func FormatCommandLine(arguments []string) string {
+	var line strings.Builder
+
+	for index, argument := range arguments {
+		if index > 0 {
+			line.WriteByte(' ')
+		}
+
+		if !needsQuotes(argument) {
+			line.WriteString(argument)
+			continue
+		}
+
+		line.WriteByte('"')
+
+		for position := 0; position < len(argument); {
+			var char, size = utf8.DecodeRuneInString(argument[position:])
+
+			switch {
+			case char == '"', char == '\\':
+				line.WriteByte('\\')
+				line.WriteByte(byte(char))
+			case char == '\n':
+				line.WriteString(`\n`)
+			case char == '\r':
+				line.WriteString(`\r`)
+			case char == '\t':
+				line.WriteString(`\t`)
+			case (char == utf8.RuneError) && (size == 1), char < ' ', char == 0x7f:
+				fmt.Fprintf(&line, `\x%02x`, argument[position])
+			default:
+				line.WriteString(argument[position : position+size])
+			}
+
+			position += size
+		}
+
+		line.WriteByte('"')
+	}
+
+	return line.String()
+}
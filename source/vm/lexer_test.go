@@ -0,0 +1,111 @@
+package vm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(test *testing.T) {
+	var tests = []struct {
+		line      string
+		arguments []string
+		column    int
+	}{
+		{line: "", arguments: []string{}},
+		{line: "   \t ", arguments: []string{}},
+		{line: "GET key", arguments: []string{"GET", "key"}},
+		{line: "  SET   key\tvalue  ", arguments: []string{"SET", "key", "value"}},
+		{line: `SET key "hello world"`, arguments: []string{"SET", "key", "hello world"}},
+		{line: `SET key 'hello world'`, arguments: []string{"SET", "key", "hello world"}},
+		{line: `"SET" key value`, arguments: []string{"SET", "key", "value"}},
+		{line: `SET "" value`, arguments: []string{"SET", "", "value"}},
+		{line: `SET key ''`, arguments: []string{"SET", "key", ""}},
+		{line: `SET key "a\"b\\c"`, arguments: []string{"SET", "key", `a"b\c`}},
+		{line: `SET key "line\nnext\ttab\r"`, arguments: []string{"SET", "key", "line\nnext\ttab\r"}},
+		{line: `SET key "\x00\xfF\x41"`, arguments: []string{"SET", "key", "\x00\xffA"}},
+		{line: `SET key "\0"`, arguments: []string{"SET", "key", "\x00"}},
+		{line: `SET key 'it\'s \n'`, arguments: []string{"SET", "key", `it's \n`}},
+		{line: `SET key C:\path`, arguments: []string{"SET", "key", `C:\path`}},
+		{line: `SET key {"a":1}`, arguments: []string{"SET", "key", `{"a":1}`}},
+		{line: "SET key \"multi\nline\"", arguments: []string{"SET", "key", "multi\nline"}},
+		{line: "SET clé \"été\"", arguments: []string{"SET", "clé", "été"}},
+		{line: `SET key "unterminated`, column: 9},
+		{line: `SET key 'unterminated`, column: 9},
+		{line: `SET key "ends with\`, column: 9},
+		{line: `SET key "bad\q"`, column: 13},
+		{line: `SET key "bad\x4"`, column: 13},
+		{line: `SET key "bad\xZZ"`, column: 13},
+		{line: `SET key "quoted"tail`, column: 17},
+		{line: `SET clé "é"x`, column: 12},
+	}
+
+	for _, testCase := range tests {
+		var arguments, err = Tokenize(testCase.line)
+
+		if testCase.column == 0 {
+			if (err != nil) || !reflect.DeepEqual(arguments, testCase.arguments) {
+				test.Errorf("Tokenize(%q) = %q, %v; want %q", testCase.line, arguments, err, testCase.arguments)
+			}
+
+			continue
+		}
+
+		var parseError *ParseError
+
+		if !errors.As(err, &parseError) || (parseError.Column != testCase.column) {
+			test.Errorf("Tokenize(%q) = %q, %v; want error at column %d", testCase.line, arguments, err, testCase.column)
+		}
+	}
+}
+
+func TestFormatCommandLine(test *testing.T) {
+	var tests = [][]string{
+		{"GET", "key"},
+		{"SET", "", "hello world"},
+		{"SET", "key", "quote\" backslash\\ single' newline\n tab\t"},
+		{"SET", "key", "\x00\xff\x7f binary"},
+		{"SET", "clé", "été"},
+	}
+
+	for _, arguments := range tests {
+		var line = FormatCommandLine(arguments)
+
+		if tokens, err := Tokenize(line); (err != nil) || !reflect.DeepEqual(tokens, arguments) {
+			test.Errorf("Tokenize(FormatCommandLine(%q)) = %q, %v", arguments, tokens, err)
+		}
+	}
+
+	if line := FormatCommandLine([]string{"GET", "key"}); line != "GET key" {
+		test.Errorf("FormatCommandLine quoted simple arguments: %s", line)
+	}
+}
+
+func FuzzTokenize(fuzz *testing.F) {
+	fuzz.Add(`SET key "hello \"world\"\n\x41"`)
+	fuzz.Add(`SET 'single \' quote' ""`)
+	fuzz.Add("GET\tkey\r\n")
+	fuzz.Add(`SET key "\xZZ`)
+
+	fuzz.Fuzz(func(test *testing.T, line string) {
+		var arguments, err = Tokenize(line)
+
+		if err != nil {
+			var parseError *ParseError
+
+			if !errors.As(err, &parseError) || (parseError.Column < 1) || (parseError.Column > len(line)+1) {
+				test.Fatalf("Tokenize(%q) returned an invalid error: %v", line, err)
+			}
+
+			return
+		}
+
+		// Formatting the arguments must always give a command line that is tokenized back to the same arguments.
+
+		var formatted = FormatCommandLine(arguments)
+
+		if tokens, err := Tokenize(formatted); (err != nil) || !reflect.DeepEqual(tokens, arguments) {
+			test.Fatalf("Tokenize(%q) = %q, %v; want %q", formatted, tokens, err, arguments)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+type (
+	// Handler executes a command in its execution context and returns the typed result, so stored values returned by
+	// the command are never mistaken for nil or error results by the middlewares.
+	Handler func(execution *ExecutionContext) TypedResult
+
+	// Middleware wraps a handler to run code before and after the command, or to answer instead of it (e.g. to deny
+	// it), by calling (or not calling) the next handler.
+	Middleware func(next Handler) Handler
+)
+
+// Use adds middlewares to the chain around the command execution, the first middleware added is the outermost one.
+// Commands received from the replication leader bypass the middlewares.
+func (runtime *Runtime) Use(middlewares ...Middleware) {
+	runtime.middlewareLock.Lock()
+	defer runtime.middlewareLock.Unlock()
+
+	runtime.middlewares = append(runtime.middlewares, middlewares...)
+
+	var handler = Handler(runtime.execute)
+
+	for index := len(runtime.middlewares) - 1; index >= 0; index-- {
+		handler = runtime.middlewares[index](handler)
+	}
+
+	runtime.handler.Store(&handler)
+}
+
+// GetCommandFlags returns the flags of a command (like write, readonly or admin), as reported by COMMAND INFO.
+func (runtime *Runtime) GetCommandFlags(command string) (flags []string, exists bool) {
+	if description := runtime.getCommandDescription(command); description != nil {
+		return description.getFlagNames(), true
+	}
+
+	return nil, false
+}
+
+// RenameCommands makes commands available under other names, renames map the command names to their new names, and
+// an empty new name disables the command. Renamed commands are no longer available under their original names.
+func RenameCommands(renames map[string]string) Middleware {
+	var originals = make(map[string]string, len(renames))
+	var renamed = make(map[string]bool, len(renames))
+
+	for command, newName := range renames {
+		renamed[strings.ToUpper(command)] = true
+
+		if newName != "" {
+			originals[strings.ToUpper(newName)] = strings.ToUpper(command)
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(execution *ExecutionContext) TypedResult {
+			if original, exists := originals[execution.Command]; exists {
+				execution.Command = original
+			} else if renamed[execution.Command] {
+				return TypedResult{Type: ErrorResult, Values: unknownCommandResult}
+			}
+
+			return next(execution)
+		}
+	}
+}
+
+// ReadOnly refuses the commands that change the database.
+func ReadOnly(runtime *Runtime) Middleware {
+	return func(next Handler) Handler {
+		return func(execution *ExecutionContext) TypedResult {
+			if function, exists := runtime.libraryCache[execution.Command]; exists && (function.flags&writeFlag != 0) {
+				return TypedResult{Type: ErrorResult, Values: readOnlyResult}
+			}
+
+			return next(execution)
+		}
+	}
+}
+
+// LogCommands logs every command with its client, user, duration and result kind.
+func LogCommands(logger *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(execution *ExecutionContext) TypedResult {
+			var startTime = time.Now()
+			var result = next(execution)
+			var kind = "ok"
+
+			if _, err := result.Get(); result.Type == ErrorResult {
+				kind = err.(*Error).Kind
+			}
+
+			logger.Printf("CMD: %s (user %q) %s %d parameters in %s: %s", execution.Client, execution.User,
+				execution.Command, len(execution.Parameters), time.Since(startTime), kind)
+
+			return result
+		}
+	}
+}
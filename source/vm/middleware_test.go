@@ -0,0 +1,107 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareChain(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var calls []string
+
+	var trace = func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(execution *ExecutionContext) TypedResult {
+				calls = append(calls, name+" "+execution.Command)
+				return next(execution)
+			}
+		}
+	}
+
+	testRuntime.Use(trace("first"), trace("second"))
+	testRuntime.Use(RenameCommands(map[string]string{"DEL": "REMOVE", "KEYS": ""}))
+
+	if result := testRuntime.Execute("set key value"); (len(result) != 1) || (result[0] != okMessage) {
+		test.Errorf("SET = %q", result)
+	}
+
+	if strings.Join(calls, ",") != "first SET,second SET" {
+		test.Errorf("calls = %q", calls)
+	}
+
+	if result := testRuntime.Execute("DEL key"); (len(result) != 1) || (result[0] != unknownCommandErrorMessage) {
+		test.Errorf("DEL = %q", result)
+	}
+
+	if result := testRuntime.Execute("KEYS *"); (len(result) != 1) || (result[0] != unknownCommandErrorMessage) {
+		test.Errorf("KEYS = %q", result)
+	}
+
+	if result := testRuntime.Execute("remove key"); (len(result) != 1) || (result[0] != "1") {
+		test.Errorf("REMOVE = %q", result)
+	}
+
+	testRuntime.Use(ReadOnly(testRuntime))
+
+	if result := testRuntime.Execute("SET key value"); (len(result) != 1) || (result[0] != readOnlyErrorMessage) {
+		test.Errorf("read only SET = %q", result)
+	}
+
+	if result := testRuntime.Execute("GET key"); (len(result) != 1) || (result[0] != NilMessage) {
+		test.Errorf("read only GET = %q", result)
+	}
+}
+
+func TestMiddlewareResultTypes(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	// Middlewares copying or replacing the values of a result keep its type.
+
+	testRuntime.Use(func(next Handler) Handler {
+		return func(execution *ExecutionContext) TypedResult {
+			var result = next(execution)
+			result.Values = append([]string(nil), result.Values...)
+			return result
+		}
+	})
+
+	testRuntime.Use(RenameCommands(map[string]string{"GET": "READ"}))
+
+	testRuntime.Execute("SET nil (nil)")
+	testRuntime.Execute("SET error \"Error: not an error\"")
+
+	var tests = []struct {
+		line     string
+		expected ResultType
+	}{
+		{"READ nil", ValuesResult},
+		{"READ error", ValuesResult},
+		{"READ missing", NilResult},
+		{"GET nil", ErrorResult},
+		{"INCR error", ErrorResult},
+	}
+
+	for _, testCase := range tests {
+		if result := testRuntime.ExecuteTyped(ExecutionContext{}, testCase.line); result.Type != testCase.expected {
+			test.Errorf("%s = %v %q, expected a %v result", testCase.line, result.Type, result.Values, testCase.expected)
+		}
+	}
+}
+
+func TestExecutionContext(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	var result = testRuntime.ExecuteWith(ExecutionContext{Client: "10.0.0.1:5000", User: "alice"}, "CLIENT INFO")
+
+	if strings.Join(result, " ") != "client=10.0.0.1:5000 user=alice db=0" {
+		test.Errorf("CLIENT INFO = %q", result)
+	}
+
+	if result = testRuntime.Execute("CLIENT INFO"); strings.Join(result, " ") != "client=local user=default db=0" {
+		test.Errorf("local CLIENT INFO = %q", result)
+	}
+
+	if flags, exists := testRuntime.GetCommandFlags("set"); !exists || (strings.Join(flags, ",") != "write,denyoom") {
+		test.Errorf("SET flags = %q", flags)
+	}
+}
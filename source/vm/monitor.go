@@ -0,0 +1,116 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of events a monitor can hold before new events are dropped.
+const monitorBufferSize = 1024
+
+type (
+	// MonitorEvent describes a single command processed by the runtime.
+	MonitorEvent struct {
+		Timestamp time.Time
+		Client    string
+		Database  int
+		Arguments []string
+	}
+
+	// Monitor receives the events for every command processed by the runtime.
+	Monitor struct {
+		events  chan MonitorEvent
+		dropped atomic.Uint64
+		runtime *Runtime
+	}
+
+	monitorHub struct {
+		mutex    sync.RWMutex
+		monitors map[*Monitor]struct{}
+		count    atomic.Int32
+	}
+)
+
+func createMonitorHub() *monitorHub {
+	return &monitorHub{
+		monitors: make(map[*Monitor]struct{}),
+	}
+}
+
+// broadcast sends an event to every monitor, slow monitors never block the runtime (events are dropped instead).
+func (hub *monitorHub) broadcast(client string, identifier string, parameters []string) {
+	if hub.count.Load() == 0 {
+		return
+	}
+
+	var event = MonitorEvent{
+		Timestamp: time.Now(),
+		Client:    client,
+		Arguments: append([]string{identifier}, parameters...),
+	}
+
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+
+	for monitor := range hub.monitors {
+		select {
+		case monitor.events <- event:
+		default:
+			monitor.dropped.Add(1)
+		}
+	}
+}
+
+// Monitor creates a new monitor that receives every command processed by the runtime until it is closed.
+func (runtime *Runtime) Monitor() (monitor *Monitor) {
+	monitor = &Monitor{
+		events:  make(chan MonitorEvent, monitorBufferSize),
+		runtime: runtime,
+	}
+
+	runtime.monitors.mutex.Lock()
+	defer runtime.monitors.mutex.Unlock()
+
+	runtime.monitors.monitors[monitor] = struct{}{}
+	runtime.monitors.count.Add(1)
+	return
+}
+
+// Events returns the monitor event channel.
+func (monitor *Monitor) Events() <-chan MonitorEvent {
+	return monitor.events
+}
+
+// GetDropped returns the number of events dropped because the monitor was not keeping up.
+func (monitor *Monitor) GetDropped() uint64 {
+	return monitor.dropped.Load()
+}
+
+// Close stops receiving events and closes the event channel.
+func (monitor *Monitor) Close() {
+	var hub = monitor.runtime.monitors
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if _, exists := hub.monitors[monitor]; exists {
+		delete(hub.monitors, monitor)
+		hub.count.Add(-1)
+		close(monitor.events)
+	}
+}
+
+// String formats the event as a single line.
+func (event MonitorEvent) String() string {
+	var arguments = make([]string, len(event.Arguments))
+
+	for index := range event.Arguments {
+		arguments[index] = strconv.Quote(event.Arguments[index])
+	}
+
+	return fmt.Sprintf("%d.%06d [%d %s] %s", event.Timestamp.Unix(), event.Timestamp.Nanosecond()/1000, event.Database, event.Client, strings.Join(arguments, " "))
+}
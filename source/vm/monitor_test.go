@@ -0,0 +1,63 @@
+package vm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitor(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var monitor = testRuntime.Monitor()
+
+	testRuntime.ExecuteFrom("10.0.0.1:1234", `SET key "hello world"`)
+
+	var event = <-monitor.Events()
+
+	if (event.Client != "10.0.0.1:1234") || (strings.Join(event.Arguments, "|") != "SET|key|hello world") {
+		test.Errorf("event = %+v", event)
+	}
+
+	if line := event.String(); !strings.HasSuffix(line, ` [0 10.0.0.1:1234] "SET" "key" "hello world"`) {
+		test.Errorf("event line = %q", line)
+	}
+
+	monitor.Close()
+
+	if _, open := <-monitor.Events(); open {
+		test.Error("closed monitor received an event")
+	}
+}
+
+func TestMonitorDropsEvents(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var slow = testRuntime.Monitor()
+	var done = make(chan struct{})
+
+	defer slow.Close()
+
+	// A monitor that never reads its events must not block the commands.
+
+	go func() {
+		defer close(done)
+
+		for index := 0; index < monitorBufferSize+10; index++ {
+			testRuntime.Execute("SET key " + strconv.Itoa(index))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		test.Fatal("commands blocked by a slow monitor")
+	}
+
+	if dropped := slow.GetDropped(); dropped != 10 {
+		test.Errorf("dropped = %d, expected 10", dropped)
+	}
+
+	if (len(slow.Events()) != monitorBufferSize) || ((<-slow.Events()).Arguments[2] != "0") {
+		test.Errorf("monitor kept %d events, expected the first %d", len(slow.Events()), monitorBufferSize)
+	}
+}
@@ -0,0 +1,84 @@
+package vm
+
+import (
+	"strings"
+
+	"arc/database"
+)
+
+// Keyspace notification flags (the event classes are defined by the database package).
+const (
+	notifyKeyspace = 1 << (iota + 16)
+	notifyKeyevent
+)
+
+// Characters used to configure the keyspace notifications (notify-keyspace-events).
+var notifyFlagCharacters = []struct {
+	character rune
+	flag      int32
+}{
+	{'K', notifyKeyspace},
+	{'E', notifyKeyevent},
+	{'g', database.GenericEvents},
+	{'$', database.StringEvents},
+	{'z', database.SortedSetEvents},
+	{'x', database.ExpiredEvents},
+	{'e', database.EvictedEvents},
+}
+
+// Character used as an alias for all the event classes.
+const notifyAllClassesCharacter = 'A'
+
+func parseNotifyFlags(value string) (flags int32, ok bool) {
+	for _, character := range value {
+		if character == notifyAllClassesCharacter {
+			flags |= database.AllEvents
+			continue
+		}
+
+		var found = false
+
+		for index := range notifyFlagCharacters {
+			if notifyFlagCharacters[index].character == character {
+				flags |= notifyFlagCharacters[index].flag
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, false
+		}
+	}
+
+	return flags, true
+}
+
+func formatNotifyFlags(flags int32) string {
+	var builder strings.Builder
+
+	for index := range notifyFlagCharacters {
+		if flags&notifyFlagCharacters[index].flag != 0 {
+			builder.WriteRune(notifyFlagCharacters[index].character)
+		}
+	}
+
+	return builder.String()
+}
+
+// publishKeyspaceEvent is the database listener that publishes the keyspace and keyevent notifications.
+func (runtime *Runtime) publishKeyspaceEvent(event database.Event) {
+	var flags = runtime.notifyFlags.Load()
+
+	if int32(event.Class)&flags == 0 {
+		return
+	}
+
+	if flags&notifyKeyspace != 0 {
+		runtime.broker.Publish("__keyspace@0__:"+event.Key, event.Name)
+	}
+
+	if flags&notifyKeyevent != 0 {
+		runtime.broker.Publish("__keyevent@0__:"+event.Name, event.Key)
+	}
+}
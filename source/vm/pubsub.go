@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"strconv"
+
+	"arc/pubsub"
+)
+
+// GetBroker returns the runtime publish/subscribe message broker.
+func (runtime *Runtime) GetBroker() *pubsub.Broker {
+	return runtime.broker
+}
+
+// PUBLISH channel message
+func stdPublish(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	return []string{strconv.Itoa(runtime.broker.Publish(parameters.Get("channel"), parameters.Get("message")))}
+}
+
+// PUBSUB CHANNELS [pattern] | PUBSUB NUMSUB [channel...] | PUBSUB NUMPAT
+func stdPubsub(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	switch parameters.Get("subcommand") {
+	case "CHANNELS":
+		return execution.dataResult(runtime.broker.GetChannels(parameters.Get("pattern")))
+	case "NUMSUB":
+		var channels = parameters.GetAll("channel")
+		var result = make([]string, 0, len(channels)*2)
+
+		for _, channel := range channels {
+			result = append(result, channel, strconv.Itoa(runtime.broker.GetNumberOfSubscribers(channel)))
+		}
+
+		return result
+	}
+
+	return []string{strconv.Itoa(runtime.broker.GetNumberOfPatterns())}
+}
+
+// SUBSCRIBE, PSUBSCRIBE, UNSUBSCRIBE and PUNSUBSCRIBE need a streaming connection (e.g. the server /subscribe endpoint).
+func stdSubscribe(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	return streamingOnlyResult
+}
@@ -0,0 +1,106 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"arc/glob"
+)
+
+type (
+	// RateLimiter is implemented by whoever limits the command rate of the clients (e.g. the HTTP server), so the
+	// RATELIMIT command can report and reset its state.
+	RateLimiter interface {
+		// GetRateLimitSettings returns the limiter settings as name and value pairs.
+		GetRateLimitSettings() []string
+
+		// GetRateLimitClients returns the state of the known clients.
+		GetRateLimitClients() []RateLimitState
+
+		// ResetRateLimit refills the budgets of a client, and returns false when the client is unknown.
+		ResetRateLimit(key string) bool
+	}
+
+	// RateLimitState is the rate limit state of a single client.
+	RateLimitState struct {
+		// Key identifies the client (like its address, user or API key).
+		Key string
+
+		// Read and Write are the tokens left in the read and write budgets (-1 for unlimited budgets).
+		Read  float64
+		Write float64
+
+		// Limited is the number of commands rejected because the client was over its budget.
+		Limited uint64
+
+		// LastSeen is the time of the last command of the client.
+		LastSeen time.Time
+	}
+)
+
+// SetRateLimiter sets the rate limiter reported by the RATELIMIT command (there is no rate limiting by default).
+func (runtime *Runtime) SetRateLimiter(limiter RateLimiter) {
+	runtime.rateLimiter = limiter
+}
+
+// RateLimitedResult returns the error result of a command rejected because the client is over its budget, until a
+// command is allowed again after the retryAfter delay.
+func RateLimitedResult(retryAfter time.Duration) TypedResult {
+	return TypedResult{
+		Type:   ErrorResult,
+		Values: []string{fmt.Sprintf("%s: retry after %ds", rateLimitedErrorMessage, int64(math.Ceil(retryAfter.Seconds())))},
+	}
+}
+
+// String formats the state of a client like "key=10.0.0.1 read=9.50 write=-1.00 limited=2 idle=3s".
+func (state *RateLimitState) String() string {
+	return fmt.Sprintf("key=%s read=%.2f write=%.2f limited=%d idle=%s", state.Key, state.Read, state.Write, state.Limited,
+		time.Since(state.LastSeen).Truncate(time.Second))
+}
+
+func stdRatelimit(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var limiter = runtime.rateLimiter
+
+	switch parameters.Get("subcommand") {
+	case "STATUS":
+		if limiter == nil {
+			return []string{"enabled", "no"}
+		}
+
+		return append([]string{"enabled", "yes"}, limiter.GetRateLimitSettings()...)
+	case "CLIENTS":
+		if limiter == nil {
+			return emptyResult
+		}
+
+		var pattern = "*"
+
+		if parameters.Has("pattern") {
+			pattern = parameters.Get("pattern")
+		}
+
+		var states = limiter.GetRateLimitClients()
+
+		sort.Slice(states, func(first int, second int) bool {
+			return states[first].Key < states[second].Key
+		})
+
+		var lines = make([]string, 0, len(states))
+
+		for index := range states {
+			if glob.Match(pattern, states[index].Key) {
+				lines = append(lines, states[index].String())
+			}
+		}
+
+		return lines
+	}
+
+	if (limiter == nil) || !limiter.ResetRateLimit(parameters.Get("client-key")) {
+		return []string{"0"}
+	}
+
+	return []string{"1"}
+}
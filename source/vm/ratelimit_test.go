@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRateLimiter struct {
+	clients []RateLimitState
+}
+
+func (limiter *testRateLimiter) GetRateLimitSettings() []string {
+	return []string{"read-rate", "10"}
+}
+
+func (limiter *testRateLimiter) GetRateLimitClients() []RateLimitState {
+	return append([]RateLimitState{}, limiter.clients...)
+}
+
+func (limiter *testRateLimiter) ResetRateLimit(key string) bool {
+	for index := range limiter.clients {
+		if limiter.clients[index].Key == key {
+			limiter.clients[index].Limited = 0
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestRatelimitCommand(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	if result := testRuntime.Execute("RATELIMIT STATUS"); strings.Join(result, " ") != "enabled no" {
+		test.Errorf("RATELIMIT STATUS without limiter = %q", result)
+	}
+
+	testRuntime.SetRateLimiter(&testRateLimiter{clients: []RateLimitState{
+		{Key: "user:bob", Read: 1, Write: -1, LastSeen: time.Now()},
+		{Key: "10.0.0.1", Read: 0.5, Write: 2, Limited: 3, LastSeen: time.Now()},
+		{Key: "user:alice", Read: 10, Write: 5, LastSeen: time.Now()},
+	}})
+
+	var tests = []struct {
+		line     string
+		expected string
+	}{
+		{"RATELIMIT STATUS", "enabled yes read-rate 10"},
+		{"RATELIMIT CLIENTS", "key=10.0.0.1 read=0.50 write=2.00 limited=3 idle=0s key=user:alice read=10.00 write=5.00 limited=0 idle=0s key=user:bob read=1.00 write=-1.00 limited=0 idle=0s"},
+		{"RATELIMIT CLIENTS user:*", "key=user:alice read=10.00 write=5.00 limited=0 idle=0s key=user:bob read=1.00 write=-1.00 limited=0 idle=0s"},
+		{"RATELIMIT RESET 10.0.0.1", "1"},
+		{"RATELIMIT RESET 10.0.0.2", "0"},
+		{"RATELIMIT CLIENTS 10.*", "key=10.0.0.1 read=0.50 write=2.00 limited=0 idle=0s"},
+	}
+
+	for _, testCase := range tests {
+		if result := strings.Join(testRuntime.Execute(testCase.line), " "); result != testCase.expected {
+			test.Errorf("%s = %q, expected %q", testCase.line, result, testCase.expected)
+		}
+	}
+}
+
+func TestRateLimitedResult(test *testing.T) {
+	var result = RateLimitedResult(1500 * time.Millisecond)
+
+	if _, err := result.Get(); !errors.Is(err, ErrRateLimited) || (result.Values[0] != "Error: rate limit exceeded: retry after 2s") {
+		test.Errorf("RateLimitedResult = %q", result)
+	}
+}
@@ -0,0 +1,259 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interval between replica acknowledgements and between reconnection attempts.
+const (
+	replicaAckInterval       = time.Second
+	replicaReconnectInterval = time.Second
+)
+
+// Replica link states.
+const (
+	linkConnecting = "connecting"
+	linkSyncing    = "sync"
+	linkUp         = "up"
+	linkDown       = "down"
+)
+
+type (
+	leaderLink struct {
+		host   string
+		port   string
+		cancel context.CancelFunc
+		done   chan struct{}
+
+		mutex  sync.Mutex
+		status string
+		lastIO time.Time
+	}
+)
+
+func (link *leaderLink) setStatus(status string) {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+
+	link.status = status
+	link.lastIO = time.Now()
+}
+
+func (link *leaderLink) getStatus() (status string, lastIO time.Time) {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+
+	return link.status, link.lastIO
+}
+
+func (link *leaderLink) getAddress() string {
+	return "http://" + net.JoinHostPort(link.host, link.port)
+}
+
+// ReplicaOf makes the runtime a read only replica of the leader at the specified host and port.
+func (runtime *Runtime) ReplicaOf(host string, port string) {
+	runtime.StopReplication()
+
+	var linkContext, cancel = context.WithCancel(context.Background())
+
+	var link = &leaderLink{
+		host:   host,
+		port:   port,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: linkConnecting,
+	}
+
+	runtime.replication.mutex.Lock()
+	runtime.replication.leader = link
+	runtime.replication.mutex.Unlock()
+
+	go runtime.runReplica(linkContext, link)
+}
+
+// StopReplication stops replicating from the current leader (if any), keeping the current data.
+func (runtime *Runtime) StopReplication() {
+	var state = runtime.replication
+
+	state.mutex.Lock()
+	var link = state.leader
+	state.leader = nil
+	state.mutex.Unlock()
+
+	if link != nil {
+		link.cancel()
+		<-link.done
+	}
+}
+
+func (runtime *Runtime) runReplica(ctx context.Context, link *leaderLink) {
+	defer close(link.done)
+
+	for {
+		log.Printf("REPL: connecting to leader at %s", link.getAddress())
+		link.setStatus(linkConnecting)
+
+		var err = runtime.syncWithLeader(ctx, link)
+		link.setStatus(linkDown)
+
+		select {
+		case <-ctx.Done():
+			log.Printf("REPL: stopped replicating from %s", link.getAddress())
+			return
+		case <-time.After(replicaReconnectInterval):
+			log.Printf("REPL: connection to leader lost (%v), reconnecting", err)
+		}
+	}
+}
+
+func (runtime *Runtime) syncWithLeader(ctx context.Context, link *leaderLink) (err error) {
+	var state = runtime.replication
+
+	state.mutex.Lock()
+	var query = url.Values{
+		"replica": {state.runID},
+		"id":      {state.id},
+		"offset":  {strconv.FormatInt(state.offset, 10)},
+	}
+	state.mutex.Unlock()
+
+	var request *http.Request
+
+	if request, err = http.NewRequestWithContext(ctx, http.MethodGet, link.getAddress()+"/replication?"+query.Encode(), nil); err != nil {
+		return
+	}
+
+	var response *http.Response
+
+	if response, err = http.DefaultClient.Do(request); err != nil {
+		return
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected leader response: %s", response.Status)
+	}
+
+	var ackContext, stopAck = context.WithCancel(ctx)
+	defer stopAck()
+
+	go runtime.sendAcks(ackContext, link)
+
+	var decoder = json.NewDecoder(response.Body)
+
+	for {
+		var message ReplicationMessage
+
+		if err = decoder.Decode(&message); err != nil {
+			return
+		}
+
+		link.setStatus(linkUp)
+
+		switch message.Type {
+		case FullResyncMessage:
+			log.Printf("REPL: full resynchronization from %s (offset %d)", link.getAddress(), message.Offset)
+			link.setStatus(linkSyncing)
+
+			state.writeMutex.Lock()
+			runtime.db.Flush()
+			state.mutex.Lock()
+			state.id = message.ID
+			state.offset = message.Offset
+			state.backlog = nil
+			state.mutex.Unlock()
+			state.writeMutex.Unlock()
+		case ContinueMessage:
+			log.Printf("REPL: partial resynchronization from %s (offset %d)", link.getAddress(), message.Offset)
+		case SnapshotMessage:
+			if len(message.Arguments) > 0 {
+				runtime.applySnapshotCommand(message.Arguments)
+			}
+		case CommandMessage:
+			if len(message.Arguments) > 0 {
+				runtime.run(&ExecutionContext{
+					Client:     leaderClientIdentity,
+					Command:    message.Arguments[0],
+					Parameters: message.Arguments[1:],
+					fromLeader: true,
+				})
+			}
+
+			// Keep the leader offset even if the command failed locally, so partial resynchronizations still work.
+
+			state.mutex.Lock()
+			state.offset = message.Offset
+			state.mutex.Unlock()
+		}
+	}
+}
+
+// applySnapshotCommand applies a snapshot command, snapshot commands are not propagated (nor counted in the offset).
+func (runtime *Runtime) applySnapshotCommand(arguments []string) {
+	var function, exists = runtime.libraryCache[strings.ToUpper(arguments[0])]
+
+	if !exists {
+		return
+	}
+
+	var execution = &ExecutionContext{
+		Context:    context.Background(),
+		Client:     leaderClientIdentity,
+		Command:    function.command,
+		Parameters: arguments[1:],
+		fromLeader: true,
+	}
+
+	if parameters, errorResult := parseParameters(function.arguments, execution.Parameters); errorResult == nil {
+		function.call(runtime, execution, parameters)
+	}
+}
+
+// sendAcks periodically reports the replica offset to the leader.
+func (runtime *Runtime) sendAcks(ctx context.Context, link *leaderLink) {
+	var ticker = time.NewTicker(replicaAckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runtime.replication.mutex.Lock()
+			var command = fmt.Sprintf("REPLCONF ACK %s %d", runtime.replication.runID, runtime.replication.offset)
+			runtime.replication.mutex.Unlock()
+
+			var request, _ = http.NewRequestWithContext(ctx, http.MethodGet, link.getAddress()+"/?cmd="+url.QueryEscape(command), nil)
+
+			if response, err := http.DefaultClient.Do(request); err == nil {
+				response.Body.Close()
+			}
+		}
+	}
+}
+
+// REPLICAOF host port | REPLICAOF NO ONE
+func stdReplicaof(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if parameters.Has("NO") {
+		runtime.StopReplication()
+		return okResult
+	}
+
+	if _, err := strconv.ParseUint(parameters.Get("port"), 10, 16); err != nil {
+		return invalidParameterValueResult
+	}
+
+	runtime.ReplicaOf(parameters.Get("host"), parameters.Get("port"))
+	return okResult
+}
@@ -0,0 +1,161 @@
+package vm
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLeader serves the replication stream of a leader runtime, and records the resynchronization messages sent.
+type testLeader struct {
+	runtime *Runtime
+	server  *httptest.Server
+	mutex   sync.Mutex
+	resyncs []string
+}
+
+func createTestLeader(test *testing.T) *testLeader {
+	var leader = &testLeader{runtime: createTestRuntime(test)}
+	var mux = http.NewServeMux()
+
+	mux.HandleFunc("/replication", func(response http.ResponseWriter, request *http.Request) {
+		var query = request.URL.Query()
+		var offset, _ = strconv.ParseInt(query.Get("offset"), 10, 64)
+		var flusher = response.(http.Flusher)
+		var encoder = json.NewEncoder(response)
+
+		response.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		leader.runtime.ServeReplica(request.Context(), query.Get("replica"), request.RemoteAddr, query.Get("id"), offset, func(message ReplicationMessage) (err error) {
+			if (message.Type == FullResyncMessage) || (message.Type == ContinueMessage) {
+				leader.mutex.Lock()
+				leader.resyncs = append(leader.resyncs, message.Type)
+				leader.mutex.Unlock()
+			}
+
+			if err = encoder.Encode(message); err == nil {
+				flusher.Flush()
+			}
+
+			return
+		})
+	})
+
+	mux.HandleFunc("/", func(response http.ResponseWriter, request *http.Request) {
+		leader.runtime.Execute(request.URL.Query().Get("cmd"))
+	})
+
+	leader.server = httptest.NewServer(mux)
+	test.Cleanup(leader.server.Close)
+	return leader
+}
+
+func (leader *testLeader) getResyncs() []string {
+	leader.mutex.Lock()
+	defer leader.mutex.Unlock()
+
+	return append([]string{}, leader.resyncs...)
+}
+
+// waitForValue waits until a key of the runtime has the expected value.
+func waitForValue(test *testing.T, runtime *Runtime, key string, expected string) {
+	var deadline = time.Now().Add(5 * time.Second)
+
+	for {
+		var result = strings.Join(runtime.Execute("GET "+key), " ")
+
+		if result == expected {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			test.Fatalf("GET %s = %q, expected %q", key, result, expected)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplicaResync(test *testing.T) {
+	var leader = createTestLeader(test)
+	var replica = createTestRuntime(test)
+	var host, port, _ = net.SplitHostPort(strings.TrimPrefix(leader.server.URL, "http://"))
+
+	defer replica.StopReplication()
+
+	leader.runtime.Execute("SET first 1")
+	leader.runtime.Execute("ZADD scores 1 alice 2 bob")
+
+	// The first synchronization is a full resynchronization, followed by the write stream.
+
+	replica.ReplicaOf(host, port)
+	waitForValue(test, replica, "first", "1")
+
+	leader.runtime.Execute("INCR first")
+	leader.runtime.Execute("SET second 2")
+	leader.runtime.Execute("DEL scores")
+	waitForValue(test, replica, "second", "2")
+
+	if result := strings.Join(replica.Execute("GET first"), " "); result != "2" {
+		test.Errorf("replica GET first = %q", result)
+	}
+
+	if result := strings.Join(replica.Execute("TYPE scores"), " "); result != "none" {
+		test.Errorf("replica TYPE scores = %q", result)
+	}
+
+	// Reconnecting with the same replication ID and offset continues from the backlog.
+
+	replica.StopReplication()
+	leader.runtime.Execute("SET third 3")
+	replica.ReplicaOf(host, port)
+	waitForValue(test, replica, "third", "3")
+
+	if resyncs := strings.Join(leader.getResyncs(), " "); resyncs != "fullresync continue" {
+		test.Errorf("resynchronizations = %q", resyncs)
+	}
+}
+
+func TestReplicaExpireTimes(test *testing.T) {
+	var leader = createTestLeader(test)
+	var replica = createTestRuntime(test)
+	var host, port, _ = net.SplitHostPort(strings.TrimPrefix(leader.server.URL, "http://"))
+
+	defer replica.StopReplication()
+
+	replica.ReplicaOf(host, port)
+	leader.runtime.Execute("SET first 1")
+	waitForValue(test, replica, "first", "1")
+
+	// The relative expirations are propagated as expire times, so a write applied late by the replica (here replayed
+	// from the backlog) doesn't expire later than on the leader.
+
+	replica.StopReplication()
+	leader.runtime.Execute("SET session value PX 2000")
+	leader.runtime.Execute("SET token value NX EX 2")
+	time.Sleep(500 * time.Millisecond)
+
+	replica.ReplicaOf(host, port)
+	waitForValue(test, replica, "token", "value")
+
+	for _, key := range []string{"session", "token"} {
+		var ttl, err = strconv.ParseInt(strings.Join(replica.Execute("PTTL "+key), " "), 10, 64)
+
+		if (err != nil) || (ttl <= 0) || (ttl > 1500) {
+			test.Errorf("replica PTTL %s = %d %v, expected at most 1500 after a 500ms delay", key, ttl, err)
+		}
+	}
+
+	var entries, _ = leader.runtime.replication.getBacklogAfter(0)
+
+	if arguments := strings.Join(entries[len(entries)-1].arguments, " "); !strings.HasPrefix(arguments, "SET token value NX PXAT ") {
+		test.Errorf("propagated SET = %q", arguments)
+	}
+}
@@ -0,0 +1,322 @@
+package vm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"arc/database"
+)
+
+// Replication settings: replication offsets count write commands, not bytes.
+const (
+	defaultReplicationBacklogSize = 10000
+	replicaFeedBufferSize         = 4096
+	replicationPingInterval       = time.Second
+)
+
+// Replication message types.
+const (
+	FullResyncMessage    = "fullresync"
+	ContinueMessage      = "continue"
+	SnapshotMessage      = "snapshot"
+	CommandMessage       = "command"
+	PingMessage          = "ping"
+	leaderClientIdentity = "leader"
+)
+
+// ErrReplicaTooSlow is returned when a replica does not keep up with the leader write stream.
+var ErrReplicaTooSlow = errors.New("replica is not keeping up with the write stream")
+
+type (
+	// ReplicationMessage represents a single message of the leader to replica stream.
+	ReplicationMessage struct {
+		Type      string   `json:"type"`
+		ID        string   `json:"id,omitempty"`
+		Offset    int64    `json:"offset,omitempty"`
+		Arguments []string `json:"args,omitempty"`
+	}
+
+	replicationEntry struct {
+		offset    int64
+		arguments []string
+	}
+
+	replicaFeed struct {
+		id         string
+		address    string
+		messages   chan ReplicationMessage
+		closed     bool
+		ackOffset  int64
+		ackTime    time.Time
+		connection time.Time
+	}
+
+	replicationState struct {
+		// writeMutex serializes write commands and snapshots.
+		writeMutex sync.Mutex
+
+		mutex       sync.Mutex
+		id          string
+		offset      int64
+		backlog     []replicationEntry
+		backlogSize int
+		replicas    map[*replicaFeed]struct{}
+		leader      *leaderLink
+		readOnly    bool
+		runID       string
+	}
+)
+
+func generateReplicationID() string {
+	var randomBytes = make([]byte, 20)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
+
+func createReplicationState() *replicationState {
+	return &replicationState{
+		id:          generateReplicationID(),
+		backlogSize: defaultReplicationBacklogSize,
+		replicas:    make(map[*replicaFeed]struct{}),
+		readOnly:    true,
+		runID:       generateReplicationID(),
+	}
+}
+
+func (state *replicationState) isReadOnly() bool {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return (state.leader != nil) && state.readOnly
+}
+
+func (state *replicationState) isReplica() bool {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return state.leader != nil
+}
+
+// propagate adds a write command to the backlog and sends it to the connected replicas.
+func (state *replicationState) propagate(arguments []string) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.offset++
+	state.backlog = append(state.backlog, replicationEntry{offset: state.offset, arguments: arguments})
+
+	if len(state.backlog) > state.backlogSize*2 {
+		state.backlog = append([]replicationEntry(nil), state.backlog[len(state.backlog)-state.backlogSize:]...)
+	}
+
+	var message = ReplicationMessage{Type: CommandMessage, Offset: state.offset, Arguments: arguments}
+
+	for feed := range state.replicas {
+		select {
+		case feed.messages <- message:
+		default:
+			// The replica will reconnect and try a partial resynchronization.
+			state.closeFeed(feed)
+		}
+	}
+}
+
+// closeFeed removes a replica feed (the caller must hold the state lock).
+func (state *replicationState) closeFeed(feed *replicaFeed) {
+	if !feed.closed {
+		feed.closed = true
+		close(feed.messages)
+	}
+
+	delete(state.replicas, feed)
+}
+
+// getBacklogAfter returns the backlog entries after an offset, if all of them are still available.
+func (state *replicationState) getBacklogAfter(offset int64) (entries []replicationEntry, ok bool) {
+	if offset == state.offset {
+		return nil, true
+	}
+
+	var keptEntries = min(len(state.backlog), state.backlogSize)
+	var firstIndex = len(state.backlog) - keptEntries
+
+	if (offset > state.offset) || (keptEntries == 0) || (state.backlog[firstIndex].offset > offset+1) {
+		return nil, false
+	}
+
+	var start = firstIndex + int(offset+1-state.backlog[firstIndex].offset)
+	return append([]replicationEntry(nil), state.backlog[start:]...), true
+}
+
+// propagateRemovals is the database listener that propagates expired and evicted keys to the replicas as deletions.
+// Keys are only removed by write commands and by the active expiration, which both hold the write lock, so the
+// deletions are propagated in the same order as the writes.
+func (runtime *Runtime) propagateRemovals(event database.Event) {
+	if (event.Name != database.ExpiredEvent) && (event.Name != database.EvictedEvent) {
+		return
+	}
+
+	if !runtime.replication.isReplica() {
+		runtime.replication.propagate([]string{"DEL", event.Key})
+	}
+}
+
+// ServeReplica synchronizes a replica (with a partial resynchronization from the backlog when possible, or with a
+// full snapshot otherwise) and then streams the write commands until the context is done or the replica fails.
+func (runtime *Runtime) ServeReplica(ctx context.Context, replicaID string, address string, replicationID string, offset int64, send func(message ReplicationMessage) error) (err error) {
+	var state = runtime.replication
+	var initialMessages []ReplicationMessage
+
+	var feed = &replicaFeed{
+		id:         replicaID,
+		address:    address,
+		messages:   make(chan ReplicationMessage, replicaFeedBufferSize),
+		ackOffset:  offset,
+		ackTime:    time.Now(),
+		connection: time.Now(),
+	}
+
+	// Hold the write lock so no write happens between the snapshot (or backlog read) and the feed registration.
+
+	state.writeMutex.Lock()
+	state.mutex.Lock()
+
+	if entries, ok := state.getBacklogAfter(offset); ok && (replicationID == state.id) {
+		initialMessages = append(initialMessages, ReplicationMessage{Type: ContinueMessage, ID: state.id, Offset: offset})
+
+		for index := range entries {
+			initialMessages = append(initialMessages, ReplicationMessage{Type: CommandMessage, Offset: entries[index].offset, Arguments: entries[index].arguments})
+		}
+	} else {
+		state.mutex.Unlock()
+		var snapshot = runtime.db.Snapshot()
+		state.mutex.Lock()
+
+		initialMessages = append(initialMessages, ReplicationMessage{Type: FullResyncMessage, ID: state.id, Offset: state.offset})
+
+		for index := range snapshot {
+			if arguments := snapshotToCommand(snapshot[index]); arguments != nil {
+				initialMessages = append(initialMessages, ReplicationMessage{Type: SnapshotMessage, Arguments: arguments})
+			}
+		}
+	}
+
+	state.replicas[feed] = struct{}{}
+	state.mutex.Unlock()
+	state.writeMutex.Unlock()
+
+	defer func() {
+		state.mutex.Lock()
+		state.closeFeed(feed)
+		state.mutex.Unlock()
+	}()
+
+	for index := range initialMessages {
+		if err = send(initialMessages[index]); err != nil {
+			return
+		}
+	}
+
+	var ping = time.NewTicker(replicationPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ping.C:
+			state.mutex.Lock()
+			var currentOffset = state.offset
+			state.mutex.Unlock()
+
+			if err = send(ReplicationMessage{Type: PingMessage, Offset: currentOffset}); err != nil {
+				return
+			}
+		case message, open := <-feed.messages:
+			if !open {
+				return ErrReplicaTooSlow
+			}
+
+			if err = send(message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// getReplicatedCommand returns the command propagated to the replicas for a write command. The relative expirations
+// (SET EX and PX) are replaced with the expire time set by the command (PXAT), so the replicas (and the backlog replays
+// after a partial resynchronization) expire the keys at the same time as the leader, however late they apply them.
+func (runtime *Runtime) getReplicatedCommand(identifier string, parameters []string, parsed *Parameters) []string {
+	var arguments = append([]string{identifier}, parameters...)
+
+	if (identifier != "SET") || !(parsed.Has("seconds") || parsed.Has("milliseconds")) {
+		return arguments
+	}
+
+	// The key has no expire time (or another one) when the NX or XX condition failed, and the replica fails it too.
+
+	var information, exists = runtime.db.GetKeyInformation(parsed.Get("key"))
+
+	if !exists || (information.Expires == 0) {
+		return arguments
+	}
+
+	arguments = []string{identifier, parsed.Get("key"), parsed.Get("value")}
+
+	if parsed.Has("condition") {
+		arguments = append(arguments, parsed.Get("condition"))
+	}
+
+	return append(arguments, "PXAT", strconv.FormatInt(information.Expires, 10))
+}
+
+// snapshotToCommand converts a snapshot entry to the command that recreates it.
+func snapshotToCommand(entry database.SnapshotEntry) (arguments []string) {
+	switch entry.Type {
+	case database.SingleValue:
+		if entry.Expires == 0 {
+			return []string{"SET", entry.Key, entry.Value}
+		}
+
+		if entry.Expires > time.Now().UnixMilli() {
+			return []string{"SET", entry.Key, entry.Value, "PXAT", strconv.FormatInt(entry.Expires, 10)}
+		}
+	case database.SortedSetValue:
+		if len(entry.Entries) == 0 {
+			return nil
+		}
+
+		arguments = []string{"ZADD", entry.Key}
+
+		for index := range entry.Entries {
+			var member, score = entry.Entries[index].Get()
+			arguments = append(arguments, strconv.FormatFloat(score, 'g', -1, 64), member)
+		}
+	}
+
+	return
+}
+
+// REPLCONF ACK replica-id offset
+func stdReplconf(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var offset = parameters.GetInt("offset")
+	var state = runtime.replication
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	for feed := range state.replicas {
+		if feed.id == parameters.Get("replica-id") {
+			feed.ackOffset = offset
+			feed.ackTime = time.Now()
+		}
+	}
+
+	return okResult
+}
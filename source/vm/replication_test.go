@@ -0,0 +1,30 @@
+package vm
+
+import (
+	"testing"
+)
+
+func TestReplicationBacklog(test *testing.T) {
+	var testState = createReplicationState()
+	testState.backlogSize = 3
+
+	for index := 0; index < 10; index++ {
+		testState.propagate([]string{"INCR", "counter"})
+	}
+
+	if entries, ok := testState.getBacklogAfter(7); !ok || (len(entries) != 3) || (entries[0].offset != 8) {
+		test.Fail()
+	}
+
+	if entries, ok := testState.getBacklogAfter(10); !ok || (len(entries) != 0) {
+		test.Fail()
+	}
+
+	if _, ok := testState.getBacklogAfter(6); ok {
+		test.Fail()
+	}
+
+	if _, ok := testState.getBacklogAfter(11); ok {
+		test.Fail()
+	}
+}
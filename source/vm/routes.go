@@ -0,0 +1,279 @@
+package vm
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type (
+	// route declares a REST resource route of a library function.
+	route struct {
+		method   string
+		path     string            // path template, variables ({name}) are matched by argument name
+		defaults map[string]string // values of the arguments missing from the request
+		body     string            // argument taking the raw request body (if any)
+		raw      bool              // the response is the raw single value
+	}
+
+	// Route is a REST resource route of a library command. The command parameters are built from the route path
+	// variables and query parameters (matched by argument name, or by token for keyword options, like ?EX=10 or ?NX),
+	// followed by the words of the request body.
+	Route struct {
+		Method  string
+		Path    string
+		Command string
+
+		// Body is the argument taking the raw request body (any bytes), given as a path variable.
+		Body string
+
+		// Raw is set when the response is the raw single value of the command (instead of the result set).
+		Raw bool
+
+		function *LibraryFunction
+		defaults map[string]string
+	}
+
+	// RouteParameter describes a parameter of a REST route.
+	RouteParameter struct {
+		Name string
+
+		// In is where the parameter is given: "path" (a path variable), "body" (the raw request body) or "query" (a
+		// query parameter, or a keyword option, that may also be given as body words or JSON body fields).
+		In string
+
+		// Type is the parameter type: "string", "integer", "number" or "flag" (a keyword option without value).
+		Type string
+
+		Required bool
+		Multiple bool
+		Default  string
+	}
+)
+
+// GetRoutes returns the REST routes of the library commands, in library order.
+func (runtime *Runtime) GetRoutes() (routes []Route) {
+	for index := range runtime.library {
+		var function = runtime.libraryCache[strings.ToUpper(runtime.library[index].command)]
+
+		for _, route := range function.routes {
+			routes = append(routes, Route{
+				Method:   route.method,
+				Path:     route.path,
+				Command:  function.command,
+				Body:     route.body,
+				Raw:      route.raw,
+				function: function,
+				defaults: route.defaults,
+			})
+		}
+	}
+
+	return
+}
+
+// GetArguments returns the command arguments (the command name followed by its parameters) of a request to the route.
+func (route *Route) GetArguments(variables map[string]string, query url.Values, body []string) (arguments []string, err error) {
+	var used = make(map[string]bool)
+
+	arguments = []string{route.Command}
+
+	for index := range route.function.arguments {
+		var argument = &route.function.arguments[index]
+
+		if argument.isOption() {
+			continue
+		}
+
+		var value, exists = variables[argument.name]
+
+		if !exists && query.Has(argument.name) {
+			if argument.multiple {
+				arguments = append(arguments, query[argument.name]...)
+				used[argument.name] = true
+				continue
+			}
+
+			value, exists = query.Get(argument.name), true
+			used[argument.name] = true
+		}
+
+		if !exists {
+			value, exists = route.defaults[argument.name]
+		}
+
+		if !exists {
+			break
+		}
+
+		arguments = append(arguments, value)
+	}
+
+	arguments = append(arguments, body...)
+
+	// Keyword options are given as query parameters, sorted so the same request always builds the same command.
+
+	var names = make([]string, 0, len(query))
+
+	for name := range query {
+		if !used[name] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var options = route.function.getOptionTokens()
+
+	for _, name := range names {
+		var option, isOption = options[strings.ToUpper(name)]
+
+		if !isOption {
+			return nil, fmt.Errorf("unknown parameter '%s'", name)
+		}
+
+		arguments = append(arguments, strings.ToUpper(name))
+
+		if option.kind != tokenKind {
+			arguments = append(arguments, query.Get(name))
+		}
+	}
+
+	return
+}
+
+// GetKey returns the key of a request to the route: the first key argument, when it is a path variable.
+func (route *Route) GetKey(variables map[string]string) (key string, exists bool) {
+	for index := range route.function.arguments {
+		if route.function.arguments[index].kind == keyKind {
+			key, exists = variables[route.function.arguments[index].name]
+			return
+		}
+	}
+
+	return
+}
+
+// HasKey returns if the requests to the route have a key (see GetKey).
+func (route *Route) HasKey() bool {
+	var _, exists = route.GetKey(route.getPathVariables())
+	return exists
+}
+
+// getPathVariables returns the names of the route path variables (mapped to themselves).
+func (route *Route) getPathVariables() (variables map[string]string) {
+	variables = make(map[string]string)
+
+	for _, segment := range strings.Split(route.Path, "/") {
+		if strings.HasPrefix(segment, "{") {
+			variables[strings.Trim(segment, "{}")] = segment
+		}
+	}
+
+	return
+}
+
+// GetParameters returns the parameters of the route, in command order.
+func (route *Route) GetParameters() (parameters []RouteParameter) {
+	var variables = route.getPathVariables()
+
+	for index := range route.function.arguments {
+		var argument = &route.function.arguments[index]
+
+		if argument.isOption() {
+			continue
+		}
+
+		var parameter = RouteParameter{
+			Name:     argument.name,
+			In:       "query",
+			Type:     argument.getTypeName(),
+			Required: !argument.optional,
+			Multiple: argument.multiple,
+		}
+
+		switch _, isVariable := variables[argument.name]; {
+		case isVariable:
+			parameter.In = "path"
+			parameter.Multiple = false
+		case argument.name == route.Body:
+			parameter.In = "body"
+		default:
+			var defaultValue, hasDefault = route.defaults[argument.name]
+			parameter.Default = defaultValue
+			parameter.Required = parameter.Required && !hasDefault
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	var options = route.function.getOptionTokens()
+	var tokens = make([]string, 0, len(options))
+
+	for token := range options {
+		tokens = append(tokens, token)
+	}
+
+	sort.Strings(tokens)
+
+	for _, token := range tokens {
+		var parameter = RouteParameter{Name: token, In: "query", Type: "flag"}
+
+		if options[token].kind != tokenKind {
+			parameter.Type = options[token].getTypeName()
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	return
+}
+
+// GetCommandInfo returns the metadata of the route command.
+func (route *Route) GetCommandInfo(runtime *Runtime) CommandInfo {
+	for _, command := range runtime.GetCommands() {
+		if command.Name == route.Command {
+			return command
+		}
+	}
+
+	return CommandInfo{Name: route.Command}
+}
+
+// getTypeName returns the REST type name of an argument.
+func (argument *argument) getTypeName() string {
+	switch argument.kind {
+	case integerKind:
+		return "integer"
+	case floatKind:
+		return "number"
+	}
+
+	return "string"
+}
+
+// getOptionTokens returns the keyword options of the function by token, options without value are token arguments.
+func (function *LibraryFunction) getOptionTokens() (tokens map[string]*argument) {
+	tokens = make(map[string]*argument)
+
+	for index := range function.arguments {
+		if !function.arguments[index].isOption() {
+			continue
+		}
+
+		var alternatives = []argument{function.arguments[index]}
+
+		if function.arguments[index].kind == oneOfKind {
+			alternatives = function.arguments[index].children
+		}
+
+		for alternativeIndex := range alternatives {
+			if alternatives[alternativeIndex].token != "" {
+				tokens[alternatives[alternativeIndex].token] = &alternatives[alternativeIndex]
+			}
+		}
+	}
+
+	return
+}
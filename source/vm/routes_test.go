@@ -0,0 +1,107 @@
+package vm
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRouteArguments(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var routes = make(map[string]Route)
+
+	for _, route := range testRuntime.GetRoutes() {
+		routes[route.Method+" "+route.Path] = route
+	}
+
+	var tests = []struct {
+		route     string
+		variables map[string]string
+		query     string
+		body      []string
+		expected  string
+	}{
+		{"GET /values/{key}", map[string]string{"key": "k"}, "", nil, "GET k"},
+		{"PUT /values", nil, "", []string{"k", "v"}, "SET k v"},
+		{"PUT /values/{key}", map[string]string{"key": "k", "value": "raw\nvalue"}, "PX=100", nil, "SET k raw\nvalue PX 100"},
+		{"PUT /values", nil, "nx&EX=10", []string{"k", "v"}, "SET k v EX 10 NX"},
+		{"GET /sets/{key}", map[string]string{"key": "z"}, "", nil, "ZRANGE z 0 -1"},
+		{"GET /sets/{key}", map[string]string{"key": "z"}, "stop=2", nil, "ZRANGE z 0 2"},
+		{"GET /sets/{key}/rank/{member}", map[string]string{"key": "z", "member": "m"}, "", nil, "ZRANK z m"},
+		{"PUT /sets", nil, "", []string{"z", "1", "a", "2", "b"}, "ZADD z 1 a 2 b"},
+		{"GET /keys", nil, "pattern=user:*", nil, "KEYS user:*"},
+		{"POST /channels/{channel}", map[string]string{"channel": "news", "message": "hello world"}, "", nil, "PUBLISH news hello world"},
+		{"GET /values/{key}", map[string]string{"key": "k"}, "unknown=1", nil, ""},
+	}
+
+	for _, testCase := range tests {
+		var route, exists = routes[testCase.route]
+
+		if !exists {
+			test.Errorf("%s: missing route", testCase.route)
+			continue
+		}
+
+		var query, _ = url.ParseQuery(testCase.query)
+		var arguments, err = route.GetArguments(testCase.variables, query, testCase.body)
+
+		if (testCase.expected == "") && (err == nil) {
+			test.Errorf("%s?%s = %q, expected an error", testCase.route, testCase.query, arguments)
+		} else if (testCase.expected != "") && (strings.Join(arguments, " ") != testCase.expected) {
+			test.Errorf("%s?%s = %q (%v), expected %q", testCase.route, testCase.query, arguments, err, testCase.expected)
+		}
+	}
+}
+
+func TestRouteParameters(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var routes = make(map[string]Route)
+
+	for _, route := range testRuntime.GetRoutes() {
+		routes[route.Method+" "+route.Path] = route
+	}
+
+	var tests = []struct {
+		route    string
+		expected string
+	}{
+		{"GET /values/{key}", "key:path:string:required"},
+		{"DELETE /values/{key}", "key:path:string:required"},
+		{"PUT /values/{key}", "key:path:string:required value:body:string:required EX:query:integer NX:query:flag PX:query:integer PXAT:query:integer XX:query:flag"},
+		{"GET /sets/{key}", "key:path:string:required start:query:integer:0 stop:query:integer:-1"},
+		{"PUT /sets", "key:query:string:required data:query:string:required:multiple"},
+	}
+
+	for _, testCase := range tests {
+		var route, exists = routes[testCase.route]
+
+		if !exists {
+			test.Errorf("%s: missing route", testCase.route)
+			continue
+		}
+
+		var descriptions []string
+
+		for _, parameter := range route.GetParameters() {
+			var description = parameter.Name + ":" + parameter.In + ":" + parameter.Type
+
+			if parameter.Required {
+				description += ":required"
+			}
+
+			if parameter.Multiple {
+				description += ":multiple"
+			}
+
+			if parameter.Default != "" {
+				description += ":" + parameter.Default
+			}
+
+			descriptions = append(descriptions, description)
+		}
+
+		if strings.Join(descriptions, " ") != testCase.expected {
+			test.Errorf("%s parameters = %q, expected %q", testCase.route, strings.Join(descriptions, " "), testCase.expected)
+		}
+	}
+}
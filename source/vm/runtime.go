@@ -1,41 +1,149 @@
 package vm
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"arc/cluster"
 	"arc/database"
+	"arc/pubsub"
 )
 
 type (
 	// Runtime defines a virtual machine environment to run commands.
 	Runtime struct {
-		db           *database.Database
-		library      Library
-		libraryCache map[string]*LibraryFunction
+		db                *database.Database
+		library           Library
+		libraryCache      map[string]*LibraryFunction
+		commands          []*commandDescription
+		stats             *runtimeStats
+		startTime         time.Time
+		serverInfo        ServerInfo
+		rateLimiter       RateLimiter
+		slowLog           *slowLog
+		monitors          *monitorHub
+		watchers          *watchHub
+		broker            *pubsub.Broker
+		notifyFlags       atomic.Int32
+		replication       *replicationState
+		cluster           *cluster.State
+		slotKeys          *cluster.KeyIndex
+		clusterEnabled    atomic.Bool
+		commandTimeout    atomic.Int64
+		maxArguments      atomic.Int64
+		maxArgumentLength atomic.Int64
+		handler           atomic.Pointer[Handler]
+		middlewares       []Middleware
+		middlewareLock    sync.Mutex
 	}
-)
 
-func getFunctionKey(command string, numberOfParameters int) string {
-	if numberOfParameters <= 0 {
-		return strings.ToUpper(command)
-	} else {
-		return strings.ToUpper(command) + "_" + strconv.FormatInt(int64(numberOfParameters), 10)
+	// ServerInfo is implemented by whoever hosts the runtime (e.g. the HTTP server) to provide server level information.
+	ServerInfo interface {
+		GetMode() string
+		GetConnectedClients() int64
+		GetTotalConnections() uint64
+		GetAddress() string
 	}
+
+	standaloneInfo struct{}
+
+	// ExecutionContext holds the context of a single command execution: who runs it, where, and until when.
+	ExecutionContext struct {
+		// Context carries the deadline and the cancellation of the call, commands stop when it is done.
+		Context context.Context
+
+		// Client identifies the client running the command (the remote address for HTTP clients).
+		Client string
+
+		// User is the authenticated user running the command, empty for anonymous clients.
+		User string
+
+		// Database is the selected database (only database 0 is available).
+		Database int
+
+		// Asking is set when the client was redirected by an ASK cluster response, so commands for slots being
+		// imported are accepted.
+		Asking bool
+
+		// Command is the command name (in upper case) and Parameters its parameters, middlewares may change them.
+		Command    string
+		Parameters []string
+
+		// Condition, when set, makes the execution conditional on the version of a key.
+		Condition *Condition
+
+		// returnsData is set when the command returns stored data (see dataResult).
+		returnsData bool
+
+		fromLeader bool
+	}
+
+	// Condition makes a command execution conditional on the version of a key (used for optimistic concurrency). It is
+	// checked right before the command runs, while holding the write lock for write commands, so no other write may
+	// happen between the check and the command.
+	Condition struct {
+		Key string
+
+		// Check returns if the command may run given the key version (zero when the key does not exist), the command
+		// fails with a precondition error otherwise. A nil Check accepts any version.
+		Check func(version uint64) bool
+
+		// Version is set to the key version seen by the command: the version right after a write command, or right
+		// before a read command (so it is never newer than the value read).
+		Version uint64
+	}
+)
+
+// Version defines the ARC version.
+const Version = "1.1.0"
+
+// Default limits of the command parameters (the number of parameters and the length of each one of them in bytes),
+// the minimums keep the limits high enough to change them back (zero disables a limit).
+const (
+	defaultMaxArguments      = 1024 * 1024
+	defaultMaxArgumentLength = 512 * 1024 * 1024
+	minMaxArguments          = 16
+	minMaxArgumentLength     = 1024
+)
+
+// Client identity used for commands executed in process (e.g. standalone mode).
+const localClient = "local"
+
+// GetMode returns the standalone mode name.
+func (info standaloneInfo) GetMode() string {
+	return "standalone"
+}
+
+// GetConnectedClients returns the number of clients (only the interactive shell) in standalone mode.
+func (info standaloneInfo) GetConnectedClients() int64 {
+	return 1
+}
+
+// GetTotalConnections returns the number of connections received in standalone mode.
+func (info standaloneInfo) GetTotalConnections() uint64 {
+	return 0
+}
+
+// GetAddress returns the (empty) address clients use to connect in standalone mode.
+func (info standaloneInfo) GetAddress() string {
+	return ""
 }
 
 func createLibraryCache(library Library) (cache map[string]*LibraryFunction) {
 	var size = len(library)
-	var functionKey string
 
 	cache = make(map[string]*LibraryFunction, size)
 
 	for index := 0; index < size; index++ {
 		var function = library[index]
-		functionKey = getFunctionKey(function.command, function.numberOfParameters)
-		log.Printf("RTM: cached function %s", functionKey)
-		cache[functionKey] = &function
+		log.Printf("RTM: cached function %s", function.command)
+		cache[strings.ToUpper(function.command)] = &function
 	}
 
 	return
@@ -43,100 +151,241 @@ func createLibraryCache(library Library) (cache map[string]*LibraryFunction) {
 
 // CreateRuntime creates a new runtime to run with the specified command library on the specified database.
 func CreateRuntime(library Library, db *database.Database) (runtime *Runtime) {
-	return &Runtime{
+	runtime = &Runtime{
 		db:           db,
 		library:      library,
 		libraryCache: createLibraryCache(library),
+		commands:     describeLibrary(library),
+		stats:        createRuntimeStats(library),
+		startTime:    time.Now(),
+		serverInfo:   standaloneInfo{},
+		slowLog:      createSlowLog(defaultSlowLogThreshold, defaultSlowLogMaxLength),
+		monitors:     createMonitorHub(),
+		watchers:     createWatchHub(),
+		broker:       pubsub.CreateBroker(),
+		replication:  createReplicationState(),
+		cluster:      cluster.CreateState(),
+		slotKeys:     cluster.CreateKeyIndex(),
 	}
+
+	runtime.maxArguments.Store(defaultMaxArguments)
+	runtime.maxArgumentLength.Store(defaultMaxArgumentLength)
+
+	var handler = Handler(runtime.execute)
+	runtime.handler.Store(&handler)
+
+	db.AddListener(runtime.publishKeyspaceEvent)
+	db.AddListener(runtime.propagateRemovals)
+	db.AddListener(runtime.notifyWatchers)
+	db.AddListener(runtime.indexKeySlots)
+	db.SetWriteLock(&runtime.replication.writeMutex)
+	return
+}
+
+// SetServerInfo sets the server information provider used by the runtime.
+func (runtime *Runtime) SetServerInfo(info ServerInfo) {
+	runtime.serverInfo = info
 }
 
 // Execute executes a database command line and returns the result set (if any).
 func (runtime *Runtime) Execute(line string) []string {
-	var cmd = runtime.parseCommand(line)
+	return runtime.ExecuteWith(ExecutionContext{Client: localClient}, line)
+}
 
-	if cmd == nil {
-		return invlaidCommandLineResult
+// ExecuteFrom executes a database command line issued by the specified client and returns the result set (if any).
+func (runtime *Runtime) ExecuteFrom(client string, line string) []string {
+	return runtime.ExecuteWith(ExecutionContext{Client: client}, line)
+}
+
+// ExecuteAsking executes a database command line issued by a client that was redirected by an ASK cluster response,
+// so commands for slots being imported are accepted.
+func (runtime *Runtime) ExecuteAsking(client string, line string) []string {
+	return runtime.ExecuteWith(ExecutionContext{Client: client, Asking: true}, line)
+}
+
+// ExecuteWith executes a database command line in the specified execution context (whose command and parameters are
+// taken from the command line) and returns the result set (if any).
+func (runtime *Runtime) ExecuteWith(execution ExecutionContext, line string) []string {
+	return runtime.ExecuteTyped(execution, line).Values
+}
+
+// ExecuteTyped executes a database command line like ExecuteWith, and returns the typed result.
+func (runtime *Runtime) ExecuteTyped(execution ExecutionContext, line string) TypedResult {
+	var arguments, err = Tokenize(line)
+
+	if (err == nil) && (len(arguments) > 0) {
+		execution.Command = arguments[0]
+		execution.Parameters = arguments[1:]
+		return runtime.run(&execution)
+	}
+
+	var result = TypedResult{Type: ErrorResult, Values: invlaidCommandLineResult}
+
+	if err != nil {
+		result.Values = []string{invalidCommandLineErrorMessage + ": " + err.Error()}
 	}
 
+	runtime.stats.processed.Increment()
+	runtime.stats.recordResult(result)
+	return result
+}
+
+// dataResult returns stored data (like values, keys or members) as the result of the command, so they are never
+// mistaken for a nil or an error result.
+func (execution *ExecutionContext) dataResult(data []string) []string {
+	execution.returnsData = true
+	return data
+}
+
+// typedResult returns the typed result of a command: the stored data returned by the command (like a GET value) are
+// values, the type of the other results is found from their values (see GetResultType).
+func (execution *ExecutionContext) typedResult(result []string) TypedResult {
+	if execution.returnsData {
+		return TypedResult{Type: ValuesResult, Values: result}
+	}
+
+	return TypedResult{Type: GetResultType(result), Values: result}
+}
+
+// run runs a single command through the middleware chain, commands received from the replication leader bypass the
+// middlewares.
+func (runtime *Runtime) run(execution *ExecutionContext) (result TypedResult) {
+	defer func() {
+		runtime.stats.processed.Increment()
+		runtime.stats.recordResult(result)
+	}()
+
+	if execution.Context == nil {
+		execution.Context = context.Background()
+	}
+
+	execution.Command = strings.ToUpper(execution.Command)
+
+	if timeout := time.Duration(runtime.commandTimeout.Load()); (timeout > 0) && !execution.fromLeader {
+		var cancel context.CancelFunc
+		execution.Context, cancel = context.WithTimeout(execution.Context, timeout)
+		defer cancel()
+	}
+
+	if execution.fromLeader {
+		return runtime.execute(execution)
+	}
+
+	return (*runtime.handler.Load())(execution)
+}
+
+// execute executes a single command and returns its typed result, it is the last handler of the middleware chain.
+func (runtime *Runtime) execute(execution *ExecutionContext) TypedResult {
+	execution.returnsData = false
+	return execution.typedResult(runtime.executeCommand(execution))
+}
+
+// executeCommand executes a single command, commands received from the replication leader bypass the cluster, read
+// only and memory limit checks.
+func (runtime *Runtime) executeCommand(execution *ExecutionContext) (result []string) {
 	var exists bool
 	var function *LibraryFunction
-	var functionKey = getFunctionKey(cmd.identifier, len(cmd.parameters))
+	var identifier = strings.ToUpper(execution.Command)
+	var parameters = execution.Parameters
 
-	if function, exists = runtime.libraryCache[functionKey]; !exists {
+	if function, exists = runtime.libraryCache[identifier]; !exists {
 		return unknownCommandResult
 	}
 
-	return function.call(runtime.db, cmd.parameters)
-}
-
-func (runtime *Runtime) parseCommand(line string) (cmd *command) {
-	cmd = &command{
-		identifier: "",
-		parameters: make([]string, 0),
+	if !execution.fromLeader {
+		if result = runtime.checkArgumentLimits(parameters); result != nil {
+			return
+		}
 	}
 
-	// FIXME: this is too hacky, but works for now: add a space at the end to make sure we interpret the last value found
-	line = line + " "
+	runtime.monitors.broadcast(execution.Client, identifier, parameters)
 
-	var inString bool
-	var escapeChar bool
-	var identifierOK bool
-	var currentValue string
+	var parsedParameters, errorResult = parseParameters(function.arguments, parameters)
 
-	for _, currentChar := range line {
-		if escapeChar {
-			if !identifierOK {
-				return nil
-			}
+	if errorResult != nil {
+		return errorResult
+	}
 
-			currentValue += string(currentChar)
-			continue
+	if !execution.fromLeader && runtime.clusterEnabled.Load() {
+		if redirect := runtime.routeKeys(function.getKeys(parameters), execution.Asking); redirect != nil {
+			return redirect
 		}
+	}
 
-		switch currentChar {
-		case '"':
-			if !identifierOK {
-				return nil
-			}
+	var isWrite = function.flags&writeFlag != 0
 
-			if inString {
-				cmd.parameters = append(cmd.parameters, currentValue)
-				currentValue = ""
-			}
+	if isWrite && !execution.fromLeader && runtime.replication.isReadOnly() {
+		return readOnlyReplicaResult
+	}
 
-			inString = !inString
-		case ' ':
-			if inString {
-				currentValue += string(currentChar)
-				continue
-			}
+	if isWrite && (function.flags&noPropagateFlag == 0) {
+		// Write commands are serialized so they are propagated to the replicas in the same order they are applied,
+		// along with the keys they expire or evict.
 
-			if currentValue == "" {
-				continue
-			}
+		runtime.replication.writeMutex.Lock()
+		defer runtime.replication.writeMutex.Unlock()
+	}
 
-			if !identifierOK {
-				cmd.identifier = strings.ToUpper(currentValue)
-				identifierOK = true
-			} else {
-				cmd.parameters = append(cmd.parameters, currentValue)
-			}
+	if !execution.fromLeader && (function.flags&denyOOMFlag != 0) && !runtime.db.FreeMemory() {
+		return outOfMemoryResult
+	}
 
-			currentValue = ""
-		case '\\':
-			if !inString {
-				return nil
-			}
+	// The command may have waited for the write lock, so it is not started if the client is gone or the time is up.
+
+	if result = getCancellationResult(execution.Context.Err()); result != nil {
+		return
+	}
 
-			escapeChar = true
-		default:
-			currentValue += string(currentChar)
+	if condition := execution.Condition; condition != nil {
+		if condition.Version = runtime.db.GetVersion(condition.Key); (condition.Check != nil) && !condition.Check(condition.Version) {
+			return preconditionFailedResult
 		}
 	}
 
-	if inString || !identifierOK {
-		return nil
+	var startTime = time.Now()
+	result = function.call(runtime, execution, parsedParameters)
+	var duration = time.Since(startTime)
+
+	if isWrite && (execution.Condition != nil) {
+		execution.Condition.Version = runtime.db.GetVersion(execution.Condition.Key)
 	}
 
+	if isWrite && (function.flags&noPropagateFlag == 0) && (execution.typedResult(result).Type != ErrorResult) {
+		runtime.replication.propagate(runtime.getReplicatedCommand(identifier, parameters, parsedParameters))
+	}
+
+	runtime.stats.recordCall(function.command, duration)
+	runtime.slowLog.record(execution.Client, identifier, parameters, duration)
+
 	return
 }
+
+// checkArgumentLimits returns the error result of a command whose parameters exceed the argument limits (if any).
+func (runtime *Runtime) checkArgumentLimits(parameters []string) []string {
+	if maxArguments := runtime.maxArguments.Load(); (maxArguments > 0) && (int64(len(parameters)) > maxArguments) {
+		return []string{fmt.Sprintf("%s: %d arguments, max-arguments is %d", argumentLimitErrorMessage, len(parameters), maxArguments)}
+	}
+
+	if maxLength := runtime.maxArgumentLength.Load(); maxLength > 0 {
+		for index := range parameters {
+			if int64(len(parameters[index])) > maxLength {
+				return []string{fmt.Sprintf("%s: argument %d has %d bytes, max-argument-length is %d", argumentLimitErrorMessage, index+1, len(parameters[index]), maxLength)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// getCancellationResult returns the error result of a command stopped by its context error (if any): a timeout when
+// its deadline was exceeded, or a cancellation (e.g. the client went away).
+func getCancellationResult(err error) []string {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return timeoutResult
+	default:
+		return cancelledResult
+	}
+}
@@ -0,0 +1,90 @@
+package vm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"arc/database"
+)
+
+// createTestRuntime creates a runtime with the standard library and a new database, closed at the end of the test.
+func createTestRuntime(test *testing.T) *Runtime {
+	var testDB = database.Create()
+	test.Cleanup(testDB.Close)
+
+	return CreateRuntime(StandardLibrary, testDB)
+}
+
+func TestConditionalExecution(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var created = &Condition{Key: "k", Check: func(version uint64) bool { return version == 0 }}
+
+	if result := testRuntime.ExecuteWith(ExecutionContext{Condition: created}, "SET k 1"); (len(result) != 1) || (result[0] != okMessage) {
+		test.Fatalf("create only SET = %q", result)
+	}
+
+	if created.Version == 0 {
+		test.Fatal("SET did not report the new version")
+	}
+
+	var recreated = &Condition{Key: "k", Check: created.Check}
+
+	if result := testRuntime.ExecuteWith(ExecutionContext{Condition: recreated}, "SET k 2"); !errors.Is(GetError(result), ErrPreconditionFailed) {
+		test.Errorf("create only SET of an existing key = %q", result)
+	}
+
+	var read = &Condition{Key: "k"}
+
+	if result := testRuntime.ExecuteWith(ExecutionContext{Condition: read}, "GET k"); (len(result) != 1) || (result[0] != "1") || (read.Version != created.Version) {
+		test.Errorf("GET = %q, version %d, expected version %d", result, read.Version, created.Version)
+	}
+
+	var matching = func(expected uint64) *Condition {
+		return &Condition{Key: "k", Check: func(version uint64) bool { return version == expected }}
+	}
+
+	var updated = matching(created.Version)
+
+	if result := testRuntime.ExecuteWith(ExecutionContext{Condition: updated}, "INCR k"); (len(result) != 1) || (result[0] != "2") {
+		test.Errorf("matching INCR = %q", result)
+	}
+
+	if result := testRuntime.ExecuteWith(ExecutionContext{Condition: matching(created.Version)}, "DEL k"); !errors.Is(GetError(result), ErrPreconditionFailed) {
+		test.Errorf("stale DEL = %q", result)
+	}
+
+	if result := testRuntime.ExecuteWith(ExecutionContext{Condition: matching(updated.Version)}, "DEL k"); (len(result) != 1) || (result[0] != "1") {
+		test.Errorf("matching DEL = %q", result)
+	}
+}
+
+func TestArgumentLimits(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var longValue = strings.Repeat("x", 1025)
+	var manyArguments = "ZADD z" + strings.Repeat(" 1 a", 8)
+
+	var tests = []struct {
+		line     string
+		expected error
+	}{
+		{"CONFIG SET max-arguments 15", ErrInvalidParameterValue},
+		{"CONFIG SET max-argument-length 1023", ErrInvalidParameterValue},
+		{"CONFIG SET max-arguments 16", nil},
+		{"CONFIG SET max-argument-length 1kb", nil},
+		{"SET key " + longValue[1:], nil},
+		{"SET key " + longValue, ErrArgumentLimit},
+		{manyArguments[:len(manyArguments)-4], nil},
+		{manyArguments, ErrArgumentLimit},
+		{"CONFIG SET max-arguments 0", nil},
+		{manyArguments, nil},
+	}
+
+	for _, testCase := range tests {
+		var result = testRuntime.Execute(testCase.line)
+
+		if err := GetError(result); ((testCase.expected == nil) && (err != nil)) || ((testCase.expected != nil) && !errors.Is(err, testCase.expected)) {
+			test.Errorf("%.40s = %.80q, expected error %v", testCase.line, result, testCase.expected)
+		}
+	}
+}
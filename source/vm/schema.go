@@ -0,0 +1,429 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// argumentKind defines how an argument is parsed and validated.
+	argumentKind int
+
+	// argument describes a command argument, argument lists are parsed in order, except for the consecutive optional
+	// arguments starting with a token (keyword options, like EX seconds), that are accepted in any order.
+	argument struct {
+		name     string
+		kind     argumentKind
+		token    string     // keyword that must precede the argument value (e.g. EX for EX seconds)
+		optional bool       // the argument may be omitted
+		multiple bool       // the argument may be repeated (only as the last argument)
+		children []argument // block arguments, or one of alternatives
+	}
+
+	// Parameters holds the parsed function parameters: the values of each argument by name, as well as the name of
+	// the chosen alternative for one of arguments and the tokens found.
+	Parameters struct {
+		raw    []string
+		values map[string][]string
+	}
+
+	// parser parses function parameters according to the argument list of a function.
+	parser struct {
+		parameters []string
+		position   int
+		values     map[string][]string
+	}
+)
+
+// Argument kinds.
+const (
+	stringKind argumentKind = iota
+	keyKind
+	integerKind
+	floatKind
+	tokenKind
+	blockKind
+	oneOfKind
+)
+
+func argString(name string) argument {
+	return argument{name: name, kind: stringKind}
+}
+
+func argKey(name string) argument {
+	return argument{name: name, kind: keyKind}
+}
+
+func argInteger(name string) argument {
+	return argument{name: name, kind: integerKind}
+}
+
+func argFloat(name string) argument {
+	return argument{name: name, kind: floatKind}
+}
+
+// argToken is a keyword (that also gives its name to the argument).
+func argToken(token string) argument {
+	return argument{name: token, kind: tokenKind, token: token}
+}
+
+// argBlock groups arguments that go together, a block starting with a token is named after it if name is empty.
+func argBlock(name string, children ...argument) argument {
+	if (name == "") && (len(children) > 0) {
+		name = children[0].token
+	}
+
+	return argument{name: name, kind: blockKind, children: children}
+}
+
+// argOneOf accepts one of the alternatives, the chosen alternative name is the argument value.
+func argOneOf(name string, alternatives ...argument) argument {
+	return argument{name: name, kind: oneOfKind, children: alternatives}
+}
+
+func optional(argument argument) argument {
+	argument.optional = true
+	return argument
+}
+
+func multiple(argument argument) argument {
+	argument.multiple = true
+	return argument
+}
+
+// withToken requires a keyword before the argument value.
+func withToken(token string, argument argument) argument {
+	argument.token = token
+	return argument
+}
+
+// getLeadingTokens returns the keywords that may start the argument (none if it does not start with a keyword).
+func (argument *argument) getLeadingTokens() (tokens []string) {
+	switch {
+	case argument.token != "":
+		return []string{argument.token}
+	case (argument.kind == blockKind) && (len(argument.children) > 0):
+		return argument.children[0].getLeadingTokens()
+	case argument.kind == oneOfKind:
+		for index := range argument.children {
+			var childTokens = argument.children[index].getLeadingTokens()
+
+			if len(childTokens) == 0 {
+				return nil
+			}
+
+			tokens = append(tokens, childTokens...)
+		}
+	}
+
+	return
+}
+
+// startsWith reports if a parameter is one of the argument leading keywords.
+func (argument *argument) startsWith(parameter string) bool {
+	for _, token := range argument.getLeadingTokens() {
+		if strings.EqualFold(token, parameter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isOption reports if the argument is an optional keyword option (accepted in any order).
+func (argument *argument) isOption() bool {
+	return argument.optional && !argument.multiple && (len(argument.getLeadingTokens()) > 0)
+}
+
+// getArity returns the minimum and maximum (negative for unlimited) number of parameters of an argument list.
+func getArity(arguments []argument) (result arity) {
+	for index := range arguments {
+		var argumentArity = arguments[index].getArity()
+
+		if !arguments[index].optional {
+			result.minimum += argumentArity.minimum
+		}
+
+		if (result.maximum >= 0) && (argumentArity.maximum >= 0) && !arguments[index].multiple {
+			result.maximum += argumentArity.maximum
+		} else {
+			result.maximum = -1
+		}
+	}
+
+	return
+}
+
+func (argument *argument) getArity() (result arity) {
+	switch argument.kind {
+	case blockKind:
+		result = getArity(argument.children)
+	case oneOfKind:
+		for index := range argument.children {
+			var childArity = argument.children[index].getArity()
+
+			if (index == 0) || (childArity.minimum < result.minimum) {
+				result.minimum = childArity.minimum
+			}
+
+			if (result.maximum >= 0) && ((childArity.maximum < 0) || (childArity.maximum > result.maximum)) {
+				result.maximum = childArity.maximum
+			}
+		}
+	default:
+		result = arity{1, 1}
+	}
+
+	if (argument.token != "") && (argument.kind != tokenKind) {
+		result.minimum++
+
+		if result.maximum >= 0 {
+			result.maximum++
+		}
+	}
+
+	return
+}
+
+// getSyntax returns the syntax of an argument list, like "key value [NX | XX]".
+func getSyntax(arguments []argument) string {
+	var parts = make([]string, len(arguments))
+
+	for index := range arguments {
+		parts[index] = arguments[index].getSyntax()
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (argument *argument) getSyntax() (syntax string) {
+	switch argument.kind {
+	case tokenKind:
+		syntax = argument.token
+	case blockKind:
+		syntax = getSyntax(argument.children)
+	case oneOfKind:
+		var alternatives = make([]string, len(argument.children))
+
+		for index := range argument.children {
+			alternatives[index] = argument.children[index].getSyntax()
+		}
+
+		syntax = strings.Join(alternatives, " | ")
+	default:
+		syntax = argument.name
+	}
+
+	if (argument.token != "") && (argument.kind != tokenKind) {
+		syntax = argument.token + " " + syntax
+	}
+
+	if argument.multiple {
+		syntax = syntax + " [" + syntax + " ...]"
+	}
+
+	if argument.optional {
+		syntax = "[" + syntax + "]"
+	}
+
+	return
+}
+
+// parseParameters parses the parameters of a function call according to its argument list.
+func parseParameters(arguments []argument, parameters []string) (*Parameters, []string) {
+	var parser = &parser{
+		parameters: parameters,
+		values:     make(map[string][]string),
+	}
+
+	if result := parser.parseList(arguments); result != nil {
+		return nil, result
+	}
+
+	if parser.position < len(parameters) {
+		return nil, parser.syntaxError()
+	}
+
+	return &Parameters{raw: parameters, values: parser.values}, nil
+}
+
+func (parser *parser) remaining() int {
+	return len(parser.parameters) - parser.position
+}
+
+func (parser *parser) syntaxError() []string {
+	return []string{fmt.Sprintf("%s: unexpected '%s'", syntaxErrorMessage, parser.parameters[parser.position])}
+}
+
+// parseList parses an argument list, returning the error result (if any).
+func (parser *parser) parseList(arguments []argument) []string {
+	for index := 0; index < len(arguments); {
+		if !arguments[index].isOption() {
+			if result := parser.parseArgument(&arguments[index]); result != nil {
+				return result
+			}
+
+			index++
+			continue
+		}
+
+		// Consecutive options are accepted in any order, but each one of them only once.
+
+		var end = index
+
+		for (end < len(arguments)) && arguments[end].isOption() {
+			end++
+		}
+
+		var options = arguments[index:end]
+		var parsed = make([]bool, len(options))
+
+	nextOption:
+		for parser.remaining() > 0 {
+			for option := range options {
+				if !parsed[option] && options[option].startsWith(parser.parameters[parser.position]) {
+					if result := parser.parseValue(&options[option]); result != nil {
+						return result
+					}
+
+					parsed[option] = true
+					continue nextOption
+				}
+			}
+
+			break
+		}
+
+		index = end
+	}
+
+	return nil
+}
+
+// parseArgument parses an argument (that may be optional or repeated).
+func (parser *parser) parseArgument(argument *argument) []string {
+	if argument.optional && ((parser.remaining() == 0) || ((argument.token != "") && !argument.startsWith(parser.parameters[parser.position]))) {
+		return nil
+	}
+
+	if result := parser.parseValue(argument); result != nil {
+		return result
+	}
+
+	for argument.multiple && (parser.remaining() > 0) {
+		if result := parser.parseValue(argument); result != nil {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// parseValue parses a single occurrence of an argument.
+func (parser *parser) parseValue(argument *argument) []string {
+	if (argument.token != "") && (argument.kind != tokenKind) {
+		if parser.remaining() == 0 {
+			return []string{fmt.Sprintf("%s: missing '%s'", invalidParametersErrorMessage, argument.token)}
+		}
+
+		if !strings.EqualFold(parser.parameters[parser.position], argument.token) {
+			return parser.syntaxError()
+		}
+
+		parser.values[argument.token] = append(parser.values[argument.token], argument.token)
+		parser.position++
+	}
+
+	switch argument.kind {
+	case blockKind:
+		return parser.parseList(argument.children)
+	case oneOfKind:
+		var chosen = -1
+
+		for index := range argument.children {
+			if (parser.remaining() > 0) && argument.children[index].startsWith(parser.parameters[parser.position]) {
+				chosen = index
+				break
+			}
+
+			if (chosen < 0) && (len(argument.children[index].getLeadingTokens()) == 0) {
+				chosen = index
+			}
+		}
+
+		if chosen < 0 {
+			if parser.remaining() == 0 {
+				return []string{fmt.Sprintf("%s: missing '%s'", invalidParametersErrorMessage, argument.name)}
+			}
+
+			return parser.syntaxError()
+		}
+
+		parser.values[argument.name] = append(parser.values[argument.name], argument.children[chosen].name)
+		return parser.parseValue(&argument.children[chosen])
+	}
+
+	if parser.remaining() == 0 {
+		return []string{fmt.Sprintf("%s: missing '%s'", invalidParametersErrorMessage, argument.name)}
+	}
+
+	var value = parser.parameters[parser.position]
+
+	switch argument.kind {
+	case tokenKind:
+		if !strings.EqualFold(value, argument.token) {
+			return parser.syntaxError()
+		}
+
+		value = argument.token
+	case integerKind:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return []string{fmt.Sprintf("%s: '%s' must be an integer", invalidParameterValueErrorMessage, argument.name)}
+		}
+	case floatKind:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return []string{fmt.Sprintf("%s: '%s' must be a number", invalidParameterValueErrorMessage, argument.name)}
+		}
+	}
+
+	parser.values[argument.name] = append(parser.values[argument.name], value)
+	parser.position++
+	return nil
+}
+
+// Has reports if an argument (or token) was given.
+func (parameters *Parameters) Has(name string) bool {
+	return len(parameters.values[name]) > 0
+}
+
+// Get returns the (first) value of an argument, or an empty string if it was not given.
+func (parameters *Parameters) Get(name string) string {
+	if values := parameters.values[name]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// GetAll returns all the values of a repeated argument.
+func (parameters *Parameters) GetAll(name string) []string {
+	return parameters.values[name]
+}
+
+// GetInt returns the (first) value of an integer argument, or zero if it was not given.
+func (parameters *Parameters) GetInt(name string) int64 {
+	var value, _ = strconv.ParseInt(parameters.Get(name), 10, 64)
+	return value
+}
+
+// GetFloat returns the (first) value of a float argument, or zero if it was not given.
+func (parameters *Parameters) GetFloat(name string) float64 {
+	var value, _ = strconv.ParseFloat(parameters.Get(name), 64)
+	return value
+}
+
+// GetRaw returns the unparsed parameters.
+func (parameters *Parameters) GetRaw() []string {
+	return parameters.raw
+}
@@ -0,0 +1,61 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseParameters(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	var tests = []struct {
+		commandLine string
+		expected    string
+	}{
+		{"SET k v", "OK"},
+		{"SET k v XX EX 10", "OK"},
+		{"SET k v ex 10 nx", NilMessage},
+		{"SET k2 v PX 1500 NX", "OK"},
+		{"SET k v EXX 10", syntaxErrorMessage + ": unexpected 'EXX'"},
+		{"SET k v EX 10 EX 10", syntaxErrorMessage + ": unexpected 'EX'"},
+		{"SET k v EX ten", invalidParameterValueErrorMessage + ": 'seconds' must be an integer"},
+		{"SET k v EX 0", invalidParameterValueErrorMessage},
+		{"SET k v EX", invalidParametersErrorMessage + ": missing 'seconds'"},
+		{"SET k", invalidParametersErrorMessage + ": missing 'value'"},
+		{"GET k extra", syntaxErrorMessage + ": unexpected 'extra'"},
+		{"ZADD z 1 a 2 b", "2"},
+		{"ZADD z 1 a 2", invalidParametersErrorMessage + ": missing 'member'"},
+		{"ZADD z one a", invalidParameterValueErrorMessage + ": 'score' must be a number"},
+		{"ZRANGE z 0 -1", "a b"},
+		{"CONFIG GET slowlog-max-len", "slowlog-max-len 128"},
+		{"CONFIG FETCH x", syntaxErrorMessage + ": unexpected 'FETCH'"},
+		{"CONFIG", invalidParametersErrorMessage + ": missing 'subcommand'"},
+	}
+
+	for _, testCase := range tests {
+		if result := strings.Join(testRuntime.Execute(testCase.commandLine), " "); result != testCase.expected {
+			test.Errorf("%s = %q, expected %q", testCase.commandLine, result, testCase.expected)
+		}
+	}
+}
+
+func TestSyntax(test *testing.T) {
+	var tests = map[string]string{
+		"SET":     "SET key value [NX | XX] [EX seconds | PX milliseconds | PXAT unix-time-milliseconds]",
+		"ZADD":    "ZADD key score member [score member ...]",
+		"DBSIZE":  "DBSIZE",
+		"SLOWLOG": "SLOWLOG GET [count] | LEN | RESET",
+	}
+
+	for _, function := range StandardLibrary {
+		if expected, exists := tests[function.command]; exists && (function.GetHelp() != expected) {
+			test.Errorf("%s syntax = %q, expected %q", function.command, function.GetHelp(), expected)
+		}
+
+		var arity = getArity(function.arguments)
+
+		if (arity.maximum >= 0) && (arity.minimum > arity.maximum) {
+			test.Errorf("%s: invalid arity %v", function.command, arity)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Slow log defaults and argument truncation limits.
+const (
+	defaultSlowLogThreshold = 10 * time.Millisecond
+	defaultSlowLogMaxLength = 128
+	slowLogMaxArguments     = 32
+	slowLogMaxArgumentSize  = 128
+)
+
+type (
+	slowLogEntry struct {
+		id        int64
+		timestamp time.Time
+		duration  time.Duration
+		arguments []string
+		client    string
+	}
+
+	// slowLog holds the most recent slow commands in a bounded ring buffer.
+	slowLog struct {
+		mutex     sync.Mutex
+		threshold time.Duration
+		entries   []*slowLogEntry
+		next      int
+		size      int
+		nextID    int64
+	}
+)
+
+func createSlowLog(threshold time.Duration, maxLength int) *slowLog {
+	return &slowLog{
+		threshold: threshold,
+		entries:   make([]*slowLogEntry, maxLength),
+	}
+}
+
+func (log *slowLog) getThreshold() time.Duration {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	return log.threshold
+}
+
+func (log *slowLog) setThreshold(threshold time.Duration) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	log.threshold = threshold
+}
+
+func (log *slowLog) getMaxLength() int {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	return len(log.entries)
+}
+
+// setMaxLength resizes the ring buffer, keeping the most recent entries.
+func (log *slowLog) setMaxLength(maxLength int) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	var recent = log.getRecent(maxLength)
+	log.entries = make([]*slowLogEntry, maxLength)
+	log.size = len(recent)
+	log.next = log.size % max(maxLength, 1)
+
+	for index := range recent {
+		log.entries[log.size-index-1] = recent[index]
+	}
+}
+
+// record adds a command to the log if its duration is above the threshold (a negative threshold disables the log).
+func (log *slowLog) record(client string, identifier string, parameters []string, duration time.Duration) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	if (log.threshold < 0) || (duration < log.threshold) || (len(log.entries) == 0) {
+		return
+	}
+
+	var arguments = make([]string, 0, min(len(parameters)+1, slowLogMaxArguments))
+	arguments = append(arguments, identifier)
+
+	for index := range parameters {
+		if len(arguments) == slowLogMaxArguments-1 {
+			arguments = append(arguments, fmt.Sprintf("... (%d more arguments)", len(parameters)-index))
+			break
+		}
+
+		if len(parameters[index]) > slowLogMaxArgumentSize {
+			arguments = append(arguments, fmt.Sprintf("%s... (%d more bytes)", parameters[index][:slowLogMaxArgumentSize], len(parameters[index])-slowLogMaxArgumentSize))
+		} else {
+			arguments = append(arguments, parameters[index])
+		}
+	}
+
+	log.entries[log.next] = &slowLogEntry{
+		id:        log.nextID,
+		timestamp: time.Now(),
+		duration:  duration,
+		arguments: arguments,
+		client:    client,
+	}
+
+	log.nextID++
+	log.next = (log.next + 1) % len(log.entries)
+
+	if log.size < len(log.entries) {
+		log.size++
+	}
+}
+
+// getRecent returns up to count entries, most recent first (the caller must hold the lock).
+func (log *slowLog) getRecent(count int) (entries []*slowLogEntry) {
+	if (count < 0) || (count > log.size) {
+		count = log.size
+	}
+
+	entries = make([]*slowLogEntry, count)
+
+	for index := 0; index < count; index++ {
+		entries[index] = log.entries[(log.next-index-1+len(log.entries))%len(log.entries)]
+	}
+
+	return
+}
+
+func (log *slowLog) get(count int) []*slowLogEntry {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	return log.getRecent(count)
+}
+
+func (log *slowLog) length() int {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	return log.size
+}
+
+func (log *slowLog) reset() {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	log.entries = make([]*slowLogEntry, len(log.entries))
+	log.next = 0
+	log.size = 0
+}
+
+func (entry *slowLogEntry) String() string {
+	return fmt.Sprintf("%d %d %d %s %s", entry.id, entry.timestamp.Unix(), entry.duration.Microseconds(), entry.client, strings.Join(entry.arguments, " "))
+}
+
+// SLOWLOG GET [count] | SLOWLOG LEN | SLOWLOG RESET
+func stdSlowlog(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	switch parameters.Get("subcommand") {
+	case "GET":
+		var count = 10
+
+		if parameters.Has("count") {
+			count = int(parameters.GetInt("count"))
+		}
+
+		var entries = runtime.slowLog.get(count)
+
+		if len(entries) == 0 {
+			return emptyResult
+		}
+
+		var lines = make([]string, len(entries))
+
+		for index := range entries {
+			lines[index] = entries[index].String()
+		}
+
+		return []string{strings.Join(lines, "\n")}
+	case "LEN":
+		return []string{strconv.Itoa(runtime.slowLog.length())}
+	}
+
+	runtime.slowLog.reset()
+	return okResult
+}
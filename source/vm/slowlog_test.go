@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowLogRingBuffer(test *testing.T) {
+	var testLog = createSlowLog(time.Millisecond, 3)
+
+	testLog.record("test", "FAST", nil, time.Microsecond)
+
+	for index := 0; index < 5; index++ {
+		testLog.record("test", "SLOW", []string{string(rune('a' + index))}, time.Second)
+	}
+
+	var entries = testLog.get(-1)
+
+	if (testLog.length() != 3) || (len(entries) != 3) ||
+		(entries[0].arguments[1] != "e") || (entries[1].arguments[1] != "d") || (entries[2].arguments[1] != "c") {
+		test.Fail()
+	}
+
+	testLog.setMaxLength(2)
+	entries = testLog.get(-1)
+
+	if (len(entries) != 2) || (entries[0].arguments[1] != "e") || (entries[1].arguments[1] != "d") {
+		test.Fail()
+	}
+
+	testLog.reset()
+
+	if testLog.length() != 0 {
+		test.Fail()
+	}
+}
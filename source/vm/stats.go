@@ -0,0 +1,154 @@
+package vm
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"arc/database"
+	"arc/metrics"
+)
+
+type (
+	commandStats struct {
+		calls   metrics.Counter
+		latency *metrics.Histogram
+	}
+
+	runtimeStats struct {
+		commands     map[string]*commandStats
+		commandNames []string
+		errors       map[string]*metrics.Counter
+		errorNames   []string
+		processed    metrics.Counter
+	}
+)
+
+// Error kinds used to classify failed commands.
+var errorKinds = map[string]string{
+	unknownCommandErrorMessage:        "unknown_command",
+	invalidCommandLineErrorMessage:    "invalid_command_line",
+	invalidParametersErrorMessage:     "invalid_parameters",
+	invalidParameterValueErrorMessage: "invalid_parameter_value",
+	invalidDataTypeErrorMessage:       "invalid_data_type",
+	streamingOnlyErrorMessage:         "streaming_only",
+	noSuchKeyErrorMessage:             "no_such_key",
+	outOfMemoryErrorMessage:           "out_of_memory",
+	readOnlyReplicaErrorMessage:       "read_only_replica",
+	crossSlotErrorMessage:             "cross_slot",
+	clusterDownErrorMessage:           "cluster_down",
+	syntaxErrorMessage:                "syntax_error",
+	readOnlyErrorMessage:              "read_only",
+	timeoutErrorMessage:               "timeout",
+	cancelledErrorMessage:             "cancelled",
+	preconditionFailedErrorMessage:    "precondition_failed",
+	argumentLimitErrorMessage:         "argument_limit",
+	rateLimitedErrorMessage:           "rate_limited",
+}
+
+func createRuntimeStats(library Library) (stats *runtimeStats) {
+	stats = &runtimeStats{
+		commands: make(map[string]*commandStats),
+		errors:   make(map[string]*metrics.Counter, len(errorKinds)+1),
+	}
+
+	for index := range library {
+		if _, exists := stats.commands[library[index].command]; !exists {
+			stats.commands[library[index].command] = &commandStats{
+				latency: metrics.CreateHistogram(metrics.LatencyBuckets),
+			}
+
+			stats.commandNames = append(stats.commandNames, library[index].command)
+		}
+	}
+
+	for _, kind := range append(GetErrorKinds(), GenericErrorKind) {
+		stats.errors[kind] = &metrics.Counter{}
+		stats.errorNames = append(stats.errorNames, kind)
+	}
+
+	sort.Strings(stats.commandNames)
+	sort.Strings(stats.errorNames)
+
+	return
+}
+
+func (stats *runtimeStats) recordCall(command string, duration time.Duration) {
+	if commandStats, exists := stats.commands[command]; exists {
+		commandStats.calls.Increment()
+		commandStats.latency.Observe(duration.Seconds())
+	}
+}
+
+func (stats *runtimeStats) getTotalErrors() (total uint64) {
+	for _, counter := range stats.errors {
+		total += counter.Get()
+	}
+
+	return
+}
+
+// getErrorKind returns the kind of an error message, messages with details ("Error: message: details") have the kind
+// of the message without them.
+func getErrorKind(message string) (kind string, known bool) {
+	if kind, known = errorKinds[message]; known || !strings.HasPrefix(message, ErrorPrefix) {
+		return
+	}
+
+	if index := strings.Index(message[len(ErrorPrefix):], ": "); index >= 0 {
+		kind, known = errorKinds[message[:len(ErrorPrefix)+index]]
+	}
+
+	return
+}
+
+// recordResult counts the error results by kind, the errors without a known kind are of the GenericErrorKind.
+func (stats *runtimeStats) recordResult(result TypedResult) {
+	if result.Type != ErrorResult {
+		return
+	}
+
+	var kind, known = getErrorKind(strings.Join(result.Values, " "))
+
+	if !known {
+		kind = GenericErrorKind
+	}
+
+	stats.errors[kind].Increment()
+}
+
+// WriteMetrics writes the runtime and database metrics using the specified metrics writer.
+func (runtime *Runtime) WriteMetrics(writer *metrics.Writer) {
+	writer.Header("arc_commands_total", metrics.CounterType, "Number of calls per command.")
+
+	for _, name := range runtime.stats.commandNames {
+		writer.Value("arc_commands_total", metrics.Labels{"command": name}, float64(runtime.stats.commands[name].calls.Get()))
+	}
+
+	writer.Header("arc_command_duration_seconds", metrics.HistogramType, "Command execution latency.")
+
+	for _, name := range runtime.stats.commandNames {
+		writer.Histogram("arc_command_duration_seconds", metrics.Labels{"command": name}, runtime.stats.commands[name].latency)
+	}
+
+	writer.Header("arc_command_errors_total", metrics.CounterType, "Number of failed commands per error kind.")
+
+	for _, kind := range runtime.stats.errorNames {
+		writer.Value("arc_command_errors_total", metrics.Labels{"kind": kind}, float64(runtime.stats.errors[kind].Get()))
+	}
+
+	var dbStats = runtime.db.GetStatistics()
+
+	writer.Header("arc_keys", metrics.GaugeType, "Number of keys per value type.")
+	writer.Value("arc_keys", metrics.Labels{"type": database.GetTypeName(database.SingleValue)}, float64(dbStats.SingleValues))
+	writer.Value("arc_keys", metrics.Labels{"type": database.GetTypeName(database.SortedSetValue)}, float64(dbStats.SortedSets))
+
+	writer.Header("arc_keys_with_expire", metrics.GaugeType, "Number of keys with an expire time.")
+	writer.Value("arc_keys_with_expire", nil, float64(dbStats.Expires))
+
+	writer.Header("arc_expired_keys_total", metrics.CounterType, "Number of keys removed due to expiration.")
+	writer.Value("arc_expired_keys_total", nil, float64(dbStats.ExpiredKeys))
+
+	writer.Header("arc_evicted_keys_total", metrics.CounterType, "Number of keys removed due to eviction.")
+	writer.Value("arc_evicted_keys_total", nil, float64(dbStats.EvictedKeys))
+}
@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestErrorStats(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	testRuntime.Use(func(next Handler) Handler {
+		return func(execution *ExecutionContext) TypedResult {
+			if execution.Command == "FAIL" {
+				return TypedResult{Type: ErrorResult, Values: []string{"Error: something unexpected"}}
+			}
+
+			return next(execution)
+		}
+	})
+
+	testRuntime.Execute("SET k v")
+	testRuntime.Execute("INCR k")
+	testRuntime.Execute("FAIL")
+	testRuntime.Execute("FAIL")
+
+	var tests = []struct {
+		kind     string
+		expected uint64
+	}{
+		{"invalid_data_type", 1},
+		{GenericErrorKind, 2},
+		{"unknown_command", 0},
+	}
+
+	for _, testCase := range tests {
+		if count := testRuntime.stats.errors[testCase.kind].Get(); count != testCase.expected {
+			test.Errorf("%s errors = %d, expected %d", testCase.kind, count, testCase.expected)
+		}
+	}
+
+	if result := testRuntime.Execute("INFO stats"); !strings.Contains(result[0], "total_error_replies:3") {
+		test.Errorf("INFO stats = %q", result)
+	}
+}
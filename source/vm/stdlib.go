@@ -1,59 +1,75 @@
 package vm
 
 import (
+	"math"
 	"strconv"
 	"time"
 
 	"arc/database"
 )
 
-// SET key value
-func stdSet(db *database.Database, parameters []string) []string {
-	if len(parameters) != 2 {
-		return invalidParametersResult
-	}
+// Number of members returned by range commands between cancellation checks.
+const rangeCheckInterval = 1024
 
-	db.SetSingleValue(parameters[0], parameters[1], 0)
-	return okResult
-}
+// Longest expiration of the SET command in milliseconds (about 73 million years), so expire times never overflow.
+const maxExpiration = math.MaxInt64 / 4
+
+// SET key value [NX | XX] [EX seconds | PX milliseconds | PXAT unix-time-milliseconds]
+func stdSet(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var key = parameters.Get("key")
+	var expires int64
 
-// SET key value EX seconds
-func stdSetEx(db *database.Database, parameters []string) []string {
-	if (len(parameters) != 4) || (parameters[2] != "EX") {
-		return invalidParametersResult
+	if parameters.Has("expiration") {
+		var seconds, milliseconds = parameters.GetInt("seconds"), parameters.GetInt("milliseconds")
+		var unixTime = parameters.GetInt("unix-time-milliseconds")
+
+		if (seconds < 0) || (seconds > maxExpiration/1000) || (milliseconds < 0) || (milliseconds > maxExpiration) ||
+			(unixTime < 0) || (unixTime > maxExpiration) || (seconds+milliseconds+unixTime == 0) {
+			return invalidParameterValueResult
+		}
+
+		if expires = unixTime; expires == 0 {
+			expires = time.Now().UnixMilli() + seconds*1000 + milliseconds
+		}
 	}
 
-	if expireSeconds, err := strconv.ParseInt(parameters[3], 10, 64); err == nil {
-		db.SetSingleValue(parameters[0], parameters[1], time.Now().Unix()+expireSeconds)
-		return okResult
+	// Write commands are serialized, so the key can't be changed by other commands between the check and the set.
+
+	switch parameters.Get("condition") {
+	case "NX":
+		if runtime.db.Has(key) {
+			return nilResult
+		}
+	case "XX":
+		if !runtime.db.Has(key) {
+			return nilResult
+		}
 	}
 
-	return invalidParameterValueResult
+	runtime.db.SetSingleValue(key, parameters.Get("value"), expires)
+	return okResult
 }
 
 // GET key
-func stdGet(db *database.Database, parameters []string) []string {
-	if len(parameters) != 1 {
-		return invalidParametersResult
-	}
+func stdGet(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	var value, exists, ok = runtime.db.GetSingleValue(parameters.Get("key"))
 
-	if !db.Has(parameters[0]) {
+	switch {
+	case !exists:
 		return nilResult
+	case !ok:
+		return invalidDataTypeResult
 	}
 
-	return []string{db.GetSingleValue(parameters[0])}
+	return execution.dataResult([]string{value})
 }
 
 // DEL key [key...]
-func stdDel(db *database.Database, parameters []string) []string {
-	if len(parameters) < 1 {
-		return invalidParametersResult
-	}
-
+func stdDel(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
 	var delCounter int64
 
-	for index := range parameters {
-		if db.Unset(parameters[index]) {
+	for _, key := range parameters.GetAll("key") {
+		if runtime.db.Unset(key) {
 			delCounter++
 		}
 	}
@@ -61,22 +77,23 @@ func stdDel(db *database.Database, parameters []string) []string {
 	return []string{strconv.FormatInt(delCounter, 10)}
 }
 
-// DBSIZE
-func stdDbSize(db *database.Database, parameters []string) []string {
-	if len(parameters) != 0 {
-		return invalidParametersResult
+// RENAME key newkey
+func stdRename(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if !runtime.db.Rename(parameters.Get("key"), parameters.Get("newkey")) {
+		return noSuchKeyResult
 	}
 
-	return []string{strconv.FormatInt(int64(db.Size()), 10)}
+	return okResult
 }
 
-// INCR key
-func stdIncr(db *database.Database, parameters []string) []string {
-	if len(parameters) != 1 {
-		return invalidParametersResult
-	}
+// DBSIZE
+func stdDbSize(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	return []string{strconv.FormatInt(int64(runtime.db.Size()), 10)}
+}
 
-	if newValue, ok := db.IncrementSingleValue(parameters[0]); ok {
+// INCR key
+func stdIncr(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if newValue, ok := runtime.db.IncrementSingleValue(parameters.Get("key")); ok {
 		return []string{strconv.FormatInt(newValue, 10)}
 	}
 
@@ -84,53 +101,28 @@ func stdIncr(db *database.Database, parameters []string) []string {
 }
 
 // ZADD key score member [score member...]
-func stdZadd(db *database.Database, parameters []string) []string {
-	if (len(parameters) < 3) || (len(parameters)%2 == 0) {
-		return invalidParametersResult
-	}
+func stdZadd(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	// The scores are validated before adding, so we can mimic a transaction like (all or none) operation.
 
-	var set *database.SortedSet
+	var scores = parameters.GetAll("score")
+	var members = parameters.GetAll("member")
+	var entries = make([]*database.SortedSetEntry, len(members))
 
-	if value := db.Get(parameters[0]); value == nil {
-		set = database.CreateSortedSet()
-		db.SetSortedSet(parameters[0], set, 0)
-	} else if value.GetType() != database.SortedSetValue {
-		return invalidDataTypeResult
-	} else {
-		set = value.Get().(*database.SortedSet)
+	for index := range members {
+		var score, _ = strconv.ParseFloat(scores[index], 64)
+		entries[index] = database.CreateSortedSetEntry(members[index], score)
 	}
 
-	// Parse the values to make sure they are valid before adding (so we can mimic a transaction like - all or none - operation).
-
-	var numberOfElements = (len(parameters) - 1) / 2
-	var entries = make([]*database.SortedSetEntry, numberOfElements)
-
-	for index := 0; index < numberOfElements; index++ {
-		if value, err := strconv.ParseFloat(parameters[(index*2)+1], 64); err == nil {
-			entries[index] = database.CreateSortedSetEntry(parameters[(index*2)+2], value)
-		} else {
-			return invalidParameterValueResult
-		}
+	if addCounter, ok := runtime.db.AddSortedSetEntries(parameters.Get("key"), entries); ok {
+		return []string{strconv.FormatInt(addCounter, 10)}
 	}
 
-	var addCounter int64
-
-	for index := range entries {
-		if set.AddEntry(entries[index]) {
-			addCounter++
-		}
-	}
-
-	return []string{strconv.FormatInt(addCounter, 10)}
+	return invalidDataTypeResult
 }
 
 // ZCARD key
-func stdZcard(db *database.Database, parameters []string) []string {
-	if len(parameters) != 1 {
-		return invalidParametersResult
-	}
-
-	if set := db.GetSortedSet(parameters[0]); set != nil {
+func stdZcard(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if set := runtime.db.GetSortedSet(parameters.Get("key")); set != nil {
 		return []string{strconv.FormatInt(int64(set.Len()), 10)}
 	}
 
@@ -138,13 +130,9 @@ func stdZcard(db *database.Database, parameters []string) []string {
 }
 
 // ZRANK key member
-func stdZrank(db *database.Database, parameters []string) []string {
-	if len(parameters) != 2 {
-		return invalidParametersResult
-	}
-
-	if set := db.GetSortedSet(parameters[0]); set != nil {
-		if rank := set.GetRank(parameters[1]); rank >= 0 {
+func stdZrank(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if set := runtime.db.GetSortedSet(parameters.Get("key")); set != nil {
+		if rank := set.GetRank(parameters.Get("member")); rank >= 0 {
 			return []string{strconv.FormatInt(rank, 10)}
 		}
 	}
@@ -153,19 +141,10 @@ func stdZrank(db *database.Database, parameters []string) []string {
 }
 
 // ZRANGE key start stop
-func stdZrange(db *database.Database, parameters []string) []string {
-	if len(parameters) != 3 {
-		return invalidParametersResult
-	}
-
-	if set := db.GetSortedSet(parameters[0]); set != nil {
-		var start, startError = strconv.ParseInt(parameters[1], 10, 64)
-		var stop, stopError = strconv.ParseInt(parameters[2], 10, 64)
-
-		if (startError != nil) || (stopError != nil) {
-			return invalidParameterValueResult
-		}
-
+func stdZrange(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string {
+	if set := runtime.db.GetSortedSet(parameters.Get("key")); set != nil {
+		var start = parameters.GetInt("start")
+		var stop = parameters.GetInt("stop")
 		var size = int64(set.Len())
 
 		if stop < 0 {
@@ -174,19 +153,31 @@ func stdZrange(db *database.Database, parameters []string) []string {
 			stop = size - 1
 		}
 
+		if start < 0 {
+			start += size
+		}
+
 		if start < 0 {
 			start = 0
-		} else if start > stop {
+		}
+
+		if (start > stop) || (start >= size) {
 			return emptyResult
 		}
 
 		var result = make([]string, stop-start+1)
 
 		for index := start; index <= stop; index++ {
+			if (index-start)%rangeCheckInterval == rangeCheckInterval-1 {
+				if cancellation := getCancellationResult(execution.Context.Err()); cancellation != nil {
+					return cancellation
+				}
+			}
+
 			result[index-start] = set.Get(int(index)).GetMember()
 		}
 
-		return result
+		return execution.dataResult(result)
 	}
 
 	return emptyResult
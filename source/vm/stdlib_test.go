@@ -0,0 +1,92 @@
+package vm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetAndGet(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	var tests = []struct {
+		line     string
+		expected string
+	}{
+		{"SET short value PX 100", okMessage},
+		{"SET long value EX 100", okMessage},
+		{"SET key value PX 0", invalidParameterValueErrorMessage},
+		{"SET key value EX -1", invalidParameterValueErrorMessage},
+		{"SET key value EX 9223372036854775807", invalidParameterValueErrorMessage},
+		{"SET key value PX 9223372036854775807", invalidParameterValueErrorMessage},
+		{"SET key value PXAT 0", invalidParameterValueErrorMessage},
+		{"SET key value PXAT 9223372036854775807", invalidParameterValueErrorMessage},
+		{"SET key value PXAT 4102444800000", okMessage},
+		{"PTTL missing", "-2"},
+		{"ZADD scores 1 alice", "1"},
+		{"GET scores", invalidDataTypeErrorMessage},
+		{"GET short", "value"},
+		{"GET missing", NilMessage},
+		{"PTTL scores", "-1"},
+	}
+
+	for _, testCase := range tests {
+		if result := strings.Join(testRuntime.Execute(testCase.line), " "); result != testCase.expected {
+			test.Errorf("%s = %q, expected %q", testCase.line, result, testCase.expected)
+		}
+	}
+
+	for key, expires := range map[string]int64{"long": time.Now().UnixMilli() + 100000, "key": 4102444800000} {
+		var ttl, _ = strconv.ParseInt(strings.Join(testRuntime.Execute("PTTL "+key), " "), 10, 64)
+
+		if remaining := expires - time.Now().UnixMilli(); (ttl > remaining) || (ttl < remaining-1000) {
+			test.Errorf("PTTL %s = %d, expected about %d", key, ttl, remaining)
+		}
+	}
+
+	// Expire times have a millisecond resolution.
+
+	time.Sleep(150 * time.Millisecond)
+
+	if result := strings.Join(testRuntime.Execute("GET short"), " "); result != NilMessage {
+		test.Errorf("GET of a key expired after 100ms = %q", result)
+	}
+
+	if result := strings.Join(testRuntime.Execute("GET long"), " "); result != "value" {
+		test.Errorf("GET of a key expiring after 100s = %q", result)
+	}
+}
+
+func TestZrange(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	testRuntime.Execute("ZADD z 1 a 2 b 3 c")
+
+	var tests = []struct {
+		line     string
+		expected string
+	}{
+		{"ZRANGE z 0 -1", "a b c"},
+		{"ZRANGE z 1 1", "b"},
+		{"ZRANGE z 0 10", "a b c"},
+		{"ZRANGE z -2 -1", "b c"},
+		{"ZRANGE z -10 1", "a b"},
+		{"ZRANGE z 2 1", ""},
+		{"ZRANGE z 3 10", ""},
+		{"ZRANGE z -1 -10", ""},
+		{"ZRANGE z 0 -10", ""},
+	}
+
+	for _, testCase := range tests {
+		var result = testRuntime.Execute(testCase.line)
+
+		if (len(result) == 1) && (result[0] == NilMessage) {
+			result = nil
+		}
+
+		if joined := strings.Join(result, " "); joined != testCase.expected {
+			test.Errorf("%s = %q, expected %q", testCase.line, joined, testCase.expected)
+		}
+	}
+}
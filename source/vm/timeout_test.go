@@ -0,0 +1,42 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommandCancellation(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+
+	testRuntime.Execute("ZADD z 1 a 2 b")
+
+	var expiredContext, cancelExpired = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	var result = testRuntime.ExecuteWith(ExecutionContext{Context: expiredContext}, "ZRANGE z 0 -1")
+
+	if !errors.Is(GetError(result), ErrTimeout) {
+		test.Errorf("expired ZRANGE = %q", result)
+	}
+
+	var cancelledContext, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if result = testRuntime.ExecuteWith(ExecutionContext{Context: cancelledContext}, "KEYS *"); !errors.Is(GetError(result), ErrCancelled) {
+		test.Errorf("cancelled KEYS = %q", result)
+	}
+
+	if result = testRuntime.Execute("CONFIG SET command-timeout 250"); (len(result) != 1) || (result[0] != okMessage) {
+		test.Errorf("CONFIG SET command-timeout = %q", result)
+	}
+
+	if result = testRuntime.Execute("CONFIG GET command-timeout"); (len(result) != 2) || (result[1] != "250") {
+		test.Errorf("CONFIG GET command-timeout = %q", result)
+	}
+
+	if result = testRuntime.Execute("ZRANGE z 0 -1"); (len(result) != 2) || (result[0] != "a") {
+		test.Errorf("ZRANGE with timeout = %q", result)
+	}
+}
@@ -1,66 +1,393 @@
 package vm
 
-import (
-	"arc/database"
-)
+import "net/http"
 
 type (
-	command struct {
-		identifier string
-		parameters []string
+	// Function defines the virtual machine library function interface, the parameters are parsed and validated
+	// according to the function arguments before the call.
+	Function func(runtime *Runtime, execution *ExecutionContext, parameters *Parameters) []string
+
+	// LibraryFunction holds the needed information for a library function to work on runtime, and its documentation.
+	LibraryFunction struct {
+		command    string
+		arguments  []argument
+		routes     []route
+		call       Function
+		flags      int
+		keys       keySpec
+		categories []string
+		summary    string
+		complexity string
+		since      string
 	}
 
-	// Function defines the virtual machine library function interface.
-	Function func(db *database.Database, parameters []string) []string
+	// arity defines the minimum and maximum (negative for unlimited) number of parameters of a function.
+	arity struct {
+		minimum int
+		maximum int
+	}
 
-	// LibraryFunction holds the needed information for a library function to work on runtime.
-	LibraryFunction struct {
-		command            string
-		numberOfParameters int
-		call               Function
-		help               string
+	// keySpec defines which parameters are keys: from first to last (negative values count from the end) every step
+	// parameters, a zero step means the function has no keys.
+	keySpec struct {
+		first int
+		last  int
+		step  int
 	}
 
 	// Library represents a set of library functions.
 	Library []LibraryFunction
 )
 
+// Library function flags.
+const (
+	// The function changes the database.
+	writeFlag = 1 << iota
+	// The function may use more memory, so it is refused when the memory limit can't be honored.
+	denyOOMFlag
+	// The function only reads the database.
+	readOnlyFlag
+	// The function manages the server (configuration, replication, cluster...).
+	adminFlag
+	// The function blocks the client, waiting for events (only available on streaming connections).
+	blockingFlag
+	// The function runs in constant or logarithmic time.
+	fastFlag
+	// The function propagates its own changes to the replicas, instead of the function call itself (it runs without
+	// the write lock, taking it for each change).
+	noPropagateFlag
+)
+
+// Names of the library function flags, in output order.
+var flagNames = []struct {
+	flag int
+	name string
+}{
+	{writeFlag, "write"},
+	{readOnlyFlag, "readonly"},
+	{denyOOMFlag, "denyoom"},
+	{adminFlag, "admin"},
+	{blockingFlag, "blocking"},
+	{fastFlag, "fast"},
+	{noPropagateFlag, "no-propagate"},
+}
+
 // StandardLibrary defines the standard function library.
 var StandardLibrary = Library{
-	{command: "SET", numberOfParameters: 2, call: stdSet, help: "SET key value"},
-	{command: "SET", numberOfParameters: 4, call: stdSetEx, help: "SET key value EX seconds"},
-	{command: "GET", numberOfParameters: 1, call: stdGet, help: "GET key"},
-	{command: "DEL", numberOfParameters: -1, call: stdDel, help: "DEL key [key...]"},
-	{command: "DBSIZE", numberOfParameters: 0, call: stdDbSize, help: "DBSIZE"},
-	{command: "INCR", numberOfParameters: 1, call: stdIncr, help: "INCR key"},
-	{command: "ZADD", numberOfParameters: -1, call: stdZadd, help: "ZADD key score member [score member...]"},
-	{command: "ZCARD", numberOfParameters: 1, call: stdZcard, help: "ZCARD key"},
-	{command: "ZRANK", numberOfParameters: 2, call: stdZrank, help: "ZRANK key member"},
-	{command: "ZRANGE", numberOfParameters: 3, call: stdZrange, help: "ZRANGE key start stop"},
+	{
+		command: "SET", call: stdSet,
+		arguments: []argument{
+			argKey("key"), argString("value"),
+			optional(argOneOf("condition", argToken("NX"), argToken("XX"))),
+			optional(argOneOf("expiration",
+				withToken("EX", argInteger("seconds")),
+				withToken("PX", argInteger("milliseconds")),
+				withToken("PXAT", argInteger("unix-time-milliseconds")),
+			)),
+		},
+		routes: []route{{method: http.MethodPut, path: "/values/{key}", body: "value"}, {method: http.MethodPut, path: "/values"}},
+		flags:  writeFlag | denyOOMFlag, keys: keySpec{0, 0, 1}, categories: []string{"string"},
+		summary: "Sets the string value of a key, optionally only if it does (XX) or does not (NX) exist.", complexity: "O(1)", since: "1.0.0",
+	},
+	{
+		command: "GET", call: stdGet, arguments: []argument{argKey("key")},
+		routes: []route{{method: http.MethodGet, path: "/values/{key}", raw: true}},
+		flags:  readOnlyFlag | fastFlag, keys: keySpec{0, 0, 1}, categories: []string{"string"},
+		summary: "Returns the string value of a key.", complexity: "O(1)", since: "1.0.0",
+	},
+	{
+		command: "DEL", call: stdDel, arguments: []argument{multiple(argKey("key"))},
+		routes: []route{{method: http.MethodDelete, path: "/values/{key}"}},
+		flags:  writeFlag, keys: keySpec{0, -1, 1}, categories: []string{"keyspace"},
+		summary: "Deletes one or more keys.", complexity: "O(N) where N is the number of keys", since: "1.0.0",
+	},
+	{
+		command: "RENAME", call: stdRename, arguments: []argument{argKey("key"), argKey("newkey")},
+		routes: []route{{method: http.MethodPost, path: "/keys/{key}/rename"}},
+		flags:  writeFlag, keys: keySpec{0, 1, 1}, categories: []string{"keyspace"},
+		summary: "Renames a key, overwriting the destination.", complexity: "O(1)", since: "1.0.0",
+	},
+	{
+		command: "DBSIZE", call: stdDbSize,
+		routes: []route{{method: http.MethodGet, path: "/db/size"}},
+		flags:  readOnlyFlag | fastFlag, categories: []string{"keyspace"},
+		summary: "Returns the number of keys in the database.", complexity: "O(1)", since: "1.0.0",
+	},
+	{
+		command: "INCR", call: stdIncr, arguments: []argument{argKey("key")},
+		routes: []route{{method: http.MethodPatch, path: "/values/{key}"}},
+		flags:  writeFlag | denyOOMFlag | fastFlag, keys: keySpec{0, 0, 1}, categories: []string{"string"},
+		summary: "Increments the integer value of a key by one.", complexity: "O(1)", since: "1.0.0",
+	},
+	{
+		command: "ZADD", call: stdZadd, arguments: []argument{argKey("key"), multiple(argBlock("data", argFloat("score"), argString("member")))},
+		routes: []route{{method: http.MethodPut, path: "/sets"}},
+		flags:  writeFlag | denyOOMFlag | fastFlag, keys: keySpec{0, 0, 1}, categories: []string{"sortedset"},
+		summary: "Adds members to a sorted set, or updates their scores.", since: "1.0.0",
+		complexity: "O(log(N)) for each member added, where N is the number of members in the sorted set",
+	},
+	{
+		command: "ZCARD", call: stdZcard, arguments: []argument{argKey("key")},
+		routes: []route{{method: http.MethodGet, path: "/sets/{key}/size"}},
+		flags:  readOnlyFlag | fastFlag, keys: keySpec{0, 0, 1}, categories: []string{"sortedset"},
+		summary: "Returns the number of members in a sorted set.", complexity: "O(1)", since: "1.0.0",
+	},
+	{
+		command: "ZRANK", call: stdZrank, arguments: []argument{argKey("key"), argString("member")},
+		routes: []route{{method: http.MethodGet, path: "/sets/{key}/rank/{member}"}},
+		flags:  readOnlyFlag | fastFlag, keys: keySpec{0, 0, 1}, categories: []string{"sortedset"},
+		summary: "Returns the index of a member in a sorted set ordered by ascending scores.", since: "1.0.0",
+		complexity: "O(log(N)) where N is the number of members in the sorted set",
+	},
+	{
+		command: "ZRANGE", call: stdZrange, arguments: []argument{argKey("key"), argInteger("start"), argInteger("stop")},
+		routes: []route{{method: http.MethodGet, path: "/sets/{key}", defaults: map[string]string{"start": "0", "stop": "-1"}}},
+		flags:  readOnlyFlag, keys: keySpec{0, 0, 1}, categories: []string{"sortedset"},
+		summary: "Returns the members of a sorted set within a range of indexes.", since: "1.0.0",
+		complexity: "O(log(N)+M) where N is the number of members in the sorted set and M the number of members returned",
+	},
+	{
+		command: "KEYS", call: stdKeys, arguments: []argument{argString("pattern")},
+		routes: []route{{method: http.MethodGet, path: "/keys", defaults: map[string]string{"pattern": "*"}}},
+		flags:  readOnlyFlag, categories: []string{"keyspace"},
+		summary: "Returns the keys matching a glob pattern.", complexity: "O(N) where N is the number of keys", since: "1.1.0",
+	},
+	{
+		command: "SCAN", call: stdScan,
+		arguments: []argument{
+			argInteger("cursor"),
+			optional(withToken("MATCH", argString("pattern"))),
+			optional(withToken("COUNT", argInteger("count"))),
+		},
+		flags: readOnlyFlag, categories: []string{"keyspace"},
+		summary: "Incrementally iterates the keys, returning the next cursor followed by the keys found.", since: "1.1.0",
+		complexity: "O(1) for every call, O(N) for a complete iteration where N is the number of keys",
+	},
+	{
+		command: "TYPE", call: stdType, arguments: []argument{argKey("key")},
+		routes: []route{{method: http.MethodGet, path: "/keys/{key}/type"}},
+		flags:  readOnlyFlag | fastFlag, keys: keySpec{0, 0, 1}, categories: []string{"keyspace"},
+		summary: "Returns the type of the value stored at a key.", complexity: "O(1)", since: "1.1.0",
+	},
+	{
+		command: "PTTL", call: stdPttl, arguments: []argument{argKey("key")},
+		routes: []route{{method: http.MethodGet, path: "/keys/{key}/pttl"}},
+		flags:  readOnlyFlag | fastFlag, keys: keySpec{0, 0, 1}, categories: []string{"keyspace"},
+		summary: "Returns the time to live of a key in milliseconds (-1 without expire time, -2 without key).", complexity: "O(1)", since: "1.1.0",
+	},
+	{
+		command: "MEMORY", call: stdMemory,
+		arguments: []argument{argOneOf("subcommand", argBlock("", argToken("USAGE"), argKey("key")), argToken("STATS"))},
+		flags:     readOnlyFlag, keys: keySpec{1, 1, 1}, categories: []string{"server"},
+		summary: "Reports the memory used by a key or the memory statistics.", since: "1.1.0",
+		complexity: "O(N) where N is the number of members of the value",
+	},
+	{
+		command: "OBJECT", call: stdObject,
+		arguments: []argument{argOneOf("subcommand", argToken("ENCODING"), argToken("IDLETIME"), argToken("FREQ")), argKey("key")},
+		flags:     readOnlyFlag, keys: keySpec{1, 1, 1}, categories: []string{"keyspace"},
+		summary: "Inspects the internals of a value.", complexity: "O(1)", since: "1.1.0",
+	},
+	{
+		command: "INFO", call: stdInfo, arguments: []argument{optional(argString("section"))},
+		routes:     []route{{method: http.MethodGet, path: "/info"}, {method: http.MethodGet, path: "/info/{section}"}},
+		categories: []string{"server"},
+		summary:    "Returns information and statistics about the server.", complexity: "O(1)", since: "1.1.0",
+	},
+	{
+		command: "CLIENT", call: stdClient, arguments: []argument{argToken("INFO")},
+		flags: fastFlag, categories: []string{"connection"},
+		summary: "Returns the client, user and database of the connection.", complexity: "O(1)", since: "1.1.0",
+	},
+	{
+		command: "COMMAND", call: stdCommand,
+		arguments: []argument{optional(argOneOf("subcommand",
+			argToken("COUNT"),
+			argBlock("", argToken("INFO"), multiple(argString("command"))),
+			argBlock("", argToken("DOCS"), optional(multiple(argString("command")))),
+		))},
+		categories: []string{"server"},
+		summary:    "Returns information about the available commands.", complexity: "O(N) where N is the number of commands", since: "1.1.0",
+	},
+	{
+		command: "SLOWLOG", call: stdSlowlog,
+		arguments: []argument{argOneOf("subcommand", argBlock("", argToken("GET"), optional(argInteger("count"))), argToken("LEN"), argToken("RESET"))},
+		flags:     adminFlag, categories: []string{"server"},
+		summary: "Manages the slow commands log.", complexity: "O(N) where N is the number of entries returned", since: "1.1.0",
+	},
+	{
+		command: "CONFIG", call: stdConfig,
+		arguments: []argument{argOneOf("subcommand",
+			argBlock("", argToken("GET"), argString("pattern")),
+			argBlock("", argToken("SET"), argString("parameter"), argString("value")),
+		)},
+		flags: adminFlag, categories: []string{"server"},
+		summary: "Gets or sets the configuration parameters.", complexity: "O(N) where N is the number of parameters", since: "1.1.0",
+	},
+	{
+		command: "RATELIMIT", call: stdRatelimit,
+		arguments: []argument{argOneOf("subcommand",
+			argToken("STATUS"),
+			argBlock("", argToken("CLIENTS"), optional(argString("pattern"))),
+			argBlock("", argToken("RESET"), argString("client-key")),
+		)},
+		flags: adminFlag, categories: []string{"server"},
+		summary: "Returns the state of the client rate limits, or resets the budgets of a client.", complexity: "O(N) where N is the number of clients", since: "1.1.0",
+	},
+	{
+		command: "REPLICAOF", call: stdReplicaof,
+		arguments: []argument{argOneOf("leader", argBlock("", argString("host"), argString("port")), argBlock("", argToken("NO"), argToken("ONE")))},
+		flags:     adminFlag, categories: []string{"server"},
+		summary: "Makes the server a replica of another server, or promotes it to leader.", complexity: "O(1)", since: "1.1.0",
+	},
+	{
+		command: "REPLCONF", call: stdReplconf, arguments: []argument{argToken("ACK"), argString("replica-id"), argInteger("offset")},
+		flags: adminFlag | fastFlag, categories: []string{"server"},
+		summary: "Reports the replication offset of a replica (used internally by replicas).", complexity: "O(1)", since: "1.1.0",
+	},
+	{
+		command: "CLUSTER", call: stdCluster,
+		arguments: []argument{argOneOf("subcommand",
+			argToken("INFO"), argToken("MYID"), argToken("NODES"), argToken("SLOTS"),
+			argBlock("", argToken("ADDSLOTS"), multiple(argString("slot"))),
+			argBlock("", argToken("DELSLOTS"), multiple(argString("slot"))),
+			argBlock("", argToken("MEET"), argString("node-id"), argString("address")),
+			argBlock("", argToken("FORGET"), argString("node-id")),
+			argBlock("", argToken("SETSLOT"), argString("slot"), argOneOf("state",
+				argBlock("", argToken("IMPORTING"), argString("node-id")),
+				argBlock("", argToken("MIGRATING"), argString("node-id")),
+				argBlock("", argToken("NODE"), argString("node-id")),
+				argToken("STABLE"),
+			)),
+			argBlock("", argToken("KEYSLOT"), argString("key")),
+			argBlock("", argToken("COUNTKEYSINSLOT"), argInteger("slot")),
+			argBlock("", argToken("GETKEYSINSLOT"), argInteger("slot"), argInteger("count")),
+		)},
+		flags: adminFlag, categories: []string{"cluster"},
+		summary: "Manages the cluster topology and hash slots.", complexity: "O(N) where N is the number of slots or keys involved", since: "1.1.0",
+	},
+	{
+		command: "MIGRATE", call: stdMigrate, arguments: []argument{argString("host"), argString("port"), multiple(argKey("key"))},
+		flags: writeFlag | noPropagateFlag, keys: keySpec{2, -1, 1}, categories: []string{"keyspace", "cluster"},
+		summary: "Moves keys to another server.", complexity: "O(N) where N is the number of keys", since: "1.1.0",
+	},
+	{
+		command: "PUBLISH", call: stdPublish, arguments: []argument{argString("channel"), argString("message")},
+		routes: []route{{method: http.MethodPost, path: "/channels/{channel}", body: "message"}},
+		flags:  fastFlag, categories: []string{"pubsub"},
+		summary: "Posts a message to a channel.", since: "1.1.0",
+		complexity: "O(N+M) where N is the number of channel subscribers and M the number of pattern subscriptions",
+	},
+	{
+		command: "PUBSUB", call: stdPubsub,
+		arguments: []argument{argOneOf("subcommand",
+			argBlock("", argToken("CHANNELS"), optional(argString("pattern"))),
+			argBlock("", argToken("NUMSUB"), optional(multiple(argString("channel")))),
+			argToken("NUMPAT"),
+		)},
+		categories: []string{"pubsub"},
+		summary:    "Inspects the state of the publish/subscribe system.", complexity: "O(N) where N is the number of channels", since: "1.1.0",
+	},
+	{
+		command: "SUBSCRIBE", call: stdSubscribe, arguments: []argument{multiple(argString("channel"))},
+		flags: blockingFlag, categories: []string{"pubsub"},
+		summary: "Listens for messages published to channels.", complexity: "O(N) where N is the number of channels", since: "1.1.0",
+	},
+	{
+		command: "PSUBSCRIBE", call: stdSubscribe, arguments: []argument{multiple(argString("pattern"))},
+		flags: blockingFlag, categories: []string{"pubsub"},
+		summary: "Listens for messages published to channels matching patterns.", complexity: "O(N) where N is the number of patterns", since: "1.1.0",
+	},
+	{
+		command: "UNSUBSCRIBE", call: stdSubscribe, arguments: []argument{optional(multiple(argString("channel")))},
+		flags: blockingFlag, categories: []string{"pubsub"},
+		summary: "Stops listening for messages published to channels.", complexity: "O(N) where N is the number of channels", since: "1.1.0",
+	},
+	{
+		command: "PUNSUBSCRIBE", call: stdSubscribe, arguments: []argument{optional(multiple(argString("pattern")))},
+		flags: blockingFlag, categories: []string{"pubsub"},
+		summary: "Stops listening for messages published to channels matching patterns.", complexity: "O(N) where N is the number of patterns", since: "1.1.0",
+	},
 }
 
 const (
-	nilMessage                        = "(nil)"
+	// ErrorPrefix starts all the error messages.
+	ErrorPrefix = "Error: "
+
+	// NilMessage is returned for keys and members that do not exist.
+	NilMessage = "(nil)"
+
 	okMessage                         = "OK"
 	unknownCommandErrorMessage        = "Error: unknown command or invalid parameters for command"
 	invalidCommandLineErrorMessage    = "Error: invalid command line"
 	invalidParametersErrorMessage     = "Error: invalid parameters"
 	invalidParameterValueErrorMessage = "Error: invalid parameter value"
 	invalidDataTypeErrorMessage       = "Error: invalid data type"
+	streamingOnlyErrorMessage         = "Error: command only available on streaming connections"
+	noSuchKeyErrorMessage             = "Error: no such key"
+	outOfMemoryErrorMessage           = "Error: out of memory, command not allowed when used memory > maxmemory"
+	readOnlyReplicaErrorMessage       = "Error: read only replica, write commands are not allowed"
+	crossSlotErrorMessage             = "Error: keys in request don't hash to the same slot"
+	clusterDownErrorMessage           = "Error: cluster down, hash slot not served"
+	syntaxErrorMessage                = "Error: syntax error"
+	readOnlyErrorMessage              = "Error: read only, write commands are not allowed"
+	timeoutErrorMessage               = "Error: timeout, command execution time limit exceeded"
+	cancelledErrorMessage             = "Error: command cancelled"
+	preconditionFailedErrorMessage    = "Error: precondition failed, the key version does not match"
+	argumentLimitErrorMessage         = "Error: argument limit exceeded"
+	rateLimitedErrorMessage           = "Error: rate limit exceeded"
 )
 
 var (
 	emptyResult                 = []string{}
-	nilResult                   = []string{nilMessage}
+	nilResult                   = []string{NilMessage}
 	okResult                    = []string{okMessage}
 	unknownCommandResult        = []string{unknownCommandErrorMessage}
+	readOnlyResult              = []string{readOnlyErrorMessage}
+	timeoutResult               = []string{timeoutErrorMessage}
+	cancelledResult             = []string{cancelledErrorMessage}
+	preconditionFailedResult    = []string{preconditionFailedErrorMessage}
 	invlaidCommandLineResult    = []string{invalidCommandLineErrorMessage}
 	invalidParametersResult     = []string{invalidParametersErrorMessage}
 	invalidParameterValueResult = []string{invalidParameterValueErrorMessage}
 	invalidDataTypeResult       = []string{invalidDataTypeErrorMessage}
+	streamingOnlyResult         = []string{streamingOnlyErrorMessage}
+	noSuchKeyResult             = []string{noSuchKeyErrorMessage}
+	outOfMemoryResult           = []string{outOfMemoryErrorMessage}
+	readOnlyReplicaResult       = []string{readOnlyReplicaErrorMessage}
+	crossSlotResult             = []string{crossSlotErrorMessage}
+	clusterDownResult           = []string{clusterDownErrorMessage}
 )
 
-// GetHelp returns the help string for the function.
+// getKeys returns the keys found in the function parameters.
+func (function *LibraryFunction) getKeys(parameters []string) (keys []string) {
+	if function.keys.step == 0 {
+		return nil
+	}
+
+	var last = function.keys.last
+
+	if last < 0 {
+		last += len(parameters)
+	}
+
+	for index := function.keys.first; (index <= last) && (index < len(parameters)); index += function.keys.step {
+		keys = append(keys, parameters[index])
+	}
+
+	return
+}
+
+// GetHelp returns the help string (the command syntax) for the function.
 func (function *LibraryFunction) GetHelp() string {
-	return function.help
+	if len(function.arguments) == 0 {
+		return function.command
+	}
+
+	return function.command + " " + getSyntax(function.arguments)
+}
+
+// GetSummary returns a short description of the function.
+func (function *LibraryFunction) GetSummary() string {
+	return function.summary
 }
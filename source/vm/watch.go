@@ -0,0 +1,170 @@
+package vm
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"arc/database"
+	"arc/glob"
+)
+
+// Number of changes a watcher can hold before new changes are dropped.
+const watcherBufferSize = 1024
+
+type (
+	// KeyChange describes a single change of a watched key.
+	KeyChange struct {
+		Key string
+
+		// Event is the database event name (like set, del or expired).
+		Event string
+
+		// Version is the key version after the change, zero when the key was removed.
+		Version uint64
+	}
+
+	// Watcher receives the changes of the keys matching its patterns, or of a single key.
+	Watcher struct {
+		patterns []string
+		key      string
+		changes  chan KeyChange
+		dropped  atomic.Uint64
+		runtime  *Runtime
+	}
+
+	// watchHub indexes the watchers of a single key by key, so writes only match the patterns of the other watchers.
+	watchHub struct {
+		mutex    sync.RWMutex
+		keys     map[string]map[*Watcher]struct{}
+		patterns map[*Watcher]struct{}
+		count    atomic.Int32
+	}
+)
+
+func createWatchHub() *watchHub {
+	return &watchHub{
+		keys:     make(map[string]map[*Watcher]struct{}),
+		patterns: make(map[*Watcher]struct{}),
+	}
+}
+
+// notifyWatchers is the database listener that sends the key changes to the watchers, slow watchers never block the
+// database (changes are dropped instead).
+func (runtime *Runtime) notifyWatchers(event database.Event) {
+	var hub = runtime.watchers
+
+	if hub.count.Load() == 0 {
+		return
+	}
+
+	var change = KeyChange{Key: event.Key, Event: event.Name, Version: event.Version}
+
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+
+	for watcher := range hub.keys[event.Key] {
+		watcher.send(change)
+	}
+
+	for watcher := range hub.patterns {
+		if watcher.matches(event.Key) {
+			watcher.send(change)
+		}
+	}
+}
+
+// Watch creates a new watcher that receives the changes of the keys matching any of the glob-style patterns until it
+// is closed.
+func (runtime *Runtime) Watch(patterns ...string) (watcher *Watcher) {
+	watcher = &Watcher{
+		patterns: patterns,
+		changes:  make(chan KeyChange, watcherBufferSize),
+		runtime:  runtime,
+	}
+
+	runtime.watchers.mutex.Lock()
+	defer runtime.watchers.mutex.Unlock()
+
+	runtime.watchers.patterns[watcher] = struct{}{}
+	runtime.watchers.count.Add(1)
+	return
+}
+
+// WatchKey creates a new watcher that receives the changes of a single key until it is closed.
+func (runtime *Runtime) WatchKey(key string) (watcher *Watcher) {
+	watcher = &Watcher{
+		key:     key,
+		changes: make(chan KeyChange, watcherBufferSize),
+		runtime: runtime,
+	}
+
+	var hub = runtime.watchers
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if hub.keys[key] == nil {
+		hub.keys[key] = make(map[*Watcher]struct{})
+	}
+
+	hub.keys[key][watcher] = struct{}{}
+	hub.count.Add(1)
+	return
+}
+
+// GetKeyVersion returns the current version of a key, zero when the key does not exist.
+func (runtime *Runtime) GetKeyVersion(key string) uint64 {
+	return runtime.db.GetVersion(key)
+}
+
+func (watcher *Watcher) matches(key string) bool {
+	for _, pattern := range watcher.patterns {
+		if glob.Match(pattern, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// send sends a change to the watcher, or drops it when the watcher is not keeping up.
+func (watcher *Watcher) send(change KeyChange) {
+	select {
+	case watcher.changes <- change:
+	default:
+		watcher.dropped.Add(1)
+	}
+}
+
+// Changes returns the watcher change channel.
+func (watcher *Watcher) Changes() <-chan KeyChange {
+	return watcher.changes
+}
+
+// GetDropped returns the number of changes dropped because the watcher was not keeping up.
+func (watcher *Watcher) GetDropped() uint64 {
+	return watcher.dropped.Load()
+}
+
+// Close stops receiving changes and closes the change channel.
+func (watcher *Watcher) Close() {
+	var hub = watcher.runtime.watchers
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if _, exists := hub.patterns[watcher]; exists {
+		delete(hub.patterns, watcher)
+	} else if _, exists = hub.keys[watcher.key][watcher]; exists {
+		delete(hub.keys[watcher.key], watcher)
+
+		if len(hub.keys[watcher.key]) == 0 {
+			delete(hub.keys, watcher.key)
+		}
+	} else {
+		return
+	}
+
+	hub.count.Add(-1)
+	close(watcher.changes)
+}
@@ -0,0 +1,85 @@
+package vm
+
+import (
+	"testing"
+
+	"arc/database"
+)
+
+func TestWatch(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var users = testRuntime.Watch("user:*")
+	var star = testRuntime.WatchKey("*")
+
+	testRuntime.Execute("SET user:1 a")
+	testRuntime.Execute("SET other b")
+	testRuntime.Execute("SET * c")
+	testRuntime.Execute("INCR user:2")
+	testRuntime.Execute("DEL user:1")
+
+	var expected = []string{"set user:1", "incr user:2", "del user:1"}
+
+	for _, event := range expected {
+		var change = <-users.Changes()
+
+		if change.Event+" "+change.Key != event {
+			test.Errorf("change = %s %s, expected %s", change.Event, change.Key, event)
+		}
+
+		if (change.Version == 0) != (change.Event == database.DelEvent) {
+			test.Errorf("%s %s version = %d", change.Event, change.Key, change.Version)
+		}
+	}
+
+	if change := <-star.Changes(); change.Key != "*" {
+		test.Errorf("escaped key watch received %q", change.Key)
+	}
+
+	users.Close()
+	star.Close()
+
+	if change, open := <-star.Changes(); open {
+		test.Errorf("escaped key watch received %q", change.Key)
+	}
+
+	if testRuntime.GetKeyVersion("user:2") == 0 {
+		test.Error("missing key version")
+	}
+}
+
+func TestWatchKey(test *testing.T) {
+	var testRuntime = createTestRuntime(test)
+	var first = testRuntime.WatchKey("user:*")
+	var second = testRuntime.WatchKey("user:*")
+	var pattern = testRuntime.Watch("user:*")
+
+	testRuntime.Execute("SET user:1 a")
+	testRuntime.Execute("SET user:* b")
+
+	for _, watcher := range []*Watcher{first, second} {
+		if change := <-watcher.Changes(); change.Key != "user:*" {
+			test.Errorf("key watcher received %q", change.Key)
+		}
+	}
+
+	for _, key := range []string{"user:1", "user:*"} {
+		if change := <-pattern.Changes(); change.Key != key {
+			test.Errorf("pattern watcher received %q, expected %q", change.Key, key)
+		}
+	}
+
+	first.Close()
+	first.Close()
+	testRuntime.Execute("DEL user:*")
+
+	if change := <-second.Changes(); change.Event != database.DelEvent {
+		test.Errorf("remaining key watcher received %s %s", change.Event, change.Key)
+	}
+
+	second.Close()
+	pattern.Close()
+
+	if (len(testRuntime.watchers.keys) != 0) || (len(testRuntime.watchers.patterns) != 0) || (testRuntime.watchers.count.Load() != 0) {
+		test.Errorf("closed watchers are still registered: %v %v", testRuntime.watchers.keys, testRuntime.watchers.patterns)
+	}
+}